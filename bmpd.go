@@ -35,8 +35,9 @@ func processBMPClinet(conn net.Conn) {
 	tcpConn := conn.(*net.TCPConn)
 	defer tcpConn.Close()
 
+	session := bgp.NewBMPSession()
 	for {
-		msg, err := bgp.ReadBMPMessage(tcpConn)
+		msg, err := session.ReadBMPMessage(tcpConn)
 		if err != nil {
 			fmt.Println(err)
 			log.Println("BMP client disconnected", conn.RemoteAddr())
@@ -16,27 +16,46 @@
 package bgp
 
 import (
+	"bytes"
 	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+
 	"math"
 	"net"
+	"net/netip"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 // move somewhere else
 
 const (
-	AFI_IP  = 1
-	AFI_IP6 = 2
+	AFI_IP    = 1
+	AFI_IP6   = 2
+	AFI_L2VPN = 25
+	AFI_LS    = 16388
 )
 
 const (
 	SAFI_UNICAST                  = 1
 	SAFI_MULTICAST                = 2
 	SAFI_MPLS_LABEL               = 4
+	SAFI_MCAST_VPN                = 5
+	SAFI_VPLS                     = 65
+	SAFI_EVPN                     = 70
+	SAFI_LS                       = 71
+	SAFI_LS_VPN                   = 72
+	SAFI_SR_POLICY                = 73
 	SAFI_MPLS_VPN                 = 128
+	SAFI_MPLS_VPN_MULTICAST       = 129
 	SAFI_ROUTE_TARGET_CONSTRTAINS = 132
+	SAFI_FLOW_SPEC_UNICAST        = 133
+	SAFI_FLOW_SPEC_VPN            = 134
 )
 
 const (
@@ -55,13 +74,18 @@ const (
 type BGPCapabilityCode uint8
 
 const (
-	BGP_CAP_MULTIPROTOCOL          BGPCapabilityCode = 1
-	BGP_CAP_ROUTE_REFRESH                            = 2
-	BGP_CAP_CARRYING_LABEL_INFO                      = 4
-	BGP_CAP_GRACEFUL_RESTART                         = 64
-	BGP_CAP_FOUR_OCTET_AS_NUMBER                     = 65
-	BGP_CAP_ENHANCED_ROUTE_REFRESH                   = 70
-	BGP_CAP_ROUTE_REFRESH_CISCO                      = 128
+	BGP_CAP_MULTIPROTOCOL                  BGPCapabilityCode = 1
+	BGP_CAP_ROUTE_REFRESH                                    = 2
+	BGP_CAP_OUTBOUND_ROUTE_FILTERING                         = 3
+	BGP_CAP_CARRYING_LABEL_INFO                              = 4
+	BGP_CAP_EXTENDED_MESSAGE                                 = 6
+	BGP_CAP_MULTIPLE_LABELS                                  = 8
+	BGP_CAP_GRACEFUL_RESTART                                 = 64
+	BGP_CAP_FOUR_OCTET_AS_NUMBER                             = 65
+	BGP_CAP_ADD_PATH                                         = 69
+	BGP_CAP_ENHANCED_ROUTE_REFRESH                           = 70
+	BGP_CAP_ROUTE_REFRESH_CISCO                              = 128
+	BGP_CAP_OUTBOUND_ROUTE_FILTERING_CISCO                   = 130
 )
 
 func (c BGPCapabilityCode) String() string {
@@ -70,16 +94,26 @@ func (c BGPCapabilityCode) String() string {
 		return "MultiProtocol"
 	case BGP_CAP_ROUTE_REFRESH:
 		return "RouteRefresh"
+	case BGP_CAP_OUTBOUND_ROUTE_FILTERING:
+		return "OutboundRouteFiltering"
 	case BGP_CAP_CARRYING_LABEL_INFO:
 		return "CarryingLabelInfo"
+	case BGP_CAP_EXTENDED_MESSAGE:
+		return "ExtendedMessage"
+	case BGP_CAP_MULTIPLE_LABELS:
+		return "MultipleLabels"
 	case BGP_CAP_GRACEFUL_RESTART:
 		return "GracefulRestart"
 	case BGP_CAP_FOUR_OCTET_AS_NUMBER:
 		return "FourOctetASNumber"
+	case BGP_CAP_ADD_PATH:
+		return "AddPath"
 	case BGP_CAP_ENHANCED_ROUTE_REFRESH:
 		return "EnhancedRouteRefresh"
 	case BGP_CAP_ROUTE_REFRESH_CISCO:
 		return "RouteRefreshCisco"
+	case BGP_CAP_OUTBOUND_ROUTE_FILTERING_CISCO:
+		return "OutboundRouteFilteringCisco"
 	}
 	return "Unknown"
 }
@@ -92,6 +126,8 @@ func structName(m interface{}) string {
 type ParameterCapabilityInterface interface {
 	DecodeFromBytes([]byte) error
 	Len() int
+	Code() BGPCapabilityCode
+	String() string
 }
 
 type DefaultParameterCapability struct {
@@ -110,10 +146,150 @@ func (c *DefaultParameterCapability) DecodeFromBytes(data []byte) error {
 	return nil
 }
 
+func (c *DefaultParameterCapability) Code() BGPCapabilityCode {
+	return c.CapCode
+}
+
 func (c *DefaultParameterCapability) Len() int {
 	return int(c.CapLen + 2)
 }
 
+func (c *DefaultParameterCapability) String() string {
+	return c.CapCode.String()
+}
+
+// capabilityJSON is the JSON form of a capability. Capabilities without
+// a structured value carry their raw value in hex.
+type capabilityJSON struct {
+	Code  BGPCapabilityCode `json:"code"`
+	Name  string            `json:"name"`
+	Value json.RawMessage   `json:"value,omitempty"`
+}
+
+func (c *DefaultParameterCapability) marshalJSON(code BGPCapabilityCode, value interface{}) ([]byte, error) {
+	j := capabilityJSON{Code: code, Name: code.String()}
+	if value != nil {
+		v, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		j.Value = v
+	}
+	return json.Marshal(j)
+}
+
+func (c *DefaultParameterCapability) MarshalJSON() ([]byte, error) {
+	var value interface{}
+	if len(c.CapValue) > 0 {
+		value = fmt.Sprintf("%x", c.CapValue)
+	}
+	return c.marshalJSON(c.CapCode, value)
+}
+
+// decodeCapabilityJSON rebuilds the wire form of a capability from its
+// JSON form and decodes it.
+func decodeCapabilityJSON(data []byte) (ParameterCapabilityInterface, error) {
+	var j capabilityJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, err
+	}
+	value, err := capabilityValueFromJSON(&j)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s capability: %s", j.Code, err)
+	}
+	if len(value) > math.MaxUint8 {
+		return nil, fmt.Errorf("%s capability is too long", j.Code)
+	}
+	buf := append([]byte{uint8(j.Code), uint8(len(value))}, value...)
+	o := OptionParameterCapability{ParamType: BGP_OPT_CAPABILITY, ParamLen: uint8(len(buf))}
+	if err := o.DecodeFromBytes(buf); err != nil {
+		return nil, err
+	}
+	if len(o.Capability) != 1 {
+		return nil, fmt.Errorf("invalid %s capability", j.Code)
+	}
+	return o.Capability[0], nil
+}
+
+func capabilityValueFromJSON(j *capabilityJSON) ([]byte, error) {
+	var buf []byte
+	switch j.Code {
+	case BGP_CAP_MULTIPROTOCOL:
+		var f familyJSON
+		if err := json.Unmarshal(j.Value, &f); err != nil {
+			return nil, err
+		}
+		buf = binary.BigEndian.AppendUint16(buf, f.AFI)
+		return append(buf, 0, f.SAFI), nil
+	case BGP_CAP_OUTBOUND_ROUTE_FILTERING, BGP_CAP_OUTBOUND_ROUTE_FILTERING_CISCO:
+		var values []orfCapabilityJSON
+		if err := json.Unmarshal(j.Value, &values); err != nil {
+			return nil, err
+		}
+		for _, v := range values {
+			buf = binary.BigEndian.AppendUint16(buf, v.AFI)
+			buf = append(buf, 0, v.SAFI, uint8(len(v.ORFs)))
+			for _, t := range v.ORFs {
+				mode, err := nameToCode(orfModeNames, t.Mode)
+				if err != nil {
+					return nil, err
+				}
+				buf = append(buf, t.Type, mode)
+			}
+		}
+		return buf, nil
+	case BGP_CAP_MULTIPLE_LABELS:
+		var values []multipleLabelsJSON
+		if err := json.Unmarshal(j.Value, &values); err != nil {
+			return nil, err
+		}
+		for _, v := range values {
+			buf = binary.BigEndian.AppendUint16(buf, v.AFI)
+			buf = append(buf, v.SAFI, v.Count)
+		}
+		return buf, nil
+	case BGP_CAP_GRACEFUL_RESTART:
+		var v gracefulRestartJSON
+		if err := json.Unmarshal(j.Value, &v); err != nil {
+			return nil, err
+		}
+		buf = binary.BigEndian.AppendUint16(buf, uint16(v.Flags)<<12|v.Time&0xfff)
+		for _, t := range v.Families {
+			buf = binary.BigEndian.AppendUint16(buf, t.AFI)
+			buf = append(buf, t.SAFI, t.Flags)
+		}
+		return buf, nil
+	case BGP_CAP_FOUR_OCTET_AS_NUMBER:
+		var as uint32
+		if err := json.Unmarshal(j.Value, &as); err != nil {
+			return nil, err
+		}
+		return binary.BigEndian.AppendUint32(buf, as), nil
+	case BGP_CAP_ADD_PATH:
+		var values []addPathJSON
+		if err := json.Unmarshal(j.Value, &values); err != nil {
+			return nil, err
+		}
+		for _, v := range values {
+			mode, err := nameToCode(addPathModeNames, v.Mode)
+			if err != nil {
+				return nil, err
+			}
+			buf = binary.BigEndian.AppendUint16(buf, v.AFI)
+			buf = append(buf, v.SAFI, mode)
+		}
+		return buf, nil
+	}
+	if len(j.Value) == 0 {
+		return nil, nil
+	}
+	var s string
+	if err := json.Unmarshal(j.Value, &s); err != nil {
+		return nil, err
+	}
+	return hex.DecodeString(s)
+}
+
 type CapMultiProtocolValue struct {
 	AFI  uint16
 	SAFI uint8
@@ -135,34 +311,249 @@ func (c *CapMultiProtocol) DecodeFromBytes(data []byte) error {
 	return nil
 }
 
+func (c *CapMultiProtocol) String() string {
+	return fmt.Sprintf("MultiProtocol(%s)", routeFamilyName(c.CapValue.AFI, c.CapValue.SAFI))
+}
+
+func (c *CapMultiProtocol) MarshalJSON() ([]byte, error) {
+	return c.marshalJSON(BGP_CAP_MULTIPROTOCOL, newFamilyJSON(c.CapValue.AFI, c.CapValue.SAFI))
+}
+
 type CapRouteRefresh struct {
 	DefaultParameterCapability
 }
 
+// Outbound Route Filtering  RFC 5291
+const (
+	ORF_TYPE_ADDRESS_PREFIX       = 64
+	ORF_TYPE_ADDRESS_PREFIX_CISCO = 128
+)
+
+const (
+	ORF_RECEIVE = 1
+	ORF_SEND    = 2
+	ORF_BOTH    = 3
+)
+
+type CapOutboundRouteFilteringTuple struct {
+	ORFType uint8
+	Mode    uint8
+}
+
+type CapOutboundRouteFilteringValue struct {
+	AFI    uint16
+	SAFI   uint8
+	Number uint8
+	Tuples []CapOutboundRouteFilteringTuple
+}
+
+type CapOutboundRouteFiltering struct {
+	DefaultParameterCapability
+	CapValue []CapOutboundRouteFilteringValue
+}
+
+func (c *CapOutboundRouteFiltering) DecodeFromBytes(data []byte) error {
+	err := c.DefaultParameterCapability.DecodeFromBytes(data)
+	if err != nil {
+		return err
+	}
+	data = data[2 : 2+c.CapLen]
+	for len(data) >= 5 {
+		v := CapOutboundRouteFilteringValue{}
+		v.AFI = binary.BigEndian.Uint16(data[0:2])
+		v.SAFI = data[3]
+		v.Number = data[4]
+		data = data[5:]
+		if len(data) < 2*int(v.Number) {
+			return fmt.Errorf("Not all CapabilityOutboundRouteFiltering bytes available")
+		}
+		for i := 0; i < int(v.Number); i++ {
+			v.Tuples = append(v.Tuples, CapOutboundRouteFilteringTuple{data[0], data[1]})
+			data = data[2:]
+		}
+		c.CapValue = append(c.CapValue, v)
+	}
+	return nil
+}
+
+func (c *CapOutboundRouteFiltering) Serialize() ([]byte, error) {
+	buf := make([]byte, 2)
+	buf[0] = uint8(c.CapCode)
+	if buf[0] == 0 {
+		buf[0] = BGP_CAP_OUTBOUND_ROUTE_FILTERING
+	}
+	for _, v := range c.CapValue {
+		b := make([]byte, 5)
+		binary.BigEndian.PutUint16(b[0:2], v.AFI)
+		b[3] = v.SAFI
+		b[4] = uint8(len(v.Tuples))
+		for _, t := range v.Tuples {
+			b = append(b, t.ORFType, t.Mode)
+		}
+		buf = append(buf, b...)
+	}
+	if len(buf)-2 > math.MaxUint8 {
+		return nil, fmt.Errorf("too many OutboundRouteFiltering entries")
+	}
+	buf[1] = uint8(len(buf) - 2)
+	return buf, nil
+}
+
+var orfModeNames = map[uint8]string{
+	ORF_RECEIVE: "receive",
+	ORF_SEND:    "send",
+	ORF_BOTH:    "both",
+}
+
+type orfTupleJSON struct {
+	Type uint8  `json:"type"`
+	Mode string `json:"mode"`
+}
+
+type orfCapabilityJSON struct {
+	familyJSON
+	ORFs []orfTupleJSON `json:"orfs"`
+}
+
+func (c *CapOutboundRouteFiltering) MarshalJSON() ([]byte, error) {
+	j := make([]orfCapabilityJSON, len(c.CapValue))
+	for i, v := range c.CapValue {
+		j[i].familyJSON = newFamilyJSON(v.AFI, v.SAFI)
+		j[i].ORFs = make([]orfTupleJSON, len(v.Tuples))
+		for k, t := range v.Tuples {
+			j[i].ORFs[k] = orfTupleJSON{t.ORFType, codeToName(orfModeNames, t.Mode)}
+		}
+	}
+	code := c.CapCode
+	if code == 0 {
+		code = BGP_CAP_OUTBOUND_ROUTE_FILTERING
+	}
+	return c.marshalJSON(code, j)
+}
+
 type CapCarryingLabelInfo struct {
 	DefaultParameterCapability
 }
 
+type CapMultipleLabelsTuple struct {
+	AFI   uint16
+	SAFI  uint8
+	Count uint8
+}
+
+type CapMultipleLabels struct {
+	DefaultParameterCapability
+	CapValue []CapMultipleLabelsTuple
+}
+
+func (c *CapMultipleLabels) DecodeFromBytes(data []byte) error {
+	err := c.DefaultParameterCapability.DecodeFromBytes(data)
+	if err != nil {
+		return err
+	}
+	data = data[2 : 2+c.CapLen]
+	for len(data) >= 4 {
+		t := CapMultipleLabelsTuple{binary.BigEndian.Uint16(data[0:2]),
+			data[2], data[3]}
+		c.CapValue = append(c.CapValue, t)
+		data = data[4:]
+	}
+	return nil
+}
+
+func (c *CapMultipleLabels) Serialize() ([]byte, error) {
+	if 4*len(c.CapValue) > math.MaxUint8 {
+		return nil, fmt.Errorf("too many MultipleLabels entries")
+	}
+	buf := make([]byte, 2+4*len(c.CapValue))
+	buf[0] = BGP_CAP_MULTIPLE_LABELS
+	buf[1] = uint8(4 * len(c.CapValue))
+	for i, t := range c.CapValue {
+		b := buf[2+4*i:]
+		binary.BigEndian.PutUint16(b[0:2], t.AFI)
+		b[2] = t.SAFI
+		b[3] = t.Count
+	}
+	return buf, nil
+}
+
+func (c *CapMultipleLabels) String() string {
+	s := make([]string, len(c.CapValue))
+	for i, t := range c.CapValue {
+		s[i] = fmt.Sprintf("%s:%d", routeFamilyName(t.AFI, t.SAFI), t.Count)
+	}
+	return fmt.Sprintf("MultipleLabels(%s)", strings.Join(s, " "))
+}
+
+type multipleLabelsJSON struct {
+	familyJSON
+	Count uint8 `json:"count"`
+}
+
+func (c *CapMultipleLabels) MarshalJSON() ([]byte, error) {
+	j := make([]multipleLabelsJSON, len(c.CapValue))
+	for i, t := range c.CapValue {
+		j[i] = multipleLabelsJSON{newFamilyJSON(t.AFI, t.SAFI), t.Count}
+	}
+	return c.marshalJSON(BGP_CAP_MULTIPLE_LABELS, j)
+}
+
+// LabelCount returns the number of labels the speaker can process for
+// the family, or 1 if the capability does not mention it.
+func (c *CapMultipleLabels) LabelCount(afi uint16, safi uint8) int {
+	for _, t := range c.CapValue {
+		if t.AFI == afi && t.SAFI == safi {
+			return int(t.Count)
+		}
+	}
+	return 1
+}
+
 type CapGracefulRestartTuples struct {
 	AFI   uint16
 	SAFI  uint8
 	Flags uint8
 }
 
+// Graceful Restart flags  RFC 4724 3, RFC 8538 2
+const (
+	BGP_GRACEFUL_RESTART_FLAG_RESTART      = 0x8
+	BGP_GRACEFUL_RESTART_FLAG_NOTIFICATION = 0x4
+)
+
+const BGP_GRACEFUL_RESTART_FAMILY_FLAG_FORWARDING = 0x80
+
 type CapGracefulRestartValue struct {
 	Flags  uint8
 	Time   uint16
 	Tuples []CapGracefulRestartTuples
 }
 
+// Restarting reports whether the sender has restarted, the R bit.
+func (v *CapGracefulRestartValue) Restarting() bool {
+	return v.Flags&BGP_GRACEFUL_RESTART_FLAG_RESTART != 0
+}
+
+// Notification reports whether the sender also restarts gracefully
+// after a NOTIFICATION, the N bit.
+func (v *CapGracefulRestartValue) Notification() bool {
+	return v.Flags&BGP_GRACEFUL_RESTART_FLAG_NOTIFICATION != 0
+}
+
 type CapGracefulRestart struct {
 	DefaultParameterCapability
 	CapValue CapGracefulRestartValue
 }
 
 func (c *CapGracefulRestart) DecodeFromBytes(data []byte) error {
-	c.DefaultParameterCapability.DecodeFromBytes(data)
-	data = data[2:]
+	err := c.DefaultParameterCapability.DecodeFromBytes(data)
+	if err != nil {
+		return err
+	}
+	data = data[2 : 2+c.CapLen]
+	if len(data) < 2 {
+		return fmt.Errorf("Not all Graceful Restart capability bytes available")
+	}
 	restart := binary.BigEndian.Uint16(data[0:2])
 	c.CapValue.Flags = uint8(restart >> 12)
 	c.CapValue.Time = restart & 0xfff
@@ -176,6 +567,40 @@ func (c *CapGracefulRestart) DecodeFromBytes(data []byte) error {
 	return nil
 }
 
+func (c *CapGracefulRestart) String() string {
+	s := []string{fmt.Sprintf("time:%d", c.CapValue.Time)}
+	if c.CapValue.Restarting() {
+		s = append(s, "restart")
+	}
+	if c.CapValue.Notification() {
+		s = append(s, "notification")
+	}
+	for _, t := range c.CapValue.Tuples {
+		s = append(s, routeFamilyName(t.AFI, t.SAFI))
+	}
+	return fmt.Sprintf("GracefulRestart(%s)", strings.Join(s, " "))
+}
+
+type gracefulRestartFamilyJSON struct {
+	familyJSON
+	Flags uint8 `json:"flags"`
+}
+
+type gracefulRestartJSON struct {
+	Flags    uint8                       `json:"flags"`
+	Time     uint16                      `json:"time"`
+	Families []gracefulRestartFamilyJSON `json:"families"`
+}
+
+func (c *CapGracefulRestart) MarshalJSON() ([]byte, error) {
+	j := gracefulRestartJSON{Flags: c.CapValue.Flags, Time: c.CapValue.Time}
+	j.Families = make([]gracefulRestartFamilyJSON, len(c.CapValue.Tuples))
+	for i, t := range c.CapValue.Tuples {
+		j.Families[i] = gracefulRestartFamilyJSON{newFamilyJSON(t.AFI, t.SAFI), t.Flags}
+	}
+	return c.marshalJSON(BGP_CAP_GRACEFUL_RESTART, j)
+}
+
 type CapFourOctetASNumber struct {
 	DefaultParameterCapability
 	CapValue uint32
@@ -191,6 +616,82 @@ func (c *CapFourOctetASNumber) DecodeFromBytes(data []byte) error {
 	return nil
 }
 
+func (c *CapFourOctetASNumber) String() string {
+	return fmt.Sprintf("FourOctetASNumber(%d)", c.CapValue)
+}
+
+func (c *CapFourOctetASNumber) MarshalJSON() ([]byte, error) {
+	return c.marshalJSON(BGP_CAP_FOUR_OCTET_AS_NUMBER, c.CapValue)
+}
+
+const (
+	BGP_ADD_PATH_NONE = iota
+	BGP_ADD_PATH_RECEIVE
+	BGP_ADD_PATH_SEND
+	BGP_ADD_PATH_BOTH
+)
+
+type CapAddPathTuple struct {
+	AFI  uint16
+	SAFI uint8
+	Mode uint8
+}
+
+type CapAddPath struct {
+	DefaultParameterCapability
+	CapValue []CapAddPathTuple
+}
+
+func (c *CapAddPath) DecodeFromBytes(data []byte) error {
+	err := c.DefaultParameterCapability.DecodeFromBytes(data)
+	if err != nil {
+		return err
+	}
+	data = data[2 : 2+c.CapLen]
+	for len(data) >= 4 {
+		t := CapAddPathTuple{binary.BigEndian.Uint16(data[0:2]),
+			data[2], data[3]}
+		c.CapValue = append(c.CapValue, t)
+		data = data[4:]
+	}
+	return nil
+}
+
+var addPathModeNames = map[uint8]string{
+	BGP_ADD_PATH_RECEIVE: "receive",
+	BGP_ADD_PATH_SEND:    "send",
+	BGP_ADD_PATH_BOTH:    "both",
+}
+
+func (c *CapAddPath) String() string {
+	s := make([]string, len(c.CapValue))
+	for i, t := range c.CapValue {
+		mode, ok := addPathModeNames[t.Mode]
+		if !ok {
+			mode = strconv.Itoa(int(t.Mode))
+		}
+		s[i] = fmt.Sprintf("%s:%s", routeFamilyName(t.AFI, t.SAFI), mode)
+	}
+	return fmt.Sprintf("AddPath(%s)", strings.Join(s, " "))
+}
+
+type addPathJSON struct {
+	familyJSON
+	Mode string `json:"mode"`
+}
+
+func (c *CapAddPath) MarshalJSON() ([]byte, error) {
+	j := make([]addPathJSON, len(c.CapValue))
+	for i, t := range c.CapValue {
+		j[i] = addPathJSON{newFamilyJSON(t.AFI, t.SAFI), codeToName(addPathModeNames, t.Mode)}
+	}
+	return c.marshalJSON(BGP_CAP_ADD_PATH, j)
+}
+
+type CapExtendedMessage struct {
+	DefaultParameterCapability
+}
+
 type CapEnhancedRouteRefresh struct {
 	DefaultParameterCapability
 }
@@ -203,6 +704,10 @@ type CapUnknown struct {
 	DefaultParameterCapability
 }
 
+func (c *CapUnknown) String() string {
+	return fmt.Sprintf("Unknown(%d)", c.CapCode)
+}
+
 type OptionParameterInterface interface {
 }
 
@@ -229,12 +734,20 @@ func (o *OptionParameterCapability) DecodeFromBytes(data []byte) error {
 			c = &CapMultiProtocol{}
 		case BGP_CAP_ROUTE_REFRESH:
 			c = &CapRouteRefresh{}
+		case BGP_CAP_OUTBOUND_ROUTE_FILTERING, BGP_CAP_OUTBOUND_ROUTE_FILTERING_CISCO:
+			c = &CapOutboundRouteFiltering{}
 		case BGP_CAP_CARRYING_LABEL_INFO:
 			c = &CapCarryingLabelInfo{}
+		case BGP_CAP_EXTENDED_MESSAGE:
+			c = &CapExtendedMessage{}
+		case BGP_CAP_MULTIPLE_LABELS:
+			c = &CapMultipleLabels{}
 		case BGP_CAP_GRACEFUL_RESTART:
 			c = &CapGracefulRestart{}
 		case BGP_CAP_FOUR_OCTET_AS_NUMBER:
 			c = &CapFourOctetASNumber{}
+		case BGP_CAP_ADD_PATH:
+			c = &CapAddPath{}
 		case BGP_CAP_ENHANCED_ROUTE_REFRESH:
 			c = &CapEnhancedRouteRefresh{}
 		case BGP_CAP_ROUTE_REFRESH_CISCO:
@@ -295,80 +808,508 @@ func (msg *BGPOpen) DecodeFromBytes(data []byte) error {
 	return nil
 }
 
-type AddrPrefixInterface interface {
-	DecodeFromBytes([]byte) error
-	Len() int
-}
-
-type IPAddrPrefixDefault struct {
-	Length uint8
-	Prefix net.IP
+func (msg *BGPOpen) String() string {
+	var params []string
+	for _, p := range msg.OptParams {
+		switch o := p.(type) {
+		case OptionParameterCapability:
+			for _, c := range o.Capability {
+				params = append(params, c.String())
+			}
+		case OptionParameterUnknown:
+			params = append(params, fmt.Sprintf("OptionalParameter(%d)", o.ParamType))
+		}
+	}
+	return fmt.Sprintf("OPEN: version %d, AS %d, hold time %d, ID %s, capabilities [%s]",
+		msg.Version, msg.MyAS, msg.HoldTime, msg.ID, strings.Join(params, " "))
 }
 
-func (r *IPAddrPrefixDefault) decodePrefix(data []byte, bitlen uint8, addrlen uint8) error {
-	bytelen := (bitlen + 7) / 8
-	b := make([]byte, addrlen)
-	copy(b, data[:bytelen])
-	r.Prefix = b
-	return nil
+type bgpOpenJSON struct {
+	Version      uint8                 `json:"version"`
+	AS           uint16                `json:"as"`
+	HoldTime     uint16                `json:"hold_time"`
+	ID           string                `json:"id"`
+	Capabilities []json.RawMessage     `json:"capabilities"`
+	Parameters   []optionParameterJSON `json:"parameters,omitempty"`
 }
 
-func (r *IPAddrPrefixDefault) Len() int {
-	return int(1 + ((r.Length + 7) / 8))
+// optionParameterJSON is an optional parameter other than the
+// capabilities, with its value in hex.
+type optionParameterJSON struct {
+	Type  uint8  `json:"type"`
+	Value string `json:"value"`
 }
 
-type IPAddrPrefix struct {
-	IPAddrPrefixDefault
-	addrlen uint8
+func (msg *BGPOpen) MarshalJSON() ([]byte, error) {
+	j := bgpOpenJSON{
+		Version:      msg.Version,
+		AS:           msg.MyAS,
+		HoldTime:     msg.HoldTime,
+		ID:           msg.ID.String(),
+		Capabilities: []json.RawMessage{},
+	}
+	for _, c := range msg.capabilities() {
+		b, err := json.Marshal(c)
+		if err != nil {
+			return nil, err
+		}
+		j.Capabilities = append(j.Capabilities, b)
+	}
+	for _, p := range msg.OptParams {
+		if o, ok := p.(OptionParameterUnknown); ok {
+			j.Parameters = append(j.Parameters, optionParameterJSON{o.ParamType, fmt.Sprintf("%x", o.Value)})
+		}
+	}
+	return json.Marshal(j)
 }
 
-func (r *IPAddrPrefix) DecodeFromBytes(data []byte) error {
-	r.Length = data[0]
-	if r.addrlen == 0 {
-		r.addrlen = 4
+// UnmarshalJSON puts every capability in an optional parameter of its
+// own, after which come the other optional parameters.
+func (msg *BGPOpen) UnmarshalJSON(data []byte) error {
+	var j bgpOpenJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
 	}
-	r.decodePrefix(data[1:], r.Length, r.addrlen)
+	id := net.ParseIP(j.ID).To4()
+	if id == nil {
+		return fmt.Errorf("invalid BGP identifier %q", j.ID)
+	}
+	*msg = BGPOpen{Version: j.Version, MyAS: j.AS, HoldTime: j.HoldTime, ID: id}
+	optlen := 0
+	for _, b := range j.Capabilities {
+		c, err := decodeCapabilityJSON(b)
+		if err != nil {
+			return err
+		}
+		p := OptionParameterCapability{
+			ParamType:  BGP_OPT_CAPABILITY,
+			ParamLen:   uint8(c.Len()),
+			Capability: []ParameterCapabilityInterface{c},
+		}
+		msg.OptParams = append(msg.OptParams, p)
+		optlen += 2 + int(p.ParamLen)
+	}
+	for _, o := range j.Parameters {
+		value, err := hex.DecodeString(o.Value)
+		if err != nil {
+			return err
+		}
+		if len(value) > math.MaxUint8 {
+			return fmt.Errorf("optional parameter %d is too long", o.Type)
+		}
+		p := OptionParameterUnknown{ParamType: o.Type, ParamLen: uint8(len(value)), Value: value}
+		msg.OptParams = append(msg.OptParams, p)
+		optlen += 2 + len(value)
+	}
+	if optlen > math.MaxUint8 {
+		return fmt.Errorf("too many optional parameters")
+	}
+	msg.OptParamLen = uint8(optlen)
 	return nil
 }
 
-type IPv6AddrPrefix struct {
-	IPAddrPrefix
-}
-
-func NewIPv6AddrPrefix() *IPv6AddrPrefix {
-	p := &IPv6AddrPrefix{}
-	p.addrlen = 16
-	return p
-}
-
-type WithdrawnRoute struct {
-	IPAddrPrefix
+func (msg *BGPOpen) capabilities() []ParameterCapabilityInterface {
+	var caps []ParameterCapabilityInterface
+	for _, p := range msg.OptParams {
+		if o, ok := p.(OptionParameterCapability); ok {
+			caps = append(caps, o.Capability...)
+		}
+	}
+	return caps
 }
 
 const (
-	BGP_RD_TWO_OCTET_AS = iota
-	BGP_RD_IPV4_ADDRESS
-	BGP_RD_FOUR_OCTET_AS
+	BGP_MAX_MESSAGE_LENGTH          = 4096
+	BGP_MAX_EXTENDED_MESSAGE_LENGTH = 65535
+)
+
+// SessionFeatures is what two speakers agreed on in their OPEN
+// messages, seen from the local side. It tells how the messages that
+// the remote side sends afterwards have to be decoded.
+type SessionFeatures struct {
+	Families            map[int]bool
+	FourOctetAS         bool
+	PeerAS              uint32
+	AddPath             map[int]uint8
+	GracefulRestart     bool
+	GracefulRestartTime uint16
+	// GracefulRestartNotification is set when both sides sent the N bit,
+	// so that graceful restart also covers NOTIFICATION messages.
+	GracefulRestartNotification bool
+	RouteRefresh                bool
+	EnhancedRouteRefresh        bool
+	ExtendedMessage             bool
+	// MultipleLabels is the number of labels the local side can process
+	// for a family, as it advertised them. Families that are not in the
+	// map take a single label.
+	MultipleLabels map[int]int
+}
+
+func findCapability(caps []ParameterCapabilityInterface, codes ...BGPCapabilityCode) ParameterCapabilityInterface {
+	for _, c := range caps {
+		for _, code := range codes {
+			if c.Code() == code {
+				return c
+			}
+		}
+	}
+	return nil
+}
+
+func openFamilies(caps []ParameterCapabilityInterface) map[int]bool {
+	families := make(map[int]bool)
+	for _, c := range caps {
+		if m, ok := c.(*CapMultiProtocol); ok {
+			families[rfshift(m.CapValue.AFI, m.CapValue.SAFI)] = true
+		}
+	}
+	// RFC 4760 8: a speaker without the capability only does IPv4 unicast
+	if len(families) == 0 {
+		families[RF_IPv4_UC] = true
+	}
+	return families
+}
+
+func openAddPath(caps []ParameterCapabilityInterface) map[int]uint8 {
+	modes := make(map[int]uint8)
+	for _, c := range caps {
+		if a, ok := c.(*CapAddPath); ok {
+			for _, t := range a.CapValue {
+				modes[rfshift(t.AFI, t.SAFI)] = t.Mode
+			}
+		}
+	}
+	return modes
+}
+
+func openMultipleLabels(caps []ParameterCapabilityInterface) map[int]int {
+	counts := make(map[int]int)
+	for _, c := range caps {
+		if m, ok := c.(*CapMultipleLabels); ok {
+			for _, t := range m.CapValue {
+				counts[rfshift(t.AFI, t.SAFI)] = m.LabelCount(t.AFI, t.SAFI)
+			}
+		}
+	}
+	return counts
+}
+
+// NegotiateSessionFeatures computes the features of a session from the
+// OPEN message the local speaker sent and the one it received.
+func NegotiateSessionFeatures(local, remote *BGPOpen) *SessionFeatures {
+	f := &SessionFeatures{
+		Families:       make(map[int]bool),
+		AddPath:        make(map[int]uint8),
+		PeerAS:         uint32(remote.MyAS),
+		MultipleLabels: make(map[int]int),
+	}
+	lcaps := local.capabilities()
+	rcaps := remote.capabilities()
+
+	rfamilies := openFamilies(rcaps)
+	for rf := range openFamilies(lcaps) {
+		if rfamilies[rf] {
+			f.Families[rf] = true
+		}
+	}
+
+	lmodes := openAddPath(lcaps)
+	for rf, rmode := range openAddPath(rcaps) {
+		if !f.Families[rf] {
+			continue
+		}
+		lmode := lmodes[rf]
+		var mode uint8
+		if lmode&BGP_ADD_PATH_RECEIVE != 0 && rmode&BGP_ADD_PATH_SEND != 0 {
+			mode |= BGP_ADD_PATH_RECEIVE
+		}
+		if lmode&BGP_ADD_PATH_SEND != 0 && rmode&BGP_ADD_PATH_RECEIVE != 0 {
+			mode |= BGP_ADD_PATH_SEND
+		}
+		if mode != BGP_ADD_PATH_NONE {
+			f.AddPath[rf] = mode
+		}
+	}
+
+	// RFC 8277 2.1: the peer may send as many labels as the local side
+	// said it can process, whatever it advertised itself
+	for rf, count := range openMultipleLabels(lcaps) {
+		if f.Families[rf] && count > 1 {
+			f.MultipleLabels[rf] = count
+		}
+	}
+	if findCapability(lcaps, BGP_CAP_FOUR_OCTET_AS_NUMBER) != nil {
+		if c := findCapability(rcaps, BGP_CAP_FOUR_OCTET_AS_NUMBER); c != nil {
+			f.FourOctetAS = true
+			f.PeerAS = c.(*CapFourOctetASNumber).CapValue
+		}
+	}
+	if l := findCapability(lcaps, BGP_CAP_GRACEFUL_RESTART); l != nil {
+		if r := findCapability(rcaps, BGP_CAP_GRACEFUL_RESTART); r != nil {
+			rv := r.(*CapGracefulRestart).CapValue
+			f.GracefulRestart = true
+			f.GracefulRestartTime = rv.Time
+			f.GracefulRestartNotification = rv.Notification() &&
+				l.(*CapGracefulRestart).CapValue.Notification()
+		}
+	}
+	f.RouteRefresh = findCapability(lcaps, BGP_CAP_ROUTE_REFRESH, BGP_CAP_ROUTE_REFRESH_CISCO) != nil &&
+		findCapability(rcaps, BGP_CAP_ROUTE_REFRESH, BGP_CAP_ROUTE_REFRESH_CISCO) != nil
+	f.EnhancedRouteRefresh = findCapability(lcaps, BGP_CAP_ENHANCED_ROUTE_REFRESH) != nil &&
+		findCapability(rcaps, BGP_CAP_ENHANCED_ROUTE_REFRESH) != nil
+	f.ExtendedMessage = findCapability(lcaps, BGP_CAP_EXTENDED_MESSAGE) != nil &&
+		findCapability(rcaps, BGP_CAP_EXTENDED_MESSAGE) != nil
+	return f
+}
+
+// HasFamily reports whether both sides agreed on the family.
+func (f *SessionFeatures) HasFamily(afi uint16, safi uint8) bool {
+	return f != nil && f.Families[rfshift(afi, safi)]
+}
+
+// ReceiveAddPath reports whether NLRI of the family received from the
+// peer carry a path identifier.
+func (f *SessionFeatures) ReceiveAddPath(afi uint16, safi uint8) bool {
+	return f != nil && f.AddPath[rfshift(afi, safi)]&BGP_ADD_PATH_RECEIVE != 0
+}
+
+// ReceiveLabelCount returns the number of labels a route of the family
+// received from the peer may carry.
+func (f *SessionFeatures) ReceiveLabelCount(afi uint16, safi uint8) int {
+	if f != nil {
+		if count, ok := f.MultipleLabels[rfshift(afi, safi)]; ok {
+			return count
+		}
+	}
+	return 1
+}
+
+// RetainRoutes reports whether the routes of the peer are kept as stale
+// instead of withdrawn when the session goes down with the NOTIFICATION,
+// sent or received. Only a Hard Reset still flushes them  RFC 8538 4
+// A nil msg stands for a session that went down without one, such as on
+// a TCP failure, and retains the routes as well.
+func (f *SessionFeatures) RetainRoutes(msg *BGPNotification) bool {
+	return f != nil && f.GracefulRestart && f.GracefulRestartNotification && !msg.IsHardReset()
+}
+
+func (f *SessionFeatures) maxMessageLength() int {
+	if f == nil || f.ExtendedMessage {
+		return BGP_MAX_EXTENDED_MESSAGE_LENGTH
+	}
+	return BGP_MAX_MESSAGE_LENGTH
+}
+
+type AddrPrefixInterface interface {
+	DecodeFromBytes([]byte) error
+	Serialize() ([]byte, error)
+	Len() int
+	String() string
+}
+
+// IPAddrPrefixDefault holds the fields shared by the IP prefix types.
+// Since its Prefix field keeps the net.IP form, the prefix types that
+// embed it return the netip.Prefix form from NetipPrefix rather than
+// from a Prefix method.
+type IPAddrPrefixDefault struct {
+	Length         uint8
+	Prefix         net.IP
+	PathIdentifier uint32
+}
+
+func (r *IPAddrPrefixDefault) setPathIdentifier(id uint32) {
+	r.PathIdentifier = id
+}
+
+func (r *IPAddrPrefixDefault) pathIdentifier() uint32 {
+	return r.PathIdentifier
+}
+
+// decodePathIdentifier consumes the ADD-PATH path identifier (RFC 7911)
+// in front of a prefix when the session negotiated it for the family.
+func decodePathIdentifier(prefix AddrPrefixInterface, data []byte, addpath bool) ([]byte, error) {
+	if !addpath {
+		return data, nil
+	}
+	if len(data) < 4 {
+		return nil, fmt.Errorf("Not all path identifier bytes available")
+	}
+	if p, ok := prefix.(interface {
+		setPathIdentifier(uint32)
+	}); ok {
+		p.setPathIdentifier(binary.BigEndian.Uint32(data[0:4]))
+	}
+	return data[4:], nil
+}
+
+func (r *IPAddrPrefixDefault) decodePrefix(data []byte, bitlen uint8, addrlen uint8) error {
+	bytelen := (bitlen + 7) / 8
+	b := make([]byte, addrlen)
+	copy(b, data[:bytelen])
+	r.Prefix = b
+	return nil
+}
+
+func (r *IPAddrPrefixDefault) serializePrefix(bitlen int) ([]byte, error) {
+	if bitlen < 0 {
+		return nil, fmt.Errorf("prefix length %d is too short for its labels", r.Length)
+	}
+	bytelen := (bitlen + 7) / 8
+	if len(r.Prefix) < bytelen {
+		return nil, fmt.Errorf("prefix %s is shorter than %d bits", r.Prefix, bitlen)
+	}
+	buf := make([]byte, bytelen)
+	copy(buf, r.Prefix[:bytelen])
+	return buf, nil
+}
+
+// netipPrefix returns the first bitlen bits of Prefix as a netip.Prefix,
+// which is comparable and does not alias the buffer the prefix was
+// decoded from. Host bits a peer left set in the last octet are
+// cleared. The result is invalid if the prefix is malformed.
+func (r *IPAddrPrefixDefault) netipPrefix(bitlen int, addrlen uint8) netip.Prefix {
+	ip := r.Prefix.To16()
+	if addrlen == net.IPv4len {
+		ip = r.Prefix.To4()
+	}
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok || bitlen < 0 || bitlen > 8*int(addrlen) {
+		return netip.Prefix{}
+	}
+	return netip.PrefixFrom(addr, bitlen).Masked()
+}
+
+// setNetipPrefix sets Prefix and Length from p, adding extrabits for
+// the labels and route distinguisher in front of the prefix.
+func (r *IPAddrPrefixDefault) setNetipPrefix(p netip.Prefix, extrabits int, addrlen uint8) error {
+	if !p.IsValid() || p.Addr().Is4() != (addrlen == net.IPv4len) {
+		return fmt.Errorf("invalid prefix %s for a %d octet address family", p, addrlen)
+	}
+	if p.Masked() != p {
+		return fmt.Errorf("prefix %s has host bits set", p)
+	}
+	if p.Bits()+extrabits > math.MaxUint8 {
+		return fmt.Errorf("prefix %s is too long", p)
+	}
+	r.Prefix = net.IP(p.Addr().AsSlice())
+	r.Length = uint8(p.Bits() + extrabits)
+	return nil
+}
+
+func (r *IPAddrPrefixDefault) Len() int {
+	return int(1 + ((r.Length + 7) / 8))
+}
+
+type IPAddrPrefix struct {
+	IPAddrPrefixDefault
+	addrlen uint8
+}
+
+func NewIPAddrPrefixFromNetip(p netip.Prefix) (*IPAddrPrefix, error) {
+	r := &IPAddrPrefix{addrlen: net.IPv4len}
+	if err := r.setNetipPrefix(p, 0, r.addrlen); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *IPAddrPrefix) NetipPrefix() netip.Prefix {
+	addrlen := r.addrlen
+	if addrlen == 0 {
+		addrlen = net.IPv4len
+	}
+	return r.netipPrefix(int(r.Length), addrlen)
+}
+
+func (r *IPAddrPrefix) String() string {
+	return fmt.Sprintf("%s/%d", r.Prefix, r.Length)
+}
+
+func (r *IPAddrPrefix) DecodeFromBytes(data []byte) error {
+	r.Length = data[0]
+	if r.addrlen == 0 {
+		r.addrlen = 4
+	}
+	r.decodePrefix(data[1:], r.Length, r.addrlen)
+	return nil
+}
+
+func (r *IPAddrPrefix) Serialize() ([]byte, error) {
+	buf, err := r.serializePrefix(int(r.Length))
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{r.Length}, buf...), nil
+}
+
+type IPv6AddrPrefix struct {
+	IPAddrPrefix
+}
+
+func NewIPv6AddrPrefix() *IPv6AddrPrefix {
+	p := &IPv6AddrPrefix{}
+	p.addrlen = 16
+	return p
+}
+
+func NewIPv6AddrPrefixFromNetip(p netip.Prefix) (*IPv6AddrPrefix, error) {
+	r := NewIPv6AddrPrefix()
+	if err := r.setNetipPrefix(p, 0, r.addrlen); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+type WithdrawnRoute struct {
+	IPAddrPrefix
+}
+
+const (
+	BGP_RD_TWO_OCTET_AS = iota
+	BGP_RD_IPV4_ADDRESS
+	BGP_RD_FOUR_OCTET_AS
 )
 
 type RouteDistinguisherInterface interface {
 	DecodeFromBytes([]byte) error
+	Serialize() ([]byte, error)
 	Len() int
+	String() string
 }
 
+// DefaultRouteDistinguisher holds the raw type and value of any route
+// distinguisher; the typed ones below keep it filled in alongside their
+// decoded Value.
 type DefaultRouteDistinguisher struct {
 	Type  uint16
 	Value []byte
 }
 
-func (rd DefaultRouteDistinguisher) DecodeFromBytes(data []byte) error {
+func (rd *DefaultRouteDistinguisher) DecodeFromBytes(data []byte) error {
+	if len(data) < 8 {
+		return fmt.Errorf("Not all RouteDistinguisher bytes available")
+	}
 	rd.Type = binary.BigEndian.Uint16(data[0:2])
 	rd.Value = data[2:8]
 	return nil
 }
 
+func (rd *DefaultRouteDistinguisher) Serialize() ([]byte, error) {
+	if len(rd.Value) != 6 {
+		return nil, fmt.Errorf("route distinguisher value must be 6 bytes")
+	}
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint16(buf[0:2], rd.Type)
+	copy(buf[2:], rd.Value)
+	return buf, nil
+}
+
 func (rd *DefaultRouteDistinguisher) Len() int { return 8 }
 
+func (rd *DefaultRouteDistinguisher) String() string {
+	return fmt.Sprintf("%d:%x", rd.Type, rd.Value)
+}
+
 type RouteDistinguisherTwoOctetASValue struct {
 	Admin    uint16
 	Assigned uint32
@@ -379,6 +1320,37 @@ type RouteDistinguisherTwoOctetAS struct {
 	Value RouteDistinguisherTwoOctetASValue
 }
 
+func NewRouteDistinguisherTwoOctetAS(admin uint16, assigned uint32) *RouteDistinguisherTwoOctetAS {
+	value := make([]byte, 6)
+	binary.BigEndian.PutUint16(value[0:2], admin)
+	binary.BigEndian.PutUint32(value[2:6], assigned)
+	return &RouteDistinguisherTwoOctetAS{
+		DefaultRouteDistinguisher: DefaultRouteDistinguisher{Type: BGP_RD_TWO_OCTET_AS, Value: value},
+		Value:                     RouteDistinguisherTwoOctetASValue{admin, assigned},
+	}
+}
+
+func (rd *RouteDistinguisherTwoOctetAS) DecodeFromBytes(data []byte) error {
+	if err := rd.DefaultRouteDistinguisher.DecodeFromBytes(data); err != nil {
+		return err
+	}
+	rd.Value.Admin = binary.BigEndian.Uint16(data[2:4])
+	rd.Value.Assigned = binary.BigEndian.Uint32(data[4:8])
+	return nil
+}
+
+func (rd *RouteDistinguisherTwoOctetAS) Serialize() ([]byte, error) {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint16(buf[0:2], BGP_RD_TWO_OCTET_AS)
+	binary.BigEndian.PutUint16(buf[2:4], rd.Value.Admin)
+	binary.BigEndian.PutUint32(buf[4:8], rd.Value.Assigned)
+	return buf, nil
+}
+
+func (rd *RouteDistinguisherTwoOctetAS) String() string {
+	return fmt.Sprintf("%d:%d", rd.Value.Admin, rd.Value.Assigned)
+}
+
 type RouteDistinguisherIPAddressASValue struct {
 	Admin    net.IP
 	Assigned uint16
@@ -389,6 +1361,41 @@ type RouteDistinguisherIPAddressAS struct {
 	Value RouteDistinguisherIPAddressASValue
 }
 
+func NewRouteDistinguisherIPAddressAS(admin net.IP, assigned uint16) *RouteDistinguisherIPAddressAS {
+	value := make([]byte, 6)
+	copy(value[0:4], admin.To4())
+	binary.BigEndian.PutUint16(value[4:6], assigned)
+	return &RouteDistinguisherIPAddressAS{
+		DefaultRouteDistinguisher: DefaultRouteDistinguisher{Type: BGP_RD_IPV4_ADDRESS, Value: value},
+		Value:                     RouteDistinguisherIPAddressASValue{admin, assigned},
+	}
+}
+
+func (rd *RouteDistinguisherIPAddressAS) DecodeFromBytes(data []byte) error {
+	if err := rd.DefaultRouteDistinguisher.DecodeFromBytes(data); err != nil {
+		return err
+	}
+	rd.Value.Admin = net.IP(data[2:6])
+	rd.Value.Assigned = binary.BigEndian.Uint16(data[6:8])
+	return nil
+}
+
+func (rd *RouteDistinguisherIPAddressAS) Serialize() ([]byte, error) {
+	ip := rd.Value.Admin.To4()
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IPv4 address in route distinguisher: %s", rd.Value.Admin)
+	}
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint16(buf[0:2], BGP_RD_IPV4_ADDRESS)
+	copy(buf[2:6], ip)
+	binary.BigEndian.PutUint16(buf[6:8], rd.Value.Assigned)
+	return buf, nil
+}
+
+func (rd *RouteDistinguisherIPAddressAS) String() string {
+	return fmt.Sprintf("%s:%d", rd.Value.Admin, rd.Value.Assigned)
+}
+
 type RouteDistinguisherFourOctetASValue struct {
 	Admin    uint32
 	Assigned uint16
@@ -399,41 +1406,155 @@ type RouteDistinguisherFourOctetAS struct {
 	Value RouteDistinguisherFourOctetASValue
 }
 
+func NewRouteDistinguisherFourOctetAS(admin uint32, assigned uint16) *RouteDistinguisherFourOctetAS {
+	value := make([]byte, 6)
+	binary.BigEndian.PutUint32(value[0:4], admin)
+	binary.BigEndian.PutUint16(value[4:6], assigned)
+	return &RouteDistinguisherFourOctetAS{
+		DefaultRouteDistinguisher: DefaultRouteDistinguisher{Type: BGP_RD_FOUR_OCTET_AS, Value: value},
+		Value:                     RouteDistinguisherFourOctetASValue{admin, assigned},
+	}
+}
+
+func (rd *RouteDistinguisherFourOctetAS) DecodeFromBytes(data []byte) error {
+	if err := rd.DefaultRouteDistinguisher.DecodeFromBytes(data); err != nil {
+		return err
+	}
+	rd.Value.Admin = binary.BigEndian.Uint32(data[2:6])
+	rd.Value.Assigned = binary.BigEndian.Uint16(data[6:8])
+	return nil
+}
+
+func (rd *RouteDistinguisherFourOctetAS) Serialize() ([]byte, error) {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint16(buf[0:2], BGP_RD_FOUR_OCTET_AS)
+	binary.BigEndian.PutUint32(buf[2:6], rd.Value.Admin)
+	binary.BigEndian.PutUint16(buf[6:8], rd.Value.Assigned)
+	return buf, nil
+}
+
+// String marks an AS that would fit in two octets with an "L" suffix,
+// as the four octet AS specific extended communities do.
+func (rd *RouteDistinguisherFourOctetAS) String() string {
+	if rd.Value.Admin <= math.MaxUint16 {
+		return fmt.Sprintf("%dL:%d", rd.Value.Admin, rd.Value.Assigned)
+	}
+	return fmt.Sprintf("%d:%d", rd.Value.Admin, rd.Value.Assigned)
+}
+
 type RouteDistinguisherUnknown struct {
 	DefaultRouteDistinguisher
 }
 
 func getRouteDistinguisher(data []byte) RouteDistinguisherInterface {
+	var rd RouteDistinguisherInterface
 	switch binary.BigEndian.Uint16(data[0:2]) {
 	case BGP_RD_TWO_OCTET_AS:
-		rd := &RouteDistinguisherTwoOctetAS{}
-		rd.Value.Admin = binary.BigEndian.Uint16(data[2:4])
-		rd.Value.Assigned = binary.BigEndian.Uint32(data[4:8])
-		return rd
+		rd = &RouteDistinguisherTwoOctetAS{}
 	case BGP_RD_IPV4_ADDRESS:
-		rd := &RouteDistinguisherIPAddressAS{}
-		rd.Value.Admin = data[2:6]
-		rd.Value.Assigned = binary.BigEndian.Uint16(data[6:8])
-		return rd
+		rd = &RouteDistinguisherIPAddressAS{}
 	case BGP_RD_FOUR_OCTET_AS:
-		rd := &RouteDistinguisherFourOctetAS{}
-		rd.Value.Admin = binary.BigEndian.Uint32(data[2:6])
-		rd.Value.Assigned = binary.BigEndian.Uint16(data[6:8])
-		return rd
+		rd = &RouteDistinguisherFourOctetAS{}
+	default:
+		rd = &RouteDistinguisherUnknown{}
+	}
+	rd.DecodeFromBytes(data)
+	return rd
+}
+
+// ParseRouteDistinguisher parses the String form of a route
+// distinguisher: "65000:100", "10.0.0.1:5" or "4200000000:7".
+func ParseRouteDistinguisher(value string) (RouteDistinguisherInterface, error) {
+	i := strings.LastIndex(value, ":")
+	if i < 0 {
+		return nil, fmt.Errorf("invalid route distinguisher format: %s", value)
 	}
+	admin, assigned := value[:i], value[i+1:]
+	if ip := net.ParseIP(admin).To4(); ip != nil {
+		v, err := strconv.ParseUint(assigned, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid route distinguisher format: %s", value)
+		}
+		return NewRouteDistinguisherIPAddressAS(ip, uint16(v)), nil
+	}
+	fourOctet := strings.HasSuffix(admin, "L")
+	as, err := strconv.ParseUint(strings.TrimSuffix(admin, "L"), 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid route distinguisher format: %s", value)
+	}
+	v, err := strconv.ParseUint(assigned, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid route distinguisher format: %s", value)
+	}
+	if fourOctet || as > math.MaxUint16 {
+		if v > math.MaxUint16 {
+			return nil, fmt.Errorf("invalid route distinguisher format: %s", value)
+		}
+		return NewRouteDistinguisherFourOctetAS(uint32(as), uint16(v)), nil
+	}
+	return NewRouteDistinguisherTwoOctetAS(uint16(as), uint32(v)), nil
+}
+
+// RouteDistinguisherKey returns the wire encoding of rd as a comparable
+// value, to index VRF tables by route distinguisher.
+func RouteDistinguisherKey(rd RouteDistinguisherInterface) (uint64, error) {
+	buf, err := rd.Serialize()
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(buf), nil
+}
 
-	return &RouteDistinguisherUnknown{}
+// EqualRouteDistinguisher reports whether a and b encode to the same
+// route distinguisher.
+func EqualRouteDistinguisher(a, b RouteDistinguisherInterface) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	ka, err := RouteDistinguisherKey(a)
+	if err != nil {
+		return false
+	}
+	kb, err := RouteDistinguisherKey(b)
+	return err == nil && ka == kb
 }
 
+// Label field value of a withdrawn labelled route  RFC 8277 2.4
+const (
+	MPLS_LABEL_WITHDRAW = 0x800000
+	MPLS_LABEL_MAX      = 1<<20 - 1
+)
+
 type Label struct {
 	Labels []uint32
+	// Withdraw is set for the label field of a withdrawn route, which
+	// holds no label stack. It is only decoded so from MP_UNREACH_NLRI,
+	// any label value is possible in a reachable route.
+	Withdraw bool
+}
+
+func NewWithdrawLabel() *Label {
+	return &Label{Withdraw: true}
+}
+
+func (l *Label) IsWithdraw() bool {
+	return l.Withdraw
 }
 
+// DecodeFromBytes reads a label stack, or the single label field of a
+// withdrawn route when Withdraw is set beforehand.
 func (l *Label) DecodeFromBytes(data []byte) error {
+	if l.Withdraw {
+		if len(data) < 3 {
+			return fmt.Errorf("Not all Label bytes available")
+		}
+		l.Labels = nil
+		return nil
+	}
 	labels := []uint32{}
 	foundBottom := false
-	for len(data) >= 4 {
-		label := uint32(data[0]<<16 | data[1]<<8 | data[2])
+	for len(data) >= 3 {
+		label := uint32(data[0])<<16 | uint32(data[1])<<8 | uint32(data[2])
 		data = data[3:]
 		labels = append(labels, label>>4)
 		if label&1 == 1 {
@@ -449,7 +1570,49 @@ func (l *Label) DecodeFromBytes(data []byte) error {
 	return nil
 }
 
-func (l *Label) Len() int { return 3 * len(l.Labels) }
+func (l *Label) Serialize() ([]byte, error) {
+	if l.IsWithdraw() {
+		return []byte{MPLS_LABEL_WITHDRAW >> 16, 0, 0}, nil
+	}
+	buf := make([]byte, 3*len(l.Labels))
+	for i, v := range l.Labels {
+		if v > MPLS_LABEL_MAX {
+			return nil, fmt.Errorf("label %d is out of range", v)
+		}
+		label := v << 4
+		if i == len(l.Labels)-1 {
+			label |= 1
+		}
+		buf[3*i] = byte(label >> 16)
+		buf[3*i+1] = byte(label >> 8)
+		buf[3*i+2] = byte(label)
+	}
+	return buf, nil
+}
+
+func (l *Label) Len() int {
+	if l.Withdraw {
+		return 3
+	}
+	return 3 * len(l.Labels)
+}
+
+func (l *Label) String() string {
+	if l.IsWithdraw() {
+		return "withdraw"
+	}
+	s := make([]string, len(l.Labels))
+	for i, v := range l.Labels {
+		s[i] = strconv.FormatUint(uint64(v), 10)
+	}
+	return strings.Join(s, "/")
+}
+
+// labelledPrefix is a route that carries a label stack, which has to
+// fit the label count negotiated for its family  RFC 8277 2.1
+type labelledPrefix interface {
+	labelCount() int
+}
 
 type LabelledVPNIPAddrPrefix struct {
 	IPAddrPrefixDefault
@@ -458,36 +1621,113 @@ type LabelledVPNIPAddrPrefix struct {
 	addrlen uint8
 }
 
+func (l *LabelledVPNIPAddrPrefix) labelCount() int {
+	return len(l.Labels.Labels)
+}
+
 func (l *LabelledVPNIPAddrPrefix) DecodeFromBytes(data []byte) error {
+	if len(data) < 1 {
+		return fmt.Errorf("Not all LabelledVPNIPAddrPrefix bytes available")
+	}
 	l.Length = uint8(data[0])
 	data = data[1:]
-	l.Labels.DecodeFromBytes(data)
+	err := l.Labels.DecodeFromBytes(data)
+	if err != nil {
+		return err
+	}
 	if int(l.Length)-8*(l.Labels.Len()) < 0 {
-		l.Labels.Labels = []uint32{}
+		l.Labels = Label{Labels: []uint32{}}
 	}
 	data = data[l.Labels.Len():]
+	if len(data) < 8 {
+		return fmt.Errorf("Not all LabelledVPNIPAddrPrefix bytes available")
+	}
 	l.RD = getRouteDistinguisher(data)
 	data = data[l.RD.Len():]
 	restbits := int(l.Length) - 8*(l.Labels.Len()+l.RD.Len())
+	if restbits < 0 || restbits > 8*int(l.addrlen) {
+		return fmt.Errorf("invalid LabelledVPNIPAddrPrefix length %d", l.Length)
+	}
+	if len(data) < (restbits+7)/8 {
+		return fmt.Errorf("Not all LabelledVPNIPAddrPrefix bytes available")
+	}
 	l.decodePrefix(data, uint8(restbits), l.addrlen)
 	return nil
 }
 
-func NewLabelledVPNIPAddrPrefix() *LabelledVPNIPAddrPrefix {
-	p := &LabelledVPNIPAddrPrefix{}
-	p.addrlen = 4
-	return p
-}
-
-type LabelledVPNIPv6AddrPrefix struct {
-	LabelledVPNIPAddrPrefix
+func (l *LabelledVPNIPAddrPrefix) setWithdraw() {
+	l.Labels.Withdraw = true
 }
 
-func NewLabelledVPNIPv6AddrPrefix() *LabelledVPNIPv6AddrPrefix {
-	p := &LabelledVPNIPv6AddrPrefix{}
-	p.addrlen = 16
-	return p
-}
+func (l *LabelledVPNIPAddrPrefix) Serialize() ([]byte, error) {
+	buf := []byte{l.Length}
+	lbuf, err := l.Labels.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	buf = append(buf, lbuf...)
+	if l.RD == nil {
+		return nil, fmt.Errorf("LabelledVPNIPAddrPrefix has no route distinguisher")
+	}
+	rbuf, err := l.RD.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	buf = append(buf, rbuf...)
+	restbits := int(l.Length) - 8*(l.Labels.Len()+l.RD.Len())
+	pbuf, err := l.serializePrefix(restbits)
+	if err != nil {
+		return nil, err
+	}
+	return append(buf, pbuf...), nil
+}
+
+func (l *LabelledVPNIPAddrPrefix) String() string {
+	bitlen := int(l.Length) - 8*(l.Labels.Len()+8)
+	return fmt.Sprintf("%s:%s/%d label %s", l.RD, l.Prefix, bitlen, &l.Labels)
+}
+
+func NewLabelledVPNIPAddrPrefix() *LabelledVPNIPAddrPrefix {
+	p := &LabelledVPNIPAddrPrefix{}
+	p.addrlen = 4
+	return p
+}
+
+func newLabelledVPNIPAddrPrefixFromNetip(r *LabelledVPNIPAddrPrefix, p netip.Prefix, labels []uint32, rd RouteDistinguisherInterface) error {
+	r.Labels.Labels = labels
+	r.RD = rd
+	return r.setNetipPrefix(p, 8*(r.Labels.Len()+8), r.addrlen)
+}
+
+func NewLabelledVPNIPAddrPrefixFromNetip(p netip.Prefix, labels []uint32, rd RouteDistinguisherInterface) (*LabelledVPNIPAddrPrefix, error) {
+	r := NewLabelledVPNIPAddrPrefix()
+	if err := newLabelledVPNIPAddrPrefixFromNetip(r, p, labels, rd); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (l *LabelledVPNIPAddrPrefix) NetipPrefix() netip.Prefix {
+	return l.netipPrefix(int(l.Length)-8*(l.Labels.Len()+8), l.addrlen)
+}
+
+type LabelledVPNIPv6AddrPrefix struct {
+	LabelledVPNIPAddrPrefix
+}
+
+func NewLabelledVPNIPv6AddrPrefix() *LabelledVPNIPv6AddrPrefix {
+	p := &LabelledVPNIPv6AddrPrefix{}
+	p.addrlen = 16
+	return p
+}
+
+func NewLabelledVPNIPv6AddrPrefixFromNetip(p netip.Prefix, labels []uint32, rd RouteDistinguisherInterface) (*LabelledVPNIPv6AddrPrefix, error) {
+	r := NewLabelledVPNIPv6AddrPrefix()
+	if err := newLabelledVPNIPAddrPrefixFromNetip(&r.LabelledVPNIPAddrPrefix, p, labels, rd); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
 
 type LabelledIPAddrPrefix struct {
 	IPAddrPrefixDefault
@@ -495,6 +1735,10 @@ type LabelledIPAddrPrefix struct {
 	addrlen uint8
 }
 
+func (l *LabelledIPAddrPrefix) labelCount() int {
+	return len(l.Labels.Labels)
+}
+
 func (r *IPAddrPrefix) decodeNextHop(data []byte) net.IP {
 	if r.addrlen == 0 {
 		r.addrlen = 4
@@ -515,24 +1759,72 @@ func (r *LabelledIPAddrPrefix) decodeNextHop(data []byte) net.IP {
 }
 
 func (l *LabelledIPAddrPrefix) DecodeFromBytes(data []byte) error {
+	if len(data) < 1 {
+		return fmt.Errorf("Not all LabelledIPAddrPrefix bytes available")
+	}
 	l.Length = uint8(data[0])
 	data = data[1:]
-	l.Labels.DecodeFromBytes(data)
+	err := l.Labels.DecodeFromBytes(data)
+	if err != nil {
+		return err
+	}
 	if int(l.Length)-8*(l.Labels.Len()) < 0 {
-		l.Labels.Labels = []uint32{}
+		l.Labels = Label{Labels: []uint32{}}
 	}
 	restbits := int(l.Length) - 8*(l.Labels.Len())
+	if restbits < 0 || restbits > 8*int(l.addrlen) {
+		return fmt.Errorf("invalid LabelledIPAddrPrefix length %d", l.Length)
+	}
 	data = data[l.Labels.Len():]
+	if len(data) < (restbits+7)/8 {
+		return fmt.Errorf("Not all LabelledIPAddrPrefix bytes available")
+	}
 	l.decodePrefix(data, uint8(restbits), l.addrlen)
 	return nil
 }
 
+func (l *LabelledIPAddrPrefix) setWithdraw() {
+	l.Labels.Withdraw = true
+}
+
+func (l *LabelledIPAddrPrefix) Serialize() ([]byte, error) {
+	buf := []byte{l.Length}
+	lbuf, err := l.Labels.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	buf = append(buf, lbuf...)
+	pbuf, err := l.serializePrefix(int(l.Length) - 8*l.Labels.Len())
+	if err != nil {
+		return nil, err
+	}
+	return append(buf, pbuf...), nil
+}
+
+func (l *LabelledIPAddrPrefix) String() string {
+	bitlen := int(l.Length) - 8*l.Labels.Len()
+	return fmt.Sprintf("%s/%d label %s", l.Prefix, bitlen, &l.Labels)
+}
+
 func NewLabelledIPAddrPrefix() *LabelledIPAddrPrefix {
 	p := &LabelledIPAddrPrefix{}
 	p.addrlen = 4
 	return p
 }
 
+func NewLabelledIPAddrPrefixFromNetip(p netip.Prefix, labels []uint32) (*LabelledIPAddrPrefix, error) {
+	r := NewLabelledIPAddrPrefix()
+	r.Labels.Labels = labels
+	if err := r.setNetipPrefix(p, 8*r.Labels.Len(), r.addrlen); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (l *LabelledIPAddrPrefix) NetipPrefix() netip.Prefix {
+	return l.netipPrefix(int(l.Length)-8*l.Labels.Len(), l.addrlen)
+}
+
 type LabelledIPv6AddrPrefix struct {
 	LabelledIPAddrPrefix
 }
@@ -543,654 +1835,8698 @@ func NewLabelledIPv6AddrPrefix() *LabelledIPv6AddrPrefix {
 	return p
 }
 
+func NewLabelledIPv6AddrPrefixFromNetip(p netip.Prefix, labels []uint32) (*LabelledIPv6AddrPrefix, error) {
+	r := NewLabelledIPv6AddrPrefix()
+	r.Labels.Labels = labels
+	if err := r.setNetipPrefix(p, 8*r.Labels.Len(), r.addrlen); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// RouteTargetMembershipNLRI is the Route Target membership NLRI of
+// RFC 4684 4. Length is the prefix length in bits: 0 for the default
+// route, otherwise 32 for the origin AS plus up to 64 bits of the route
+// target.
 type RouteTargetMembershipNLRI struct {
+	Length      uint8
 	AS          uint32
 	RouteTarget ExtendedCommunityInterface
 }
 
+func NewRouteTargetMembershipNLRI(as uint32, target ExtendedCommunityInterface) *RouteTargetMembershipNLRI {
+	return &RouteTargetMembershipNLRI{Length: 96, AS: as, RouteTarget: target}
+}
+
 func (n *RouteTargetMembershipNLRI) DecodeFromBytes(data []byte) error {
+	if len(data) < 1 {
+		return fmt.Errorf("Not all RouteTargetMembershipNLRI bytes available")
+	}
+	n.Length = data[0]
+	if n.Length == 0 {
+		return nil
+	}
+	if n.Length < 32 || n.Length > 96 {
+		return fmt.Errorf("invalid RouteTargetMembershipNLRI length %d", n.Length)
+	}
+	data = data[1:]
+	if len(data) < n.Len()-1 {
+		return fmt.Errorf("Not all RouteTargetMembershipNLRI bytes available")
+	}
 	n.AS = binary.BigEndian.Uint32(data[0:4])
-	n.RouteTarget = parseExtended(data[4:])
+	if n.Length > 32 {
+		rt := make([]byte, 8)
+		copy(rt, data[4:n.Len()-1])
+		n.RouteTarget = parseExtended(rt)
+	}
 	return nil
 }
 
-func (n *RouteTargetMembershipNLRI) Len() int { return 12 }
-
-func rfshift(afi uint16, safi uint8) int {
-	return int(afi)<<16 | int(safi)
+func (n *RouteTargetMembershipNLRI) Serialize() ([]byte, error) {
+	if n.Length == 0 && n.RouteTarget == nil && n.AS == 0 {
+		return []byte{0}, nil
+	}
+	buf := make([]byte, 13)
+	binary.BigEndian.PutUint32(buf[1:5], n.AS)
+	if n.RouteTarget != nil {
+		rt, err := n.RouteTarget.Serialize()
+		if err != nil {
+			return nil, err
+		}
+		copy(buf[5:], rt)
+		if n.Length == 0 {
+			n.Length = 96
+		}
+	} else {
+		n.Length = 32
+	}
+	if n.Length < 32 || n.Length > 96 {
+		return nil, fmt.Errorf("invalid RouteTargetMembershipNLRI length %d", n.Length)
+	}
+	buf[0] = n.Length
+	return buf[:n.Len()], nil
 }
 
-const (
-	RF_IPv4_UC   = AFI_IP<<16 | SAFI_UNICAST
-	RF_IPv6_UC   = AFI_IP6<<16 | SAFI_UNICAST
-	RF_IPv4_VPN  = AFI_IP<<16 | SAFI_MPLS_VPN
-	RF_IPv6_VPN  = AFI_IP6<<16 | SAFI_MPLS_VPN
-	RF_IPv4_MPLS = AFI_IP<<16 | SAFI_MPLS_LABEL
-	RF_IPv6_MPLS = AFI_IP6<<16 | SAFI_MPLS_LABEL
-	RF_RTC_UC    = AFI_IP<<16 | SAFI_ROUTE_TARGET_CONSTRTAINS
-)
+func (n *RouteTargetMembershipNLRI) Len() int {
+	return 1 + (int(n.Length)+7)/8
+}
 
-func routeFamilyPrefix(afi uint16, safi uint8) (prefix AddrPrefixInterface) {
-	switch rfshift(afi, safi) {
-	case RF_IPv4_UC:
-		prefix = &IPAddrPrefix{}
-	case RF_IPv6_UC:
-		prefix = NewIPv6AddrPrefix()
-	case RF_IPv4_VPN:
-		prefix = NewLabelledVPNIPAddrPrefix()
-	case RF_IPv6_VPN:
-		prefix = NewLabelledVPNIPv6AddrPrefix()
-	case RF_IPv4_MPLS:
-		prefix = NewLabelledIPAddrPrefix()
-	case RF_IPv6_MPLS:
-		prefix = NewLabelledIPv6AddrPrefix()
-	case RF_RTC_UC:
-		prefix = &RouteTargetMembershipNLRI{}
+func (n *RouteTargetMembershipNLRI) String() string {
+	switch {
+	case n.Length == 0:
+		return "default"
+	case n.RouteTarget == nil:
+		return fmt.Sprintf("%d:*", n.AS)
+	case n.Length < 96:
+		return fmt.Sprintf("%d:%s/%d", n.AS, n.RouteTarget, n.Length)
 	}
-	return prefix
+	return fmt.Sprintf("%d:%s", n.AS, n.RouteTarget)
 }
 
-const (
-	BGP_ATTR_FLAG_EXTENDED_LENGTH = 1 << 4
-	BGP_ATTR_FLAG_PARTIAL         = 1 << 5
-	BGP_ATTR_FLAG_TRANSITIVE      = 1 << 6
-	BGP_ATTR_FLAG_OPTIONAL        = 1 << 7
-)
+// UnknownNLRI holds the NLRI field of an address family this package
+// does not decode. Since the length of each NLRI is family specific it
+// takes all remaining bytes of the MP_REACH_NLRI or MP_UNREACH_NLRI.
+type UnknownNLRI struct {
+	AFI   uint16
+	SAFI  uint8
+	Value []byte
+}
 
-const (
-	_ = iota
-	BGP_ATTR_TYPE_ORIGIN
-	BGP_ATTR_TYPE_AS_PATH
-	BGP_ATTR_TYPE_NEXT_HOP
-	BGP_ATTR_TYPE_MULTI_EXIT_DISC
-	BGP_ATTR_TYPE_LOCAL_PREF
-	BGP_ATTR_TYPE_ATOMIC_AGGREGATE
-	BGP_ATTR_TYPE_AGGREGATOR
-	BGP_ATTR_TYPE_COMMUNITIES
-	BGP_ATTR_TYPE_ORIGINATOR_ID
-	BGP_ATTR_TYPE_CLUSTER_LIST
-	_
-	_
-	_
-	BGP_ATTR_TYPE_MP_REACH_NLRI
-	BGP_ATTR_TYPE_MP_UNREACH_NLRI
-	BGP_ATTR_TYPE_EXTENDED_COMMUNITIES
-	BGP_ATTR_TYPE_AS4_PATH
-	BGP_ATTR_TYPE_AS4_AGGREGATOR
-)
+func (n *UnknownNLRI) DecodeFromBytes(data []byte) error {
+	n.Value = data
+	return nil
+}
 
-// NOTIFICATION Error Code  RFC 4271 4.5.
-const (
-	_ = iota
-	BGP_ERROR_MESSAGE_HEADER_ERROR
-	BGP_ERROR_OPEN_MESSAGE_ERROR
-	BGP_ERROR_UPDATE_MESSAGE_ERROR
-	BGP_ERROR_HOLD_TIMER_EXPIRED
-	BGP_ERROR_FSM_ERROR
-	BGP_ERROR_CEASE
-)
+func (n *UnknownNLRI) Serialize() ([]byte, error) {
+	return n.Value, nil
+}
 
-// NOTIFICATION Error Subcode for BGP_ERROR_MESSAGE_HEADER_ERROR
-const (
-	_ = iota
-	BGP_ERROR_SUB_CONNECTION_NOT_SYNCHRONIZED
-	BGP_ERROR_SUB_BAD_MESSAGE_LENGTH
-	BGP_ERROR_SUB_BAD_MESSAGE_TYPE
-)
+func (n *UnknownNLRI) Len() int {
+	return len(n.Value)
+}
 
-// NOTIFICATION Error Subcode for BGP_ERROR_OPEN_MESSAGE_ERROR
-const (
-	_ = iota
-	BGP_ERROR_SUB_UNSUPPORTED_VERSION_NUMBER
-	BGP_ERROR_SUB_BAD_PEER_AS
-	BGP_ERROR_SUB_BAD_BGP_IDENTIFIER
-	BGP_ERROR_SUB_UNSUPPORTED_OPTIONAL_PARAMETER
-	BGP_ERROR_SUB_AUTHENTICATION_FAILURE
-	BGP_ERROR_SUB_UNACCEPTABLE_HOLD_TIME
-)
+func (n *UnknownNLRI) String() string {
+	return fmt.Sprintf("[afi:%d][safi:%d][value:%x]", n.AFI, n.SAFI, n.Value)
+}
 
-// NOTIFICATION Error Subcode for BGP_ERROR_UPDATE_MESSAGE_ERROR
-const (
-	_ = iota
-	BGP_ERROR_SUB_MALFORMED_ATTRIBUTE_LIST
-	BGP_ERROR_SUB_UNRECOGNIZED_WELL_KNOWN_ATTRIBUTE
-	BGP_ERROR_SUB_MISSING_WELL_KNOWN_ATTRIBUTE
-	BGP_ERROR_SUB_ATTRIBUTE_FLAGS_ERROR
-	BGP_ERROR_SUB_ATTRIBUTE_LENGTH_ERROR
-	BGP_ERROR_SUB_INVALID_ORIGIN_ATTRIBUTE
-	BGP_ERROR_SUB_ROUTING_LOOP
-	BGP_ERROR_SUB_INVALID_NEXT_HOP_ATTRIBUTE
-	BGP_ERROR_SUB_OPTIONAL_ATTRIBUTE_ERROR
-	BGP_ERROR_SUB_INVALID_NETWORK_FIELD
-	BGP_ERROR_SUB_MALFORMED_AS_PATH
-)
+// EVPN  RFC 7432, RFC 9136
 
-// NOTIFICATION Error Subcode for BGP_ERROR_HOLD_TIMER_EXPIRED
 const (
-	_ = iota
-	BGP_ERROR_SUB_HOLD_TIMER_EXPIRED
+	ESI_ARBITRARY = iota
+	ESI_LACP
+	ESI_MSTP
+	ESI_MAC
+	ESI_ROUTERID
+	ESI_AS
 )
 
-// NOTIFICATION Error Subcode for BGP_ERROR_FSM_ERROR
-const (
-	_ = iota
-	BGP_ERROR_SUB_FSM_ERROR
-)
+type EthernetSegmentIdentifier struct {
+	Type  uint8
+	Value []byte
+}
 
-// NOTIFICATION Error Subcode for BGP_ERROR_CEASE  (RFC 4486)
-const (
-	_ = iota
-	BGP_ERROR_SUB_MAXIMUM_NUMBER_OF_PREFIXES_REACHED
-	BGP_ERROR_SUB_ADMINISTRATIVE_SHUTDOWN
-	BGP_ERROR_SUB_PEER_DECONFIGURED
-	BGP_ERROR_SUB_ADMINISTRATIVE_RESET
-	BGP_ERROR_SUB_CONNECTION_RESET
-	BGP_ERROR_SUB_OTHER_CONFIGURATION_CHANGE
-	BGP_ERROR_SUB_CONNECTION_COLLISION_RESOLUTION
-	BGP_ERROR_SUB_OUT_OF_RESOURCES
-)
+func (esi *EthernetSegmentIdentifier) DecodeFromBytes(data []byte) error {
+	if len(data) < 10 {
+		return fmt.Errorf("Not all EthernetSegmentIdentifier bytes available")
+	}
+	esi.Type = data[0]
+	esi.Value = data[1:10]
+	return nil
+}
 
-type PathAttributeInterface interface {
-	DecodeFromBytes([]byte) error
-	Len() int
+func (esi *EthernetSegmentIdentifier) Serialize() ([]byte, error) {
+	if len(esi.Value) > 9 {
+		return nil, fmt.Errorf("EthernetSegmentIdentifier value must be 9 bytes")
+	}
+	buf := make([]byte, 10)
+	buf[0] = esi.Type
+	copy(buf[1:], esi.Value)
+	return buf, nil
 }
 
-type PathAttribute struct {
-	Flags  uint8
-	Type   uint8
-	Length uint16
-	Value  []byte
+func (esi *EthernetSegmentIdentifier) String() string {
+	return fmt.Sprintf("%d:%x", esi.Type, esi.Value)
 }
 
-func (p *PathAttribute) Len() int {
-	l := 2 + p.Length
-	if p.Flags&BGP_ATTR_FLAG_EXTENDED_LENGTH != 0 {
-		l += 2
-	} else {
-		l += 1
-	}
-	return int(l)
+// EVPN labels are kept as the raw 24 bit field: an MPLS label is shifted
+// left by 4 bits, while VXLAN and other NVO encapsulations carry the
+// whole field as their VNI.
+func evpnLabelDecode(data []byte) uint32 {
+	return uint32(data[0])<<16 | uint32(data[1])<<8 | uint32(data[2])
 }
 
-func (p *PathAttribute) DecodeFromBytes(data []byte) error {
-	p.Flags = data[0]
-	p.Type = data[1]
+func evpnLabelSerialize(label uint32) []byte {
+	return []byte{byte(label >> 16), byte(label >> 8), byte(label)}
+}
 
-	if p.Flags&BGP_ATTR_FLAG_EXTENDED_LENGTH != 0 {
-		p.Length = binary.BigEndian.Uint16(data[2:4])
-		data = data[4:]
-	} else {
-		p.Length = uint16(data[2])
-		data = data[3:]
+func evpnIPDecode(data []byte, bitlen int) (net.IP, error) {
+	switch bitlen {
+	case 0:
+		return nil, nil
+	case 32, 128:
+		if len(data) < bitlen/8 {
+			return nil, fmt.Errorf("Not all EVPN IP address bytes available")
+		}
+		return net.IP(data[:bitlen/8]), nil
 	}
-	p.Value = data[:p.Length]
+	return nil, fmt.Errorf("invalid EVPN IP address length %d", bitlen)
+}
 
-	return nil
+func evpnIPSerialize(ip net.IP) (uint8, []byte) {
+	if ip == nil {
+		return 0, nil
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return 32, ip4
+	}
+	return 128, ip.To16()
 }
 
-type PathAttributeOrigin struct {
-	PathAttribute
+const (
+	EVPN_ROUTE_TYPE_ETHERNET_AUTO_DISCOVERY          = 1
+	EVPN_ROUTE_TYPE_MAC_IP_ADVERTISEMENT             = 2
+	EVPN_ROUTE_TYPE_INCLUSIVE_MULTICAST_ETHERNET_TAG = 3
+	EVPN_ROUTE_TYPE_ETHERNET_SEGMENT                 = 4
+	EVPN_ROUTE_TYPE_IP_PREFIX                        = 5
+)
+
+type EVPNRouteTypeInterface interface {
+	DecodeFromBytes([]byte) error
+	Serialize() ([]byte, error)
+	String() string
 }
 
-type AsPathParam struct {
-	Type uint8
-	Num  uint8
-	AS   []uint32
+type EVPNEthernetAutoDiscoveryRoute struct {
+	RD    RouteDistinguisherInterface
+	ESI   EthernetSegmentIdentifier
+	ETag  uint32
+	Label uint32
+}
+
+func (er *EVPNEthernetAutoDiscoveryRoute) DecodeFromBytes(data []byte) error {
+	if len(data) < 25 {
+		return fmt.Errorf("Not all EVPN Ethernet A-D route bytes available")
+	}
+	er.RD = getRouteDistinguisher(data)
+	data = data[er.RD.Len():]
+	er.ESI.DecodeFromBytes(data)
+	data = data[10:]
+	er.ETag = binary.BigEndian.Uint32(data[0:4])
+	er.Label = evpnLabelDecode(data[4:7])
+	return nil
+}
+
+func (er *EVPNEthernetAutoDiscoveryRoute) Serialize() ([]byte, error) {
+	buf, err := er.RD.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	esi, err := er.ESI.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	buf = append(buf, esi...)
+	tbuf := make([]byte, 4)
+	binary.BigEndian.PutUint32(tbuf, er.ETag)
+	buf = append(buf, tbuf...)
+	return append(buf, evpnLabelSerialize(er.Label)...), nil
+}
+
+func (er *EVPNEthernetAutoDiscoveryRoute) String() string {
+	return fmt.Sprintf("[type:A-D][rd:%s][esi:%s][etag:%d][label:%d]", er.RD, &er.ESI, er.ETag, er.Label)
+}
+
+type EVPNMacIPAdvertisementRoute struct {
+	RD               RouteDistinguisherInterface
+	ESI              EthernetSegmentIdentifier
+	ETag             uint32
+	MacAddressLength uint8
+	MacAddress       net.HardwareAddr
+	IPAddressLength  uint8
+	IPAddress        net.IP
+	Labels           []uint32
+}
+
+func (er *EVPNMacIPAdvertisementRoute) DecodeFromBytes(data []byte) error {
+	if len(data) < 30 {
+		return fmt.Errorf("Not all EVPN MAC/IP advertisement route bytes available")
+	}
+	er.RD = getRouteDistinguisher(data)
+	data = data[er.RD.Len():]
+	er.ESI.DecodeFromBytes(data)
+	data = data[10:]
+	er.ETag = binary.BigEndian.Uint32(data[0:4])
+	er.MacAddressLength = data[4]
+	if er.MacAddressLength != 48 {
+		return fmt.Errorf("invalid EVPN MAC address length %d", er.MacAddressLength)
+	}
+	er.MacAddress = net.HardwareAddr(data[5:11])
+	er.IPAddressLength = data[11]
+	data = data[12:]
+	ip, err := evpnIPDecode(data, int(er.IPAddressLength))
+	if err != nil {
+		return err
+	}
+	er.IPAddress = ip
+	data = data[len(ip):]
+	if len(data) != 3 && len(data) != 6 {
+		return fmt.Errorf("invalid EVPN MAC/IP advertisement route label length %d", len(data))
+	}
+	er.Labels = nil
+	for len(data) >= 3 {
+		er.Labels = append(er.Labels, evpnLabelDecode(data))
+		data = data[3:]
+	}
+	return nil
+}
+
+func (er *EVPNMacIPAdvertisementRoute) Serialize() ([]byte, error) {
+	if len(er.MacAddress) != 6 {
+		return nil, fmt.Errorf("invalid EVPN MAC address: %s", er.MacAddress)
+	}
+	if len(er.Labels) != 1 && len(er.Labels) != 2 {
+		return nil, fmt.Errorf("EVPN MAC/IP advertisement route needs one or two labels")
+	}
+	buf, err := er.RD.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	esi, err := er.ESI.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	buf = append(buf, esi...)
+	tbuf := make([]byte, 5)
+	binary.BigEndian.PutUint32(tbuf[0:4], er.ETag)
+	tbuf[4] = 48
+	buf = append(buf, tbuf...)
+	buf = append(buf, er.MacAddress...)
+	iplen, ip := evpnIPSerialize(er.IPAddress)
+	buf = append(buf, iplen)
+	buf = append(buf, ip...)
+	for _, l := range er.Labels {
+		buf = append(buf, evpnLabelSerialize(l)...)
+	}
+	return buf, nil
+}
+
+func (er *EVPNMacIPAdvertisementRoute) String() string {
+	return fmt.Sprintf("[type:macadv][rd:%s][esi:%s][etag:%d][mac:%s][ip:%s][labels:%v]", er.RD, &er.ESI, er.ETag, er.MacAddress, er.IPAddress, er.Labels)
+}
+
+type EVPNMulticastEthernetTagRoute struct {
+	RD              RouteDistinguisherInterface
+	ETag            uint32
+	IPAddressLength uint8
+	IPAddress       net.IP
+}
+
+func (er *EVPNMulticastEthernetTagRoute) DecodeFromBytes(data []byte) error {
+	if len(data) < 13 {
+		return fmt.Errorf("Not all EVPN Inclusive Multicast route bytes available")
+	}
+	er.RD = getRouteDistinguisher(data)
+	data = data[er.RD.Len():]
+	er.ETag = binary.BigEndian.Uint32(data[0:4])
+	er.IPAddressLength = data[4]
+	ip, err := evpnIPDecode(data[5:], int(er.IPAddressLength))
+	if err != nil {
+		return err
+	}
+	er.IPAddress = ip
+	return nil
+}
+
+func (er *EVPNMulticastEthernetTagRoute) Serialize() ([]byte, error) {
+	buf, err := er.RD.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	tbuf := make([]byte, 4)
+	binary.BigEndian.PutUint32(tbuf, er.ETag)
+	buf = append(buf, tbuf...)
+	iplen, ip := evpnIPSerialize(er.IPAddress)
+	buf = append(buf, iplen)
+	return append(buf, ip...), nil
+}
+
+func (er *EVPNMulticastEthernetTagRoute) String() string {
+	return fmt.Sprintf("[type:multicast][rd:%s][etag:%d][ip:%s]", er.RD, er.ETag, er.IPAddress)
+}
+
+type EVPNEthernetSegmentRoute struct {
+	RD              RouteDistinguisherInterface
+	ESI             EthernetSegmentIdentifier
+	IPAddressLength uint8
+	IPAddress       net.IP
+}
+
+func (er *EVPNEthernetSegmentRoute) DecodeFromBytes(data []byte) error {
+	if len(data) < 19 {
+		return fmt.Errorf("Not all EVPN Ethernet Segment route bytes available")
+	}
+	er.RD = getRouteDistinguisher(data)
+	data = data[er.RD.Len():]
+	er.ESI.DecodeFromBytes(data)
+	data = data[10:]
+	er.IPAddressLength = data[0]
+	ip, err := evpnIPDecode(data[1:], int(er.IPAddressLength))
+	if err != nil {
+		return err
+	}
+	er.IPAddress = ip
+	return nil
+}
+
+func (er *EVPNEthernetSegmentRoute) Serialize() ([]byte, error) {
+	buf, err := er.RD.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	esi, err := er.ESI.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	buf = append(buf, esi...)
+	iplen, ip := evpnIPSerialize(er.IPAddress)
+	buf = append(buf, iplen)
+	return append(buf, ip...), nil
+}
+
+func (er *EVPNEthernetSegmentRoute) String() string {
+	return fmt.Sprintf("[type:esi][rd:%s][esi:%s][ip:%s]", er.RD, &er.ESI, er.IPAddress)
+}
+
+type EVPNIPPrefixRoute struct {
+	RD             RouteDistinguisherInterface
+	ESI            EthernetSegmentIdentifier
+	ETag           uint32
+	IPPrefixLength uint8
+	IPPrefix       net.IP
+	GWIPAddress    net.IP
+	Label          uint32
+}
+
+func (er *EVPNIPPrefixRoute) DecodeFromBytes(data []byte) error {
+	// the address family is only known from the route length
+	addrlen := 0
+	switch len(data) {
+	case 34:
+		addrlen = 4
+	case 58:
+		addrlen = 16
+	default:
+		return fmt.Errorf("invalid EVPN IP prefix route length %d", len(data))
+	}
+	er.RD = getRouteDistinguisher(data)
+	data = data[er.RD.Len():]
+	er.ESI.DecodeFromBytes(data)
+	data = data[10:]
+	er.ETag = binary.BigEndian.Uint32(data[0:4])
+	er.IPPrefixLength = data[4]
+	if int(er.IPPrefixLength) > 8*addrlen {
+		return fmt.Errorf("invalid EVPN IP prefix length %d", er.IPPrefixLength)
+	}
+	data = data[5:]
+	er.IPPrefix = net.IP(data[:addrlen])
+	er.GWIPAddress = net.IP(data[addrlen : 2*addrlen])
+	er.Label = evpnLabelDecode(data[2*addrlen:])
+	return nil
+}
+
+func (er *EVPNIPPrefixRoute) Serialize() ([]byte, error) {
+	buf, err := er.RD.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	esi, err := er.ESI.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	buf = append(buf, esi...)
+	tbuf := make([]byte, 5)
+	binary.BigEndian.PutUint32(tbuf[0:4], er.ETag)
+	tbuf[4] = er.IPPrefixLength
+	buf = append(buf, tbuf...)
+	prefix := er.IPPrefix.To4()
+	gw := er.GWIPAddress.To4()
+	if prefix == nil {
+		prefix = er.IPPrefix.To16()
+		gw = er.GWIPAddress.To16()
+		if gw == nil {
+			gw = net.IPv6zero
+		}
+	} else if gw == nil {
+		gw = net.IPv4zero.To4()
+	}
+	if prefix == nil || len(prefix) != len(gw) {
+		return nil, fmt.Errorf("EVPN IP prefix %s and gateway %s are not of the same family", er.IPPrefix, er.GWIPAddress)
+	}
+	buf = append(buf, prefix...)
+	buf = append(buf, gw...)
+	return append(buf, evpnLabelSerialize(er.Label)...), nil
+}
+
+func (er *EVPNIPPrefixRoute) String() string {
+	return fmt.Sprintf("[type:prefix][rd:%s][esi:%s][etag:%d][prefix:%s/%d][gw:%s][label:%d]", er.RD, &er.ESI, er.ETag, er.IPPrefix, er.IPPrefixLength, er.GWIPAddress, er.Label)
+}
+
+type EVPNUnknownRoute struct {
+	Value []byte
+}
+
+func (er *EVPNUnknownRoute) DecodeFromBytes(data []byte) error {
+	er.Value = data
+	return nil
+}
+
+func (er *EVPNUnknownRoute) Serialize() ([]byte, error) {
+	return er.Value, nil
+}
+
+func (er *EVPNUnknownRoute) String() string {
+	return fmt.Sprintf("[value:%x]", er.Value)
+}
+
+func getEVPNRouteType(t uint8) EVPNRouteTypeInterface {
+	switch t {
+	case EVPN_ROUTE_TYPE_ETHERNET_AUTO_DISCOVERY:
+		return &EVPNEthernetAutoDiscoveryRoute{}
+	case EVPN_ROUTE_TYPE_MAC_IP_ADVERTISEMENT:
+		return &EVPNMacIPAdvertisementRoute{}
+	case EVPN_ROUTE_TYPE_INCLUSIVE_MULTICAST_ETHERNET_TAG:
+		return &EVPNMulticastEthernetTagRoute{}
+	case EVPN_ROUTE_TYPE_ETHERNET_SEGMENT:
+		return &EVPNEthernetSegmentRoute{}
+	case EVPN_ROUTE_TYPE_IP_PREFIX:
+		return &EVPNIPPrefixRoute{}
+	}
+	return &EVPNUnknownRoute{}
+}
+
+type EVPNNLRI struct {
+	RouteType     uint8
+	Length        uint8
+	RouteTypeData EVPNRouteTypeInterface
+}
+
+func NewEVPNNLRI(routetype uint8, routetypedata EVPNRouteTypeInterface) *EVPNNLRI {
+	return &EVPNNLRI{
+		RouteType:     routetype,
+		RouteTypeData: routetypedata,
+	}
+}
+
+func (n *EVPNNLRI) DecodeFromBytes(data []byte) error {
+	if len(data) < 2 {
+		return fmt.Errorf("Not all EVPNNLRI bytes available")
+	}
+	n.RouteType = data[0]
+	n.Length = data[1]
+	data = data[2:]
+	if len(data) < int(n.Length) {
+		return fmt.Errorf("Not all EVPNNLRI Route type bytes available")
+	}
+	r := getEVPNRouteType(n.RouteType)
+	n.RouteTypeData = r
+	return n.RouteTypeData.DecodeFromBytes(data[:n.Length])
+}
+
+func (n *EVPNNLRI) Serialize() ([]byte, error) {
+	if n.RouteTypeData == nil {
+		return nil, fmt.Errorf("EVPNNLRI has no route type data")
+	}
+	tbuf, err := n.RouteTypeData.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	if len(tbuf) > math.MaxUint8 {
+		return nil, fmt.Errorf("EVPN route is too long")
+	}
+	n.Length = uint8(len(tbuf))
+	return append([]byte{n.RouteType, n.Length}, tbuf...), nil
+}
+
+func (n *EVPNNLRI) Len() int {
+	return int(n.Length) + 2
+}
+
+func (n *EVPNNLRI) String() string {
+	if n.RouteTypeData == nil {
+		return fmt.Sprintf("[type:%d]", n.RouteType)
+	}
+	if _, ok := n.RouteTypeData.(*EVPNUnknownRoute); ok {
+		return fmt.Sprintf("[type:%d]%s", n.RouteType, n.RouteTypeData)
+	}
+	return n.RouteTypeData.String()
+}
+
+// VPLS  RFC 4761 3.2.2
+const VPLS_NLRI_LENGTH = 17
+
+type VPLSNLRI struct {
+	Length         uint16
+	RD             RouteDistinguisherInterface
+	VEID           uint16
+	VEBlockOffset  uint16
+	VEBlockSize    uint16
+	LabelBlockBase uint32
+}
+
+func NewVPLSNLRI(rd RouteDistinguisherInterface, veid, offset, size uint16, base uint32) *VPLSNLRI {
+	return &VPLSNLRI{
+		Length:         VPLS_NLRI_LENGTH,
+		RD:             rd,
+		VEID:           veid,
+		VEBlockOffset:  offset,
+		VEBlockSize:    size,
+		LabelBlockBase: base,
+	}
+}
+
+func (n *VPLSNLRI) DecodeFromBytes(data []byte) error {
+	if len(data) < 2 {
+		return fmt.Errorf("Not all VPLSNLRI bytes available")
+	}
+	n.Length = binary.BigEndian.Uint16(data[0:2])
+	data = data[2:]
+	if n.Length != VPLS_NLRI_LENGTH || len(data) < int(n.Length) {
+		return fmt.Errorf("Not all VPLSNLRI bytes available")
+	}
+	n.RD = getRouteDistinguisher(data[0:8])
+	n.VEID = binary.BigEndian.Uint16(data[8:10])
+	n.VEBlockOffset = binary.BigEndian.Uint16(data[10:12])
+	n.VEBlockSize = binary.BigEndian.Uint16(data[12:14])
+	n.LabelBlockBase = (uint32(data[14])<<16 | uint32(data[15])<<8 | uint32(data[16])) >> 4
+	return nil
+}
+
+func (n *VPLSNLRI) Serialize() ([]byte, error) {
+	if n.RD == nil {
+		return nil, fmt.Errorf("VPLSNLRI has no route distinguisher")
+	}
+	if n.LabelBlockBase > MPLS_LABEL_MAX {
+		return nil, fmt.Errorf("invalid label block base: %d", n.LabelBlockBase)
+	}
+	rbuf, err := n.RD.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	n.Length = VPLS_NLRI_LENGTH
+	buf := make([]byte, 2+VPLS_NLRI_LENGTH)
+	binary.BigEndian.PutUint16(buf[0:2], n.Length)
+	copy(buf[2:10], rbuf)
+	binary.BigEndian.PutUint16(buf[10:12], n.VEID)
+	binary.BigEndian.PutUint16(buf[12:14], n.VEBlockOffset)
+	binary.BigEndian.PutUint16(buf[14:16], n.VEBlockSize)
+	label := n.LabelBlockBase<<4 | 1
+	buf[16] = byte(label >> 16)
+	buf[17] = byte(label >> 8)
+	buf[18] = byte(label)
+	return buf, nil
+}
+
+func (n *VPLSNLRI) Len() int {
+	return int(n.Length) + 2
+}
+
+func (n *VPLSNLRI) String() string {
+	return fmt.Sprintf("[rd:%s][veid:%d][offset:%d][size:%d][label-base:%d]", n.RD, n.VEID, n.VEBlockOffset, n.VEBlockSize, n.LabelBlockBase)
+}
+
+// Labels returns the first and last label of the block advertised to
+// the VE blocks, one label per VE ID from VEBlockOffset. ok is false when
+// the block is empty.
+func (n *VPLSNLRI) Labels() (first, last uint32, ok bool) {
+	if n.VEBlockSize == 0 {
+		return 0, 0, false
+	}
+	return n.LabelBlockBase, n.LabelBlockBase + uint32(n.VEBlockSize) - 1, true
+}
+
+// MCAST-VPN  RFC 6514 4
+const (
+	MVPN_ROUTE_TYPE_INTRA_AS_I_PMSI_AD = 1
+	MVPN_ROUTE_TYPE_INTER_AS_I_PMSI_AD = 2
+	MVPN_ROUTE_TYPE_S_PMSI_AD          = 3
+	MVPN_ROUTE_TYPE_LEAF_AD            = 4
+	MVPN_ROUTE_TYPE_SOURCE_ACTIVE_AD   = 5
+	MVPN_ROUTE_TYPE_C_MULTICAST_SHARED = 6
+	MVPN_ROUTE_TYPE_C_MULTICAST_SOURCE = 7
+)
+
+var mvpnRouteTypeNames = map[uint8]string{
+	MVPN_ROUTE_TYPE_INTRA_AS_I_PMSI_AD: "intra-as-i-pmsi-ad",
+	MVPN_ROUTE_TYPE_INTER_AS_I_PMSI_AD: "inter-as-i-pmsi-ad",
+	MVPN_ROUTE_TYPE_S_PMSI_AD:          "s-pmsi-ad",
+	MVPN_ROUTE_TYPE_LEAF_AD:            "leaf-ad",
+	MVPN_ROUTE_TYPE_SOURCE_ACTIVE_AD:   "source-active-ad",
+	MVPN_ROUTE_TYPE_C_MULTICAST_SHARED: "c-multicast-shared",
+	MVPN_ROUTE_TYPE_C_MULTICAST_SOURCE: "c-multicast-source",
+}
+
+// MVPN route types leave the route type to the MVPNNLRI they are in,
+// since the two C-multicast routes share one encoding.
+type MVPNRouteTypeInterface interface {
+	DecodeFromBytes([]byte) error
+	Serialize() ([]byte, error)
+	String() string
+}
+
+func mvpnOriginatorDecode(data []byte) (net.IP, error) {
+	if len(data) != net.IPv4len && len(data) != net.IPv6len {
+		return nil, fmt.Errorf("invalid MVPN originating router address length %d", len(data))
+	}
+	return net.IP(data), nil
+}
+
+func mvpnOriginatorSerialize(ip net.IP) ([]byte, error) {
+	_, buf := evpnIPSerialize(ip)
+	if buf == nil {
+		return nil, fmt.Errorf("MVPN route has no originating router address")
+	}
+	return buf, nil
+}
+
+// mvpnSourceGroupDecode decodes the multicast source and group of S-PMSI
+// A-D, Source Active A-D and C-multicast routes. A zero length denotes
+// a wildcard  RFC 6625.
+func mvpnSourceGroupDecode(data []byte) (net.IP, net.IP, []byte, error) {
+	if len(data) < 1 {
+		return nil, nil, nil, fmt.Errorf("Not all MVPN multicast source bytes available")
+	}
+	source, err := evpnIPDecode(data[1:], int(data[0]))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	data = data[1+len(source):]
+	if len(data) < 1 {
+		return nil, nil, nil, fmt.Errorf("Not all MVPN multicast group bytes available")
+	}
+	group, err := evpnIPDecode(data[1:], int(data[0]))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return source, group, data[1+len(group):], nil
+}
+
+func mvpnSourceGroupSerialize(source, group net.IP) []byte {
+	slen, s := evpnIPSerialize(source)
+	glen, g := evpnIPSerialize(group)
+	buf := append([]byte{slen}, s...)
+	buf = append(buf, glen)
+	return append(buf, g...)
+}
+
+type MVPNIntraASIPMSIADRoute struct {
+	RD         RouteDistinguisherInterface
+	Originator net.IP
+}
+
+func (r *MVPNIntraASIPMSIADRoute) DecodeFromBytes(data []byte) error {
+	if len(data) < 12 {
+		return fmt.Errorf("Not all MVPN Intra-AS I-PMSI A-D route bytes available")
+	}
+	r.RD = getRouteDistinguisher(data)
+	ip, err := mvpnOriginatorDecode(data[8:])
+	r.Originator = ip
+	return err
+}
+
+func (r *MVPNIntraASIPMSIADRoute) Serialize() ([]byte, error) {
+	buf, err := r.RD.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	ip, err := mvpnOriginatorSerialize(r.Originator)
+	if err != nil {
+		return nil, err
+	}
+	return append(buf, ip...), nil
+}
+
+func (r *MVPNIntraASIPMSIADRoute) String() string {
+	return fmt.Sprintf("[rd:%s][originator:%s]", r.RD, r.Originator)
+}
+
+type MVPNInterASIPMSIADRoute struct {
+	RD       RouteDistinguisherInterface
+	SourceAS uint32
+}
+
+func (r *MVPNInterASIPMSIADRoute) DecodeFromBytes(data []byte) error {
+	if len(data) != 12 {
+		return fmt.Errorf("Not all MVPN Inter-AS I-PMSI A-D route bytes available")
+	}
+	r.RD = getRouteDistinguisher(data)
+	r.SourceAS = binary.BigEndian.Uint32(data[8:12])
+	return nil
+}
+
+func (r *MVPNInterASIPMSIADRoute) Serialize() ([]byte, error) {
+	buf, err := r.RD.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	asbuf := make([]byte, 4)
+	binary.BigEndian.PutUint32(asbuf, r.SourceAS)
+	return append(buf, asbuf...), nil
+}
+
+func (r *MVPNInterASIPMSIADRoute) String() string {
+	return fmt.Sprintf("[rd:%s][source-as:%d]", r.RD, r.SourceAS)
+}
+
+type MVPNSPMSIADRoute struct {
+	RD         RouteDistinguisherInterface
+	Source     net.IP
+	Group      net.IP
+	Originator net.IP
+}
+
+func (r *MVPNSPMSIADRoute) DecodeFromBytes(data []byte) error {
+	if len(data) < 8 {
+		return fmt.Errorf("Not all MVPN S-PMSI A-D route bytes available")
+	}
+	r.RD = getRouteDistinguisher(data)
+	source, group, rest, err := mvpnSourceGroupDecode(data[8:])
+	if err != nil {
+		return err
+	}
+	r.Source = source
+	r.Group = group
+	r.Originator, err = mvpnOriginatorDecode(rest)
+	return err
+}
+
+func (r *MVPNSPMSIADRoute) Serialize() ([]byte, error) {
+	buf, err := r.RD.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	buf = append(buf, mvpnSourceGroupSerialize(r.Source, r.Group)...)
+	ip, err := mvpnOriginatorSerialize(r.Originator)
+	if err != nil {
+		return nil, err
+	}
+	return append(buf, ip...), nil
+}
+
+func (r *MVPNSPMSIADRoute) String() string {
+	return fmt.Sprintf("[rd:%s][source:%s][group:%s][originator:%s]", r.RD, r.Source, r.Group, r.Originator)
+}
+
+// MVPNLeafADRoute answers the route carried in RouteKey, usually an
+// S-PMSI A-D route  RFC 6514 4.4
+type MVPNLeafADRoute struct {
+	RouteKey   *MVPNNLRI
+	Originator net.IP
+}
+
+func (r *MVPNLeafADRoute) DecodeFromBytes(data []byte) error {
+	r.RouteKey = &MVPNNLRI{}
+	if err := r.RouteKey.DecodeFromBytes(data); err != nil {
+		return err
+	}
+	ip, err := mvpnOriginatorDecode(data[r.RouteKey.Len():])
+	r.Originator = ip
+	return err
+}
+
+func (r *MVPNLeafADRoute) Serialize() ([]byte, error) {
+	if r.RouteKey == nil {
+		return nil, fmt.Errorf("MVPN Leaf A-D route has no route key")
+	}
+	buf, err := r.RouteKey.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	ip, err := mvpnOriginatorSerialize(r.Originator)
+	if err != nil {
+		return nil, err
+	}
+	return append(buf, ip...), nil
+}
+
+func (r *MVPNLeafADRoute) String() string {
+	return fmt.Sprintf("[route-key:%s][originator:%s]", r.RouteKey, r.Originator)
+}
+
+type MVPNSourceActiveADRoute struct {
+	RD     RouteDistinguisherInterface
+	Source net.IP
+	Group  net.IP
+}
+
+func (r *MVPNSourceActiveADRoute) DecodeFromBytes(data []byte) error {
+	if len(data) < 8 {
+		return fmt.Errorf("Not all MVPN Source Active A-D route bytes available")
+	}
+	r.RD = getRouteDistinguisher(data)
+	source, group, rest, err := mvpnSourceGroupDecode(data[8:])
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return fmt.Errorf("trailing bytes in MVPN Source Active A-D route")
+	}
+	r.Source = source
+	r.Group = group
+	return nil
+}
+
+func (r *MVPNSourceActiveADRoute) Serialize() ([]byte, error) {
+	buf, err := r.RD.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	return append(buf, mvpnSourceGroupSerialize(r.Source, r.Group)...), nil
+}
+
+func (r *MVPNSourceActiveADRoute) String() string {
+	return fmt.Sprintf("[rd:%s][source:%s][group:%s]", r.RD, r.Source, r.Group)
+}
+
+// MVPNCMulticastRoute is a C-multicast Shared Tree Join (type 6) or
+// Source Tree Join (type 7) route, both share the same encoding.
+type MVPNCMulticastRoute struct {
+	RD       RouteDistinguisherInterface
+	SourceAS uint32
+	Source   net.IP
+	Group    net.IP
+}
+
+func (r *MVPNCMulticastRoute) DecodeFromBytes(data []byte) error {
+	if len(data) < 12 {
+		return fmt.Errorf("Not all MVPN C-multicast route bytes available")
+	}
+	r.RD = getRouteDistinguisher(data)
+	r.SourceAS = binary.BigEndian.Uint32(data[8:12])
+	source, group, rest, err := mvpnSourceGroupDecode(data[12:])
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return fmt.Errorf("trailing bytes in MVPN C-multicast route")
+	}
+	r.Source = source
+	r.Group = group
+	return nil
+}
+
+func (r *MVPNCMulticastRoute) Serialize() ([]byte, error) {
+	buf, err := r.RD.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	asbuf := make([]byte, 4)
+	binary.BigEndian.PutUint32(asbuf, r.SourceAS)
+	buf = append(buf, asbuf...)
+	return append(buf, mvpnSourceGroupSerialize(r.Source, r.Group)...), nil
+}
+
+func (r *MVPNCMulticastRoute) String() string {
+	return fmt.Sprintf("[rd:%s][source-as:%d][source:%s][group:%s]", r.RD, r.SourceAS, r.Source, r.Group)
+}
+
+type MVPNUnknownRoute struct {
+	Value []byte
+}
+
+func (r *MVPNUnknownRoute) DecodeFromBytes(data []byte) error {
+	r.Value = data
+	return nil
+}
+
+func (r *MVPNUnknownRoute) String() string {
+	return fmt.Sprintf("[value:%x]", r.Value)
+}
+
+func (r *MVPNUnknownRoute) Serialize() ([]byte, error) {
+	return r.Value, nil
+}
+
+func getMVPNRouteType(t uint8) MVPNRouteTypeInterface {
+	switch t {
+	case MVPN_ROUTE_TYPE_INTRA_AS_I_PMSI_AD:
+		return &MVPNIntraASIPMSIADRoute{}
+	case MVPN_ROUTE_TYPE_INTER_AS_I_PMSI_AD:
+		return &MVPNInterASIPMSIADRoute{}
+	case MVPN_ROUTE_TYPE_S_PMSI_AD:
+		return &MVPNSPMSIADRoute{}
+	case MVPN_ROUTE_TYPE_LEAF_AD:
+		return &MVPNLeafADRoute{}
+	case MVPN_ROUTE_TYPE_SOURCE_ACTIVE_AD:
+		return &MVPNSourceActiveADRoute{}
+	case MVPN_ROUTE_TYPE_C_MULTICAST_SHARED, MVPN_ROUTE_TYPE_C_MULTICAST_SOURCE:
+		return &MVPNCMulticastRoute{}
+	}
+	return &MVPNUnknownRoute{}
+}
+
+type MVPNNLRI struct {
+	RouteType     uint8
+	Length        uint8
+	RouteTypeData MVPNRouteTypeInterface
+}
+
+func NewMVPNNLRI(routetype uint8, routetypedata MVPNRouteTypeInterface) *MVPNNLRI {
+	return &MVPNNLRI{
+		RouteType:     routetype,
+		RouteTypeData: routetypedata,
+	}
+}
+
+func (n *MVPNNLRI) DecodeFromBytes(data []byte) error {
+	if len(data) < 2 {
+		return fmt.Errorf("Not all MVPNNLRI bytes available")
+	}
+	n.RouteType = data[0]
+	n.Length = data[1]
+	data = data[2:]
+	if len(data) < int(n.Length) {
+		return fmt.Errorf("Not all MVPNNLRI Route type bytes available")
+	}
+	n.RouteTypeData = getMVPNRouteType(n.RouteType)
+	return n.RouteTypeData.DecodeFromBytes(data[:n.Length])
+}
+
+func (n *MVPNNLRI) Serialize() ([]byte, error) {
+	if n.RouteTypeData == nil {
+		return nil, fmt.Errorf("MVPNNLRI has no route type data")
+	}
+	tbuf, err := n.RouteTypeData.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	if len(tbuf) > math.MaxUint8 {
+		return nil, fmt.Errorf("MVPN route is too long")
+	}
+	n.Length = uint8(len(tbuf))
+	return append([]byte{n.RouteType, n.Length}, tbuf...), nil
+}
+
+func (n *MVPNNLRI) Len() int {
+	return int(n.Length) + 2
+}
+
+func (n *MVPNNLRI) String() string {
+	name, ok := mvpnRouteTypeNames[n.RouteType]
+	if !ok {
+		name = strconv.Itoa(int(n.RouteType))
+	}
+	if n.RouteTypeData == nil {
+		return fmt.Sprintf("[type:%s]", name)
+	}
+	return fmt.Sprintf("[type:%s]%s", name, n.RouteTypeData)
+}
+
+// BGP-LS  RFC 9552
+type LsNLRIType uint16
+
+const (
+	LS_NLRI_TYPE_NODE        LsNLRIType = 1
+	LS_NLRI_TYPE_LINK                   = 2
+	LS_NLRI_TYPE_PREFIX_IPV4            = 3
+	LS_NLRI_TYPE_PREFIX_IPV6            = 4
+)
+
+type LsProtocolID uint8
+
+const (
+	LS_PROTOCOL_ISIS_L1 LsProtocolID = 1
+	LS_PROTOCOL_ISIS_L2              = 2
+	LS_PROTOCOL_OSPFV2               = 3
+	LS_PROTOCOL_DIRECT               = 4
+	LS_PROTOCOL_STATIC               = 5
+	LS_PROTOCOL_OSPFV3               = 6
+	LS_PROTOCOL_BGP                  = 7
+)
+
+var lsNLRITypeNames = map[LsNLRIType]string{
+	LS_NLRI_TYPE_NODE:        "node",
+	LS_NLRI_TYPE_LINK:        "link",
+	LS_NLRI_TYPE_PREFIX_IPV4: "prefix-ipv4",
+	LS_NLRI_TYPE_PREFIX_IPV6: "prefix-ipv6",
+}
+
+func (t LsNLRIType) String() string {
+	if name, ok := lsNLRITypeNames[t]; ok {
+		return name
+	}
+	return strconv.Itoa(int(t))
+}
+
+var lsProtocolIDNames = map[LsProtocolID]string{
+	LS_PROTOCOL_ISIS_L1: "isis-l1",
+	LS_PROTOCOL_ISIS_L2: "isis-l2",
+	LS_PROTOCOL_OSPFV2:  "ospfv2",
+	LS_PROTOCOL_DIRECT:  "direct",
+	LS_PROTOCOL_STATIC:  "static",
+	LS_PROTOCOL_OSPFV3:  "ospfv3",
+	LS_PROTOCOL_BGP:     "bgp",
+}
+
+func (p LsProtocolID) String() string {
+	if name, ok := lsProtocolIDNames[p]; ok {
+		return name
+	}
+	return strconv.Itoa(int(p))
+}
+
+// TLV code points of the NLRI descriptors  RFC 9552 5.2 and of the
+// BGP-LS attribute  RFC 9552 5.3, RFC 9085
+const (
+	LS_TLV_LOCAL_NODE_DESC          = 256
+	LS_TLV_REMOTE_NODE_DESC         = 257
+	LS_TLV_LINK_ID                  = 258
+	LS_TLV_IPV4_INTERFACE_ADDR      = 259
+	LS_TLV_IPV4_NEIGHBOR_ADDR       = 260
+	LS_TLV_IPV6_INTERFACE_ADDR      = 261
+	LS_TLV_IPV6_NEIGHBOR_ADDR       = 262
+	LS_TLV_MULTI_TOPOLOGY_ID        = 263
+	LS_TLV_OSPF_ROUTE_TYPE          = 264
+	LS_TLV_IP_REACHABILITY_INFO     = 265
+	LS_TLV_AS                       = 512
+	LS_TLV_BGP_LS_ID                = 513
+	LS_TLV_OSPF_AREA_ID             = 514
+	LS_TLV_IGP_ROUTER_ID            = 515
+	LS_TLV_NODE_FLAG_BITS           = 1024
+	LS_TLV_NODE_NAME                = 1026
+	LS_TLV_ISIS_AREA_ID             = 1027
+	LS_TLV_IPV4_LOCAL_ROUTER_ID     = 1028
+	LS_TLV_IPV6_LOCAL_ROUTER_ID     = 1029
+	LS_TLV_IPV4_REMOTE_ROUTER_ID    = 1030
+	LS_TLV_IPV6_REMOTE_ROUTER_ID    = 1031
+	LS_TLV_SR_CAPABILITIES          = 1034
+	LS_TLV_SR_ALGORITHM             = 1035
+	LS_TLV_SR_LOCAL_BLOCK           = 1036
+	LS_TLV_ADMIN_GROUP              = 1088
+	LS_TLV_MAX_LINK_BANDWIDTH       = 1089
+	LS_TLV_MAX_RESERVABLE_BANDWIDTH = 1090
+	LS_TLV_UNRESERVED_BANDWIDTH     = 1091
+	LS_TLV_TE_DEFAULT_METRIC        = 1092
+	LS_TLV_IGP_METRIC               = 1095
+	LS_TLV_SRLG                     = 1096
+	LS_TLV_LINK_NAME                = 1098
+	LS_TLV_ADJACENCY_SID            = 1099
+	LS_TLV_LAN_ADJACENCY_SID        = 1100
+	LS_TLV_IGP_FLAGS                = 1152
+	LS_TLV_ROUTE_TAG                = 1153
+	LS_TLV_PREFIX_METRIC            = 1155
+	LS_TLV_PREFIX_SID               = 1158
+	LS_TLV_SID_LABEL                = 1161
+)
+
+type LsTLV struct {
+	Type   uint16
+	Length uint16
+	Value  []byte
+}
+
+func NewLsTLV(t uint16, value []byte) *LsTLV {
+	return &LsTLV{t, uint16(len(value)), value}
+}
+
+func (t *LsTLV) DecodeFromBytes(data []byte) error {
+	if len(data) < 4 {
+		return fmt.Errorf("Not all BGP-LS TLV bytes available")
+	}
+	t.Type = binary.BigEndian.Uint16(data[0:2])
+	t.Length = binary.BigEndian.Uint16(data[2:4])
+	if len(data) < 4+int(t.Length) {
+		return fmt.Errorf("Not all BGP-LS TLV %d bytes available", t.Type)
+	}
+	t.Value = data[4 : 4+t.Length]
+	return nil
+}
+
+func (t *LsTLV) Serialize() ([]byte, error) {
+	if len(t.Value) > math.MaxUint16 {
+		return nil, fmt.Errorf("BGP-LS TLV %d is too long", t.Type)
+	}
+	t.Length = uint16(len(t.Value))
+	buf := make([]byte, 4)
+	binary.BigEndian.PutUint16(buf[0:2], t.Type)
+	binary.BigEndian.PutUint16(buf[2:4], t.Length)
+	return append(buf, t.Value...), nil
+}
+
+func (t *LsTLV) Len() int {
+	return 4 + int(t.Length)
+}
+
+func decodeLsTLVs(data []byte) ([]*LsTLV, error) {
+	var tlvs []*LsTLV
+	for len(data) > 0 {
+		t := &LsTLV{}
+		if err := t.DecodeFromBytes(data); err != nil {
+			return nil, err
+		}
+		tlvs = append(tlvs, t)
+		data = data[t.Len():]
+	}
+	return tlvs, nil
+}
+
+// serializeLsTLVs encodes tlvs in ascending type order, which is the
+// canonical order BGP-LS speakers use when comparing NLRIs.
+func serializeLsTLVs(tlvs []*LsTLV) ([]byte, error) {
+	sort.SliceStable(tlvs, func(i, j int) bool {
+		return tlvs[i].Type < tlvs[j].Type
+	})
+	var buf []byte
+	for _, t := range tlvs {
+		tbuf, err := t.Serialize()
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, tbuf...)
+	}
+	return buf, nil
+}
+
+func lsUint8TLV(t uint16, v uint8) *LsTLV {
+	return NewLsTLV(t, []byte{v})
+}
+
+func lsUint16TLV(t uint16, v uint16) *LsTLV {
+	buf := make([]byte, 2)
+	binary.BigEndian.PutUint16(buf, v)
+	return NewLsTLV(t, buf)
+}
+
+func lsUint32TLV(t uint16, v uint32) *LsTLV {
+	buf := make([]byte, 4)
+	binary.BigEndian.PutUint32(buf, v)
+	return NewLsTLV(t, buf)
+}
+
+func lsFloat32TLV(t uint16, v float32) *LsTLV {
+	return lsUint32TLV(t, math.Float32bits(v))
+}
+
+func lsAddrTLV(t4, t6 uint16, addr net.IP) *LsTLV {
+	if v4 := addr.To4(); v4 != nil {
+		return NewLsTLV(t4, []byte(v4))
+	}
+	return NewLsTLV(t6, []byte(addr.To16()))
+}
+
+func lsTLVLength(t *LsTLV, lengths ...int) error {
+	for _, l := range lengths {
+		if int(t.Length) == l {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid BGP-LS TLV %d length %d", t.Type, t.Length)
+}
+
+// LsNodeDescriptor identifies a node in the local and remote node
+// descriptor TLVs  RFC 9552 5.2.1.4
+type LsNodeDescriptor struct {
+	AS          *uint32
+	BGPLsID     *uint32
+	OspfAreaID  *uint32
+	IGPRouterID []byte
+	Unknown     []*LsTLV
+}
+
+func (d *LsNodeDescriptor) DecodeFromBytes(data []byte) error {
+	tlvs, err := decodeLsTLVs(data)
+	if err != nil {
+		return err
+	}
+	for _, t := range tlvs {
+		switch t.Type {
+		case LS_TLV_AS, LS_TLV_BGP_LS_ID, LS_TLV_OSPF_AREA_ID:
+			if err := lsTLVLength(t, 4); err != nil {
+				return err
+			}
+			v := binary.BigEndian.Uint32(t.Value)
+			switch t.Type {
+			case LS_TLV_AS:
+				d.AS = &v
+			case LS_TLV_BGP_LS_ID:
+				d.BGPLsID = &v
+			case LS_TLV_OSPF_AREA_ID:
+				d.OspfAreaID = &v
+			}
+		case LS_TLV_IGP_ROUTER_ID:
+			if err := lsTLVLength(t, 4, 6, 7, 8); err != nil {
+				return err
+			}
+			d.IGPRouterID = t.Value
+		default:
+			d.Unknown = append(d.Unknown, t)
+		}
+	}
+	return nil
+}
+
+func (d *LsNodeDescriptor) Serialize() ([]byte, error) {
+	tlvs := append([]*LsTLV{}, d.Unknown...)
+	if d.AS != nil {
+		tlvs = append(tlvs, lsUint32TLV(LS_TLV_AS, *d.AS))
+	}
+	if d.BGPLsID != nil {
+		tlvs = append(tlvs, lsUint32TLV(LS_TLV_BGP_LS_ID, *d.BGPLsID))
+	}
+	if d.OspfAreaID != nil {
+		tlvs = append(tlvs, lsUint32TLV(LS_TLV_OSPF_AREA_ID, *d.OspfAreaID))
+	}
+	if d.IGPRouterID != nil {
+		tlvs = append(tlvs, NewLsTLV(LS_TLV_IGP_ROUTER_ID, d.IGPRouterID))
+	}
+	return serializeLsTLVs(tlvs)
+}
+
+func (d *LsNodeDescriptor) String() string {
+	buf := bytes.NewBuffer(nil)
+	if d.AS != nil {
+		buf.WriteString(fmt.Sprintf("[as:%d]", *d.AS))
+	}
+	if d.BGPLsID != nil {
+		buf.WriteString(fmt.Sprintf("[bgp-ls-id:%d]", *d.BGPLsID))
+	}
+	if d.OspfAreaID != nil {
+		buf.WriteString(fmt.Sprintf("[ospf-area:%d]", *d.OspfAreaID))
+	}
+	switch len(d.IGPRouterID) {
+	case 0:
+	case net.IPv4len:
+		buf.WriteString(fmt.Sprintf("[router-id:%s]", net.IP(d.IGPRouterID)))
+	default:
+		buf.WriteString(fmt.Sprintf("[router-id:%x]", d.IGPRouterID))
+	}
+	return buf.String()
+}
+
+// LsLinkDescriptor identifies a link  RFC 9552 5.2.2
+type LsLinkDescriptor struct {
+	LinkLocalID      *uint32
+	LinkRemoteID     *uint32
+	InterfaceAddr    net.IP
+	NeighborAddr     net.IP
+	MultiTopologyIDs []uint16
+	Unknown          []*LsTLV
+}
+
+func (d *LsLinkDescriptor) decodeTLV(t *LsTLV) error {
+	switch t.Type {
+	case LS_TLV_LINK_ID:
+		if err := lsTLVLength(t, 8); err != nil {
+			return err
+		}
+		local := binary.BigEndian.Uint32(t.Value[0:4])
+		remote := binary.BigEndian.Uint32(t.Value[4:8])
+		d.LinkLocalID = &local
+		d.LinkRemoteID = &remote
+	case LS_TLV_IPV4_INTERFACE_ADDR, LS_TLV_IPV4_NEIGHBOR_ADDR:
+		if err := lsTLVLength(t, 4); err != nil {
+			return err
+		}
+		if t.Type == LS_TLV_IPV4_INTERFACE_ADDR {
+			d.InterfaceAddr = net.IP(t.Value)
+		} else {
+			d.NeighborAddr = net.IP(t.Value)
+		}
+	case LS_TLV_IPV6_INTERFACE_ADDR, LS_TLV_IPV6_NEIGHBOR_ADDR:
+		if err := lsTLVLength(t, 16); err != nil {
+			return err
+		}
+		if t.Type == LS_TLV_IPV6_INTERFACE_ADDR {
+			d.InterfaceAddr = net.IP(t.Value)
+		} else {
+			d.NeighborAddr = net.IP(t.Value)
+		}
+	case LS_TLV_MULTI_TOPOLOGY_ID:
+		ids, err := decodeLsMultiTopologyIDs(t)
+		if err != nil {
+			return err
+		}
+		d.MultiTopologyIDs = ids
+	default:
+		d.Unknown = append(d.Unknown, t)
+	}
+	return nil
+}
+
+func (d *LsLinkDescriptor) tlvs() []*LsTLV {
+	tlvs := append([]*LsTLV{}, d.Unknown...)
+	if d.LinkLocalID != nil {
+		buf := make([]byte, 8)
+		binary.BigEndian.PutUint32(buf[0:4], *d.LinkLocalID)
+		if d.LinkRemoteID != nil {
+			binary.BigEndian.PutUint32(buf[4:8], *d.LinkRemoteID)
+		}
+		tlvs = append(tlvs, NewLsTLV(LS_TLV_LINK_ID, buf))
+	}
+	if d.InterfaceAddr != nil {
+		tlvs = append(tlvs, lsAddrTLV(LS_TLV_IPV4_INTERFACE_ADDR, LS_TLV_IPV6_INTERFACE_ADDR, d.InterfaceAddr))
+	}
+	if d.NeighborAddr != nil {
+		tlvs = append(tlvs, lsAddrTLV(LS_TLV_IPV4_NEIGHBOR_ADDR, LS_TLV_IPV6_NEIGHBOR_ADDR, d.NeighborAddr))
+	}
+	if d.MultiTopologyIDs != nil {
+		tlvs = append(tlvs, lsMultiTopologyTLV(d.MultiTopologyIDs))
+	}
+	return tlvs
+}
+
+func (d *LsLinkDescriptor) String() string {
+	buf := bytes.NewBuffer(nil)
+	if d.LinkLocalID != nil && d.LinkRemoteID != nil {
+		buf.WriteString(fmt.Sprintf("[link-id:%d/%d]", *d.LinkLocalID, *d.LinkRemoteID))
+	}
+	if d.InterfaceAddr != nil {
+		buf.WriteString(fmt.Sprintf("[interface:%s]", d.InterfaceAddr))
+	}
+	if d.NeighborAddr != nil {
+		buf.WriteString(fmt.Sprintf("[neighbor:%s]", d.NeighborAddr))
+	}
+	if d.MultiTopologyIDs != nil {
+		buf.WriteString(fmt.Sprintf("[mt:%v]", d.MultiTopologyIDs))
+	}
+	return buf.String()
+}
+
+func decodeLsMultiTopologyIDs(t *LsTLV) ([]uint16, error) {
+	if t.Length%2 != 0 {
+		return nil, fmt.Errorf("invalid BGP-LS TLV %d length %d", t.Type, t.Length)
+	}
+	ids := make([]uint16, 0, t.Length/2)
+	for i := 0; i < int(t.Length); i += 2 {
+		ids = append(ids, binary.BigEndian.Uint16(t.Value[i:i+2])&0x0fff)
+	}
+	return ids, nil
+}
+
+func lsMultiTopologyTLV(ids []uint16) *LsTLV {
+	buf := make([]byte, 2*len(ids))
+	for i, id := range ids {
+		binary.BigEndian.PutUint16(buf[2*i:], id&0x0fff)
+	}
+	return NewLsTLV(LS_TLV_MULTI_TOPOLOGY_ID, buf)
+}
+
+// LsPrefixDescriptor identifies an IGP prefix  RFC 9552 5.2.3
+type LsPrefixDescriptor struct {
+	MultiTopologyIDs []uint16
+	OspfRouteType    *uint8
+	PrefixLength     uint8
+	Prefix           net.IP
+	Unknown          []*LsTLV
+}
+
+func (d *LsPrefixDescriptor) decodeTLV(t *LsTLV, addrlen int) error {
+	switch t.Type {
+	case LS_TLV_MULTI_TOPOLOGY_ID:
+		ids, err := decodeLsMultiTopologyIDs(t)
+		if err != nil {
+			return err
+		}
+		d.MultiTopologyIDs = ids
+	case LS_TLV_OSPF_ROUTE_TYPE:
+		if err := lsTLVLength(t, 1); err != nil {
+			return err
+		}
+		v := t.Value[0]
+		d.OspfRouteType = &v
+	case LS_TLV_IP_REACHABILITY_INFO:
+		if t.Length < 1 {
+			return fmt.Errorf("Not all BGP-LS IP reachability bytes available")
+		}
+		d.PrefixLength = t.Value[0]
+		if int(d.PrefixLength) > addrlen*8 || int(t.Length)-1 != (int(d.PrefixLength)+7)/8 {
+			return fmt.Errorf("invalid BGP-LS IP reachability prefix length %d", d.PrefixLength)
+		}
+		d.Prefix = make(net.IP, addrlen)
+		copy(d.Prefix, t.Value[1:])
+	default:
+		d.Unknown = append(d.Unknown, t)
+	}
+	return nil
+}
+
+func (d *LsPrefixDescriptor) tlvs(addrlen int) ([]*LsTLV, error) {
+	tlvs := append([]*LsTLV{}, d.Unknown...)
+	if d.MultiTopologyIDs != nil {
+		tlvs = append(tlvs, lsMultiTopologyTLV(d.MultiTopologyIDs))
+	}
+	if d.OspfRouteType != nil {
+		tlvs = append(tlvs, lsUint8TLV(LS_TLV_OSPF_ROUTE_TYPE, *d.OspfRouteType))
+	}
+	prefix := d.Prefix.To16()
+	if addrlen == net.IPv4len {
+		prefix = d.Prefix.To4()
+	}
+	if prefix == nil || int(d.PrefixLength) > addrlen*8 {
+		return nil, fmt.Errorf("invalid BGP-LS prefix %s/%d", d.Prefix, d.PrefixLength)
+	}
+	buf := []byte{d.PrefixLength}
+	buf = append(buf, prefix[:(d.PrefixLength+7)/8]...)
+	tlvs = append(tlvs, NewLsTLV(LS_TLV_IP_REACHABILITY_INFO, buf))
+	return tlvs, nil
+}
+
+func (d *LsPrefixDescriptor) String() string {
+	buf := bytes.NewBuffer(nil)
+	if d.MultiTopologyIDs != nil {
+		buf.WriteString(fmt.Sprintf("[mt:%v]", d.MultiTopologyIDs))
+	}
+	if d.OspfRouteType != nil {
+		buf.WriteString(fmt.Sprintf("[ospf-route-type:%d]", *d.OspfRouteType))
+	}
+	buf.WriteString(fmt.Sprintf("[prefix:%s/%d]", d.Prefix, d.PrefixLength))
+	return buf.String()
+}
+
+type LsNLRIInterface interface {
+	DecodeFromBytes([]byte) error
+	Serialize() ([]byte, error)
+	String() string
+}
+
+// LsNLRIHeader is the part shared by node, link and prefix NLRIs
+type LsNLRIHeader struct {
+	ProtocolID LsProtocolID
+	Identifier uint64
+	LocalNode  LsNodeDescriptor
+}
+
+func (h *LsNLRIHeader) decodeFromBytes(data []byte) ([]*LsTLV, error) {
+	if len(data) < 9 {
+		return nil, fmt.Errorf("Not all BGP-LS NLRI bytes available")
+	}
+	h.ProtocolID = LsProtocolID(data[0])
+	h.Identifier = binary.BigEndian.Uint64(data[1:9])
+	tlvs, err := decodeLsTLVs(data[9:])
+	if err != nil {
+		return nil, err
+	}
+	if len(tlvs) == 0 || tlvs[0].Type != LS_TLV_LOCAL_NODE_DESC {
+		return nil, fmt.Errorf("BGP-LS NLRI has no local node descriptors")
+	}
+	if err := h.LocalNode.DecodeFromBytes(tlvs[0].Value); err != nil {
+		return nil, err
+	}
+	return tlvs[1:], nil
+}
+
+func (h *LsNLRIHeader) serialize(tlvs []*LsTLV) ([]byte, error) {
+	nbuf, err := h.LocalNode.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	tlvs = append(tlvs, NewLsTLV(LS_TLV_LOCAL_NODE_DESC, nbuf))
+	tbuf, err := serializeLsTLVs(tlvs)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 9)
+	buf[0] = uint8(h.ProtocolID)
+	binary.BigEndian.PutUint64(buf[1:9], h.Identifier)
+	return append(buf, tbuf...), nil
+}
+
+func (h *LsNLRIHeader) String() string {
+	return fmt.Sprintf("[protocol:%s][id:%d][local:%s]", h.ProtocolID, h.Identifier, &h.LocalNode)
+}
+
+type LsNodeNLRI struct {
+	LsNLRIHeader
+}
+
+func (n *LsNodeNLRI) DecodeFromBytes(data []byte) error {
+	tlvs, err := n.decodeFromBytes(data)
+	if err != nil {
+		return err
+	}
+	if len(tlvs) > 0 {
+		return fmt.Errorf("unexpected TLV %d in BGP-LS node NLRI", tlvs[0].Type)
+	}
+	return nil
+}
+
+func (n *LsNodeNLRI) Serialize() ([]byte, error) {
+	return n.serialize(nil)
+}
+
+type LsLinkNLRI struct {
+	LsNLRIHeader
+	RemoteNode LsNodeDescriptor
+	Link       LsLinkDescriptor
+}
+
+func (n *LsLinkNLRI) DecodeFromBytes(data []byte) error {
+	tlvs, err := n.decodeFromBytes(data)
+	if err != nil {
+		return err
+	}
+	if len(tlvs) == 0 || tlvs[0].Type != LS_TLV_REMOTE_NODE_DESC {
+		return fmt.Errorf("BGP-LS link NLRI has no remote node descriptors")
+	}
+	if err := n.RemoteNode.DecodeFromBytes(tlvs[0].Value); err != nil {
+		return err
+	}
+	for _, t := range tlvs[1:] {
+		if err := n.Link.decodeTLV(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *LsLinkNLRI) String() string {
+	return fmt.Sprintf("%s[remote:%s]%s", &n.LsNLRIHeader, &n.RemoteNode, &n.Link)
+}
+
+func (n *LsLinkNLRI) Serialize() ([]byte, error) {
+	nbuf, err := n.RemoteNode.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	tlvs := append(n.Link.tlvs(), NewLsTLV(LS_TLV_REMOTE_NODE_DESC, nbuf))
+	return n.serialize(tlvs)
+}
+
+type LsPrefixNLRI struct {
+	LsNLRIHeader
+	Prefix  LsPrefixDescriptor
+	addrlen int
+}
+
+func (n *LsPrefixNLRI) DecodeFromBytes(data []byte) error {
+	tlvs, err := n.decodeFromBytes(data)
+	if err != nil {
+		return err
+	}
+	for _, t := range tlvs {
+		if err := n.Prefix.decodeTLV(t, n.addrlen); err != nil {
+			return err
+		}
+	}
+	if n.Prefix.Prefix == nil {
+		return fmt.Errorf("BGP-LS prefix NLRI has no IP reachability information")
+	}
+	return nil
+}
+
+func (n *LsPrefixNLRI) String() string {
+	return fmt.Sprintf("%s%s", &n.LsNLRIHeader, &n.Prefix)
+}
+
+func (n *LsPrefixNLRI) Serialize() ([]byte, error) {
+	tlvs, err := n.Prefix.tlvs(n.addrlen)
+	if err != nil {
+		return nil, err
+	}
+	return n.serialize(tlvs)
+}
+
+type LsUnknownNLRI struct {
+	Value []byte
+}
+
+func (n *LsUnknownNLRI) DecodeFromBytes(data []byte) error {
+	n.Value = data
+	return nil
+}
+
+func (n *LsUnknownNLRI) Serialize() ([]byte, error) {
+	return n.Value, nil
+}
+
+func (n *LsUnknownNLRI) String() string {
+	return fmt.Sprintf("[value:%x]", n.Value)
+}
+
+func getLsNLRI(t LsNLRIType) LsNLRIInterface {
+	switch t {
+	case LS_NLRI_TYPE_NODE:
+		return &LsNodeNLRI{}
+	case LS_NLRI_TYPE_LINK:
+		return &LsLinkNLRI{}
+	case LS_NLRI_TYPE_PREFIX_IPV4:
+		return &LsPrefixNLRI{addrlen: net.IPv4len}
+	case LS_NLRI_TYPE_PREFIX_IPV6:
+		return &LsPrefixNLRI{addrlen: net.IPv6len}
+	}
+	return &LsUnknownNLRI{}
+}
+
+type LsNLRI struct {
+	NLRIType LsNLRIType
+	Length   uint16
+	RD       RouteDistinguisherInterface
+	NLRI     LsNLRIInterface
+	vpn      bool
+}
+
+func NewLsNLRI(t LsNLRIType, nlri LsNLRIInterface) *LsNLRI {
+	return &LsNLRI{NLRIType: t, NLRI: nlri}
+}
+
+func NewLsVPNNLRI(t LsNLRIType, rd RouteDistinguisherInterface, nlri LsNLRIInterface) *LsNLRI {
+	return &LsNLRI{NLRIType: t, RD: rd, NLRI: nlri, vpn: true}
+}
+
+func (n *LsNLRI) DecodeFromBytes(data []byte) error {
+	if len(data) < 4 {
+		return fmt.Errorf("Not all BGP-LS NLRI bytes available")
+	}
+	n.NLRIType = LsNLRIType(binary.BigEndian.Uint16(data[0:2]))
+	n.Length = binary.BigEndian.Uint16(data[2:4])
+	data = data[4:]
+	if len(data) < int(n.Length) {
+		return fmt.Errorf("Not all BGP-LS NLRI bytes available")
+	}
+	data = data[:n.Length]
+	if n.vpn {
+		if len(data) < 8 {
+			return fmt.Errorf("Not all BGP-LS NLRI bytes available")
+		}
+		n.RD = getRouteDistinguisher(data)
+		data = data[8:]
+	}
+	n.NLRI = getLsNLRI(n.NLRIType)
+	return n.NLRI.DecodeFromBytes(data)
+}
+
+func (n *LsNLRI) Serialize() ([]byte, error) {
+	if n.NLRI == nil {
+		return nil, fmt.Errorf("BGP-LS NLRI has no value")
+	}
+	if p, ok := n.NLRI.(*LsPrefixNLRI); ok && p.addrlen == 0 {
+		p.addrlen = net.IPv4len
+		if n.NLRIType == LS_NLRI_TYPE_PREFIX_IPV6 {
+			p.addrlen = net.IPv6len
+		}
+	}
+	var buf []byte
+	if n.vpn {
+		if n.RD == nil {
+			return nil, fmt.Errorf("BGP-LS VPN NLRI has no route distinguisher")
+		}
+		rbuf, err := n.RD.Serialize()
+		if err != nil {
+			return nil, err
+		}
+		buf = rbuf
+	}
+	nbuf, err := n.NLRI.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	buf = append(buf, nbuf...)
+	if len(buf) > math.MaxUint16 {
+		return nil, fmt.Errorf("BGP-LS NLRI is too long")
+	}
+	n.Length = uint16(len(buf))
+	hbuf := make([]byte, 4)
+	binary.BigEndian.PutUint16(hbuf[0:2], uint16(n.NLRIType))
+	binary.BigEndian.PutUint16(hbuf[2:4], n.Length)
+	return append(hbuf, buf...), nil
+}
+
+func (n *LsNLRI) Len() int {
+	return int(n.Length) + 4
+}
+
+func (n *LsNLRI) String() string {
+	buf := bytes.NewBuffer(nil)
+	buf.WriteString(fmt.Sprintf("[type:%s]", n.NLRIType))
+	if n.RD != nil {
+		buf.WriteString(fmt.Sprintf("[rd:%s]", n.RD))
+	}
+	if n.NLRI != nil {
+		buf.WriteString(n.NLRI.String())
+	}
+	return buf.String()
+}
+
+// SR Policy  RFC 9830 2.1
+type SRPolicyNLRI struct {
+	Length        uint8
+	Distinguisher uint32
+	Color         uint32
+	Endpoint      net.IP
+}
+
+func NewSRPolicyNLRI(distinguisher, color uint32, endpoint net.IP) *SRPolicyNLRI {
+	return &SRPolicyNLRI{
+		Distinguisher: distinguisher,
+		Color:         color,
+		Endpoint:      endpoint,
+	}
+}
+
+func (n *SRPolicyNLRI) DecodeFromBytes(data []byte) error {
+	if len(data) < 1 {
+		return fmt.Errorf("Not all SRPolicyNLRI bytes available")
+	}
+	n.Length = data[0]
+	if n.Length != 96 && n.Length != 192 {
+		return fmt.Errorf("invalid SRPolicyNLRI length %d", n.Length)
+	}
+	data = data[1:]
+	if len(data) < int(n.Length)/8 {
+		return fmt.Errorf("Not all SRPolicyNLRI bytes available")
+	}
+	n.Distinguisher = binary.BigEndian.Uint32(data[0:4])
+	n.Color = binary.BigEndian.Uint32(data[4:8])
+	n.Endpoint = net.IP(data[8 : n.Length/8])
+	return nil
+}
+
+func (n *SRPolicyNLRI) Serialize() ([]byte, error) {
+	_, endpoint := evpnIPSerialize(n.Endpoint)
+	if endpoint == nil {
+		return nil, fmt.Errorf("SRPolicyNLRI has no endpoint")
+	}
+	buf := make([]byte, 9)
+	binary.BigEndian.PutUint32(buf[1:5], n.Distinguisher)
+	binary.BigEndian.PutUint32(buf[5:9], n.Color)
+	buf = append(buf, endpoint...)
+	n.Length = uint8(8 * (len(buf) - 1))
+	buf[0] = n.Length
+	return buf, nil
+}
+
+func (n *SRPolicyNLRI) Len() int {
+	return 1 + int(n.Length)/8
+}
+
+func (n *SRPolicyNLRI) String() string {
+	return fmt.Sprintf("[distinguisher:%d][color:%d][endpoint:%s]", n.Distinguisher, n.Color, n.Endpoint)
+}
+
+// Flow Specification  RFC 8955, RFC 8956
+
+type BGPFlowSpecType uint8
+
+const (
+	FLOW_SPEC_TYPE_UNKNOWN BGPFlowSpecType = iota
+	FLOW_SPEC_TYPE_DST_PREFIX
+	FLOW_SPEC_TYPE_SRC_PREFIX
+	FLOW_SPEC_TYPE_IP_PROTO
+	FLOW_SPEC_TYPE_PORT
+	FLOW_SPEC_TYPE_DST_PORT
+	FLOW_SPEC_TYPE_SRC_PORT
+	FLOW_SPEC_TYPE_ICMP_TYPE
+	FLOW_SPEC_TYPE_ICMP_CODE
+	FLOW_SPEC_TYPE_TCP_FLAG
+	FLOW_SPEC_TYPE_PKT_LEN
+	FLOW_SPEC_TYPE_DSCP
+	FLOW_SPEC_TYPE_FRAGMENT
+	FLOW_SPEC_TYPE_LABEL
+)
+
+var flowSpecTypeNames = map[BGPFlowSpecType]string{
+	FLOW_SPEC_TYPE_DST_PREFIX: "destination",
+	FLOW_SPEC_TYPE_SRC_PREFIX: "source",
+	FLOW_SPEC_TYPE_IP_PROTO:   "protocol",
+	FLOW_SPEC_TYPE_PORT:       "port",
+	FLOW_SPEC_TYPE_DST_PORT:   "destination-port",
+	FLOW_SPEC_TYPE_SRC_PORT:   "source-port",
+	FLOW_SPEC_TYPE_ICMP_TYPE:  "icmp-type",
+	FLOW_SPEC_TYPE_ICMP_CODE:  "icmp-code",
+	FLOW_SPEC_TYPE_TCP_FLAG:   "tcp-flags",
+	FLOW_SPEC_TYPE_PKT_LEN:    "packet-length",
+	FLOW_SPEC_TYPE_DSCP:       "dscp",
+	FLOW_SPEC_TYPE_FRAGMENT:   "fragment",
+	FLOW_SPEC_TYPE_LABEL:      "label",
+}
+
+func (t BGPFlowSpecType) String() string {
+	if name, ok := flowSpecTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("%d", uint8(t))
+}
+
+// operator byte of the numeric and bitmask components
+const (
+	FLOW_SPEC_OP_END      = 0x80
+	FLOW_SPEC_OP_AND      = 0x40
+	FLOW_SPEC_OP_LEN_MASK = 0x30
+)
+
+const (
+	FLOW_SPEC_NUM_OP_FALSE = 0x00
+	FLOW_SPEC_NUM_OP_EQ    = 0x01
+	FLOW_SPEC_NUM_OP_GT    = 0x02
+	FLOW_SPEC_NUM_OP_GT_EQ = 0x03
+	FLOW_SPEC_NUM_OP_LT    = 0x04
+	FLOW_SPEC_NUM_OP_LT_EQ = 0x05
+	FLOW_SPEC_NUM_OP_NE    = 0x06
+	FLOW_SPEC_NUM_OP_TRUE  = 0x07
+)
+
+const (
+	FLOW_SPEC_BITMASK_OP_MATCH = 0x01
+	FLOW_SPEC_BITMASK_OP_NOT   = 0x02
+)
+
+// bits of the TCP flags component
+const (
+	TCP_FLAG_FIN = 0x01
+	TCP_FLAG_SYN = 0x02
+	TCP_FLAG_RST = 0x04
+	TCP_FLAG_PSH = 0x08
+	TCP_FLAG_ACK = 0x10
+	TCP_FLAG_URG = 0x20
+	TCP_FLAG_ECE = 0x40
+	TCP_FLAG_CWR = 0x80
+)
+
+// bits of the fragment component
+const (
+	FRAG_FLAG_DONT      = 0x01
+	FRAG_FLAG_IS        = 0x02
+	FRAG_FLAG_FIRST     = 0x04
+	FRAG_FLAG_LAST      = 0x08
+	FRAG_FLAG_ALL_VALID = FRAG_FLAG_DONT | FRAG_FLAG_IS | FRAG_FLAG_FIRST | FRAG_FLAG_LAST
+)
+
+type FlowSpecComponentInterface interface {
+	DecodeFromBytes([]byte) error
+	Serialize() ([]byte, error)
+	Len() int
+	Type() BGPFlowSpecType
+	String() string
+}
+
+// FlowSpecPrefix is the destination or source prefix component. Offset
+// is only used for IPv6, where the component matches Length-Offset bits
+// of the address starting at bit Offset.
+type FlowSpecPrefix struct {
+	type_   BGPFlowSpecType
+	Length  uint8
+	Offset  uint8
+	Prefix  net.IP
+	addrlen uint8
+}
+
+func NewFlowSpecDestinationPrefix(length uint8, prefix net.IP) *FlowSpecPrefix {
+	return newFlowSpecPrefix(FLOW_SPEC_TYPE_DST_PREFIX, length, 0, prefix)
+}
+
+func NewFlowSpecSourcePrefix(length uint8, prefix net.IP) *FlowSpecPrefix {
+	return newFlowSpecPrefix(FLOW_SPEC_TYPE_SRC_PREFIX, length, 0, prefix)
+}
+
+func NewFlowSpecDestinationPrefix6(length, offset uint8, prefix net.IP) *FlowSpecPrefix {
+	return newFlowSpecPrefix(FLOW_SPEC_TYPE_DST_PREFIX, length, offset, prefix)
+}
+
+func NewFlowSpecSourcePrefix6(length, offset uint8, prefix net.IP) *FlowSpecPrefix {
+	return newFlowSpecPrefix(FLOW_SPEC_TYPE_SRC_PREFIX, length, offset, prefix)
+}
+
+func newFlowSpecPrefix(t BGPFlowSpecType, length, offset uint8, prefix net.IP) *FlowSpecPrefix {
+	p := &FlowSpecPrefix{type_: t, Length: length, Offset: offset}
+	if ip := prefix.To4(); ip != nil && offset == 0 {
+		p.addrlen = 4
+		p.Prefix = ip
+	} else {
+		p.addrlen = 16
+		p.Prefix = prefix.To16()
+	}
+	return p
+}
+
+func getBit(b []byte, i int) bool {
+	return b[i/8]&(0x80>>uint(i%8)) != 0
+}
+
+func setBit(b []byte, i int) {
+	b[i/8] |= 0x80 >> uint(i%8)
+}
+
+func (p *FlowSpecPrefix) DecodeFromBytes(data []byte) error {
+	if len(data) < 2 {
+		return fmt.Errorf("Not all FlowSpecPrefix bytes available")
+	}
+	p.type_ = BGPFlowSpecType(data[0])
+	p.Length = data[1]
+	data = data[2:]
+	if p.addrlen == 16 {
+		if len(data) < 1 {
+			return fmt.Errorf("Not all FlowSpecPrefix bytes available")
+		}
+		p.Offset = data[0]
+		data = data[1:]
+	} else {
+		p.addrlen = 4
+	}
+	if p.Length > 8*p.addrlen || p.Offset > p.Length {
+		return fmt.Errorf("invalid FlowSpecPrefix length %d offset %d", p.Length, p.Offset)
+	}
+	bitlen := int(p.Length - p.Offset)
+	if len(data) < (bitlen+7)/8 {
+		return fmt.Errorf("Not all FlowSpecPrefix bytes available")
+	}
+	b := make([]byte, p.addrlen)
+	for i := 0; i < bitlen; i++ {
+		if getBit(data, i) {
+			setBit(b, int(p.Offset)+i)
+		}
+	}
+	p.Prefix = b
+	return nil
+}
+
+func (p *FlowSpecPrefix) Serialize() ([]byte, error) {
+	if p.addrlen == 0 {
+		p.addrlen = 4
+	}
+	if p.Length > 8*p.addrlen || p.Offset > p.Length || len(p.Prefix) < int(p.addrlen) {
+		return nil, fmt.Errorf("invalid FlowSpecPrefix %s/%d offset %d", p.Prefix, p.Length, p.Offset)
+	}
+	buf := []byte{uint8(p.type_), p.Length}
+	if p.addrlen == 16 {
+		buf = append(buf, p.Offset)
+	} else if p.Offset != 0 {
+		return nil, fmt.Errorf("IPv4 FlowSpecPrefix can not have an offset")
+	}
+	bitlen := int(p.Length - p.Offset)
+	b := make([]byte, (bitlen+7)/8)
+	for i := 0; i < bitlen; i++ {
+		if getBit(p.Prefix, int(p.Offset)+i) {
+			setBit(b, i)
+		}
+	}
+	return append(buf, b...), nil
+}
+
+func (p *FlowSpecPrefix) Len() int {
+	l := 2 + (int(p.Length-p.Offset)+7)/8
+	if p.addrlen == 16 {
+		l++
+	}
+	return l
+}
+
+func (p *FlowSpecPrefix) Type() BGPFlowSpecType {
+	return p.type_
+}
+
+func (p *FlowSpecPrefix) String() string {
+	if p.Offset != 0 {
+		return fmt.Sprintf("[%s: %s/%d/%d]", p.type_, p.Prefix, p.Length, p.Offset)
+	}
+	return fmt.Sprintf("[%s: %s/%d]", p.type_, p.Prefix, p.Length)
+}
+
+type FlowSpecComponentItem struct {
+	Op    uint8
+	Value uint64
+	// length is the size of the value on the wire, a decoded value may
+	// use more bytes than it needs. Zero picks the smallest size.
+	length int
+}
+
+func NewFlowSpecComponentItem(op uint8, value uint64) *FlowSpecComponentItem {
+	return &FlowSpecComponentItem{Op: op, Value: value}
+}
+
+func (i *FlowSpecComponentItem) valueLen() int {
+	if i.length != 0 && (i.length == 8 || i.Value < 1<<(8*uint(i.length))) {
+		return i.length
+	}
+	switch {
+	case i.Value <= math.MaxUint8:
+		return 1
+	case i.Value <= math.MaxUint16:
+		return 2
+	case i.Value <= math.MaxUint32:
+		return 4
+	}
+	return 8
+}
+
+// FlowSpecComponent is any component made of a list of numeric or bitmask
+// operator and value pairs. Op of each item holds the AND bit and the
+// comparison bits; the end-of-list and length bits are computed.
+type FlowSpecComponent struct {
+	type_ BGPFlowSpecType
+	Items []*FlowSpecComponentItem
+}
+
+func NewFlowSpecComponent(t BGPFlowSpecType, items []*FlowSpecComponentItem) *FlowSpecComponent {
+	return &FlowSpecComponent{t, items}
+}
+
+func (p *FlowSpecComponent) isBitmask() bool {
+	return p.type_ == FLOW_SPEC_TYPE_TCP_FLAG || p.type_ == FLOW_SPEC_TYPE_FRAGMENT
+}
+
+func (p *FlowSpecComponent) DecodeFromBytes(data []byte) error {
+	if len(data) < 1 {
+		return fmt.Errorf("Not all FlowSpecComponent bytes available")
+	}
+	p.type_ = BGPFlowSpecType(data[0])
+	data = data[1:]
+	p.Items = nil
+	for {
+		if len(data) < 1 {
+			return fmt.Errorf("Not all FlowSpecComponent bytes available")
+		}
+		op := data[0]
+		l := 1 << ((op & FLOW_SPEC_OP_LEN_MASK) >> 4)
+		if len(data) < 1+l {
+			return fmt.Errorf("Not all FlowSpecComponent bytes available")
+		}
+		var value uint64
+		for _, b := range data[1 : 1+l] {
+			value = value<<8 | uint64(b)
+		}
+		p.Items = append(p.Items, &FlowSpecComponentItem{
+			Op:     op &^ (FLOW_SPEC_OP_END | FLOW_SPEC_OP_LEN_MASK),
+			Value:  value,
+			length: l,
+		})
+		data = data[1+l:]
+		if op&FLOW_SPEC_OP_END != 0 {
+			break
+		}
+	}
+	return p.validate()
+}
+
+func (p *FlowSpecComponent) validate() error {
+	if len(p.Items) == 0 {
+		return fmt.Errorf("%s component has no values", p.type_)
+	}
+	for _, item := range p.Items {
+		if item.Op&0x08 != 0 || (p.isBitmask() && item.Op&0x0c != 0) {
+			return fmt.Errorf("%s component has reserved operator bits set: 0x%02x", p.type_, item.Op)
+		}
+		switch p.type_ {
+		case FLOW_SPEC_TYPE_IP_PROTO, FLOW_SPEC_TYPE_ICMP_TYPE, FLOW_SPEC_TYPE_ICMP_CODE:
+			if item.Value > math.MaxUint8 {
+				return fmt.Errorf("%s value %d is out of range", p.type_, item.Value)
+			}
+		case FLOW_SPEC_TYPE_PORT, FLOW_SPEC_TYPE_DST_PORT, FLOW_SPEC_TYPE_SRC_PORT,
+			FLOW_SPEC_TYPE_PKT_LEN, FLOW_SPEC_TYPE_TCP_FLAG:
+			if item.Value > math.MaxUint16 {
+				return fmt.Errorf("%s value %d is out of range", p.type_, item.Value)
+			}
+		case FLOW_SPEC_TYPE_DSCP:
+			if item.Value > 0x3f {
+				return fmt.Errorf("%s value %d is out of range", p.type_, item.Value)
+			}
+		case FLOW_SPEC_TYPE_FRAGMENT:
+			if item.Value&^FRAG_FLAG_ALL_VALID != 0 {
+				return fmt.Errorf("%s value 0x%x has unknown bits", p.type_, item.Value)
+			}
+		case FLOW_SPEC_TYPE_LABEL:
+			if item.Value > 0xfffff {
+				return fmt.Errorf("%s value %d is out of range", p.type_, item.Value)
+			}
+		}
+	}
+	return nil
+}
+
+func (p *FlowSpecComponent) Serialize() ([]byte, error) {
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	buf := []byte{uint8(p.type_)}
+	for n, item := range p.Items {
+		l := item.valueLen()
+		op := item.Op &^ (FLOW_SPEC_OP_END | FLOW_SPEC_OP_LEN_MASK)
+		if n == 0 {
+			// the first value can not be ANDed with anything
+			op &^= FLOW_SPEC_OP_AND
+		}
+		if n == len(p.Items)-1 {
+			op |= FLOW_SPEC_OP_END
+		}
+		switch l {
+		case 2:
+			op |= 0x10
+		case 4:
+			op |= 0x20
+		case 8:
+			op |= 0x30
+		}
+		buf = append(buf, op)
+		for i := l - 1; i >= 0; i-- {
+			buf = append(buf, byte(item.Value>>(8*uint(i))))
+		}
+	}
+	return buf, nil
+}
+
+func (p *FlowSpecComponent) Len() int {
+	l := 1
+	for _, item := range p.Items {
+		l += 1 + item.valueLen()
+	}
+	return l
+}
+
+func (p *FlowSpecComponent) Type() BGPFlowSpecType {
+	return p.type_
+}
+
+var flowSpecNumOpNames = map[uint8]string{
+	FLOW_SPEC_NUM_OP_FALSE: "false",
+	FLOW_SPEC_NUM_OP_EQ:    "==",
+	FLOW_SPEC_NUM_OP_GT:    ">",
+	FLOW_SPEC_NUM_OP_GT_EQ: ">=",
+	FLOW_SPEC_NUM_OP_LT:    "<",
+	FLOW_SPEC_NUM_OP_LT_EQ: "<=",
+	FLOW_SPEC_NUM_OP_NE:    "!=",
+	FLOW_SPEC_NUM_OP_TRUE:  "true",
+}
+
+var flowSpecBitmaskOpNames = map[uint8]string{
+	0:                          "",
+	FLOW_SPEC_BITMASK_OP_MATCH: "=",
+	FLOW_SPEC_BITMASK_OP_NOT:   "!",
+	FLOW_SPEC_BITMASK_OP_NOT | FLOW_SPEC_BITMASK_OP_MATCH: "!=",
+}
+
+func (p *FlowSpecComponent) String() string {
+	buf := bytes.NewBufferString(fmt.Sprintf("[%s:", p.type_))
+	for n, item := range p.Items {
+		if n > 0 && item.Op&FLOW_SPEC_OP_AND != 0 {
+			buf.WriteString("&")
+		} else {
+			buf.WriteString(" ")
+		}
+		if p.isBitmask() {
+			buf.WriteString(fmt.Sprintf("%s0x%x", flowSpecBitmaskOpNames[item.Op&0x03], item.Value))
+			continue
+		}
+		op := item.Op & 0x07
+		if op == FLOW_SPEC_NUM_OP_TRUE || op == FLOW_SPEC_NUM_OP_FALSE {
+			buf.WriteString(flowSpecNumOpNames[op])
+			continue
+		}
+		buf.WriteString(fmt.Sprintf("%s%d", flowSpecNumOpNames[op], item.Value))
+	}
+	buf.WriteString("]")
+	return buf.String()
+}
+
+type FlowSpecUnknown struct {
+	Value []byte
+}
+
+func (p *FlowSpecUnknown) DecodeFromBytes(data []byte) error {
+	p.Value = data
+	return nil
+}
+
+func (p *FlowSpecUnknown) Serialize() ([]byte, error) {
+	return p.Value, nil
+}
+
+func (p *FlowSpecUnknown) Len() int {
+	return len(p.Value)
+}
+
+func (p *FlowSpecUnknown) Type() BGPFlowSpecType {
+	if len(p.Value) > 0 {
+		return BGPFlowSpecType(p.Value[0])
+	}
+	return FLOW_SPEC_TYPE_UNKNOWN
+}
+
+func (p *FlowSpecUnknown) String() string {
+	return fmt.Sprintf("[unknown:%x]", p.Value)
+}
+
+type FlowSpecNLRI struct {
+	Value   []FlowSpecComponentInterface
+	RD      RouteDistinguisherInterface
+	afi     uint16
+	safi    uint8
+	nlriLen int
+}
+
+func (n *FlowSpecNLRI) addrlen() uint8 {
+	if n.afi == AFI_IP6 {
+		return 16
+	}
+	return 4
+}
+
+func (n *FlowSpecNLRI) DecodeFromBytes(data []byte) error {
+	if len(data) < 1 {
+		return fmt.Errorf("Not all FlowSpecNLRI bytes available")
+	}
+	length := int(data[0])
+	data = data[1:]
+	n.nlriLen = 1
+	if length >= 0xf0 {
+		if len(data) < 1 {
+			return fmt.Errorf("Not all FlowSpecNLRI bytes available")
+		}
+		length = (length&0x0f)<<8 | int(data[0])
+		data = data[1:]
+		n.nlriLen = 2
+	}
+	if len(data) < length {
+		return fmt.Errorf("Not all FlowSpecNLRI bytes available")
+	}
+	n.nlriLen += length
+	data = data[:length]
+	if n.safi == SAFI_FLOW_SPEC_VPN {
+		if len(data) < 8 {
+			return fmt.Errorf("Not all FlowSpecNLRI bytes available")
+		}
+		n.RD = getRouteDistinguisher(data)
+		data = data[n.RD.Len():]
+	}
+	n.Value = nil
+	for len(data) > 0 {
+		var c FlowSpecComponentInterface
+		switch BGPFlowSpecType(data[0]) {
+		case FLOW_SPEC_TYPE_DST_PREFIX, FLOW_SPEC_TYPE_SRC_PREFIX:
+			c = &FlowSpecPrefix{addrlen: n.addrlen()}
+		case FLOW_SPEC_TYPE_IP_PROTO, FLOW_SPEC_TYPE_PORT, FLOW_SPEC_TYPE_DST_PORT,
+			FLOW_SPEC_TYPE_SRC_PORT, FLOW_SPEC_TYPE_ICMP_TYPE, FLOW_SPEC_TYPE_ICMP_CODE,
+			FLOW_SPEC_TYPE_TCP_FLAG, FLOW_SPEC_TYPE_PKT_LEN, FLOW_SPEC_TYPE_DSCP,
+			FLOW_SPEC_TYPE_FRAGMENT, FLOW_SPEC_TYPE_LABEL:
+			c = &FlowSpecComponent{}
+		default:
+			// the length of an unknown component can't be told, so it
+			// takes the rest of the NLRI
+			c = &FlowSpecUnknown{}
+		}
+		err := c.DecodeFromBytes(data)
+		if err != nil {
+			return err
+		}
+		n.Value = append(n.Value, c)
+		data = data[c.Len():]
+	}
+	return n.Validate()
+}
+
+// Validate checks the rules of RFC 8955 4.2 and RFC 8956 3: components
+// appear at most once and in increasing type order, and the flow label
+// component is only used for IPv6.
+func (n *FlowSpecNLRI) Validate() error {
+	if len(n.Value) == 0 {
+		return fmt.Errorf("FlowSpecNLRI has no components")
+	}
+	var last BGPFlowSpecType
+	for _, c := range n.Value {
+		t := c.Type()
+		if t <= last {
+			return fmt.Errorf("FlowSpecNLRI component %s is out of order", t)
+		}
+		last = t
+		switch v := c.(type) {
+		case *FlowSpecPrefix:
+			if v.addrlen != n.addrlen() {
+				return fmt.Errorf("FlowSpecNLRI %s prefix is not of the NLRI family", t)
+			}
+		case *FlowSpecComponent:
+			if t == FLOW_SPEC_TYPE_LABEL && n.afi != AFI_IP6 {
+				return fmt.Errorf("FlowSpecNLRI flow label component is only valid for IPv6")
+			}
+		}
+	}
+	if n.safi == SAFI_FLOW_SPEC_VPN && n.RD == nil {
+		return fmt.Errorf("FlowSpecNLRI has no route distinguisher")
+	}
+	return nil
+}
+
+func (n *FlowSpecNLRI) Serialize() ([]byte, error) {
+	if err := n.Validate(); err != nil {
+		return nil, err
+	}
+	var buf []byte
+	if n.safi == SAFI_FLOW_SPEC_VPN {
+		b, err := n.RD.Serialize()
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, b...)
+	}
+	for _, c := range n.Value {
+		b, err := c.Serialize()
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, b...)
+	}
+	length := len(buf)
+	switch {
+	case length < 0xf0:
+		buf = append([]byte{byte(length)}, buf...)
+		n.nlriLen = 1 + length
+	case length <= 0xfff:
+		buf = append([]byte{0xf0 | byte(length>>8), byte(length)}, buf...)
+		n.nlriLen = 2 + length
+	default:
+		return nil, fmt.Errorf("FlowSpecNLRI is too long: %d", length)
+	}
+	return buf, nil
+}
+
+func (n *FlowSpecNLRI) Len() int {
+	return n.nlriLen
+}
+
+func (n *FlowSpecNLRI) String() string {
+	buf := bytes.NewBuffer(nil)
+	if n.RD != nil {
+		buf.WriteString(fmt.Sprintf("[rd: %s]", n.RD))
+	}
+	for _, c := range n.Value {
+		buf.WriteString(c.String())
+	}
+	return buf.String()
+}
+
+type FlowSpecIPv4Unicast struct {
+	FlowSpecNLRI
+}
+
+func NewFlowSpecIPv4Unicast(value []FlowSpecComponentInterface) *FlowSpecIPv4Unicast {
+	n := &FlowSpecIPv4Unicast{}
+	n.Value = value
+	n.afi = AFI_IP
+	n.safi = SAFI_FLOW_SPEC_UNICAST
+	return n
+}
+
+type FlowSpecIPv6Unicast struct {
+	FlowSpecNLRI
+}
+
+func NewFlowSpecIPv6Unicast(value []FlowSpecComponentInterface) *FlowSpecIPv6Unicast {
+	n := &FlowSpecIPv6Unicast{}
+	n.Value = value
+	n.afi = AFI_IP6
+	n.safi = SAFI_FLOW_SPEC_UNICAST
+	return n
+}
+
+type FlowSpecIPv4VPN struct {
+	FlowSpecNLRI
+}
+
+func NewFlowSpecIPv4VPN(rd RouteDistinguisherInterface, value []FlowSpecComponentInterface) *FlowSpecIPv4VPN {
+	n := &FlowSpecIPv4VPN{}
+	n.RD = rd
+	n.Value = value
+	n.afi = AFI_IP
+	n.safi = SAFI_FLOW_SPEC_VPN
+	return n
+}
+
+type FlowSpecIPv6VPN struct {
+	FlowSpecNLRI
+}
+
+func NewFlowSpecIPv6VPN(rd RouteDistinguisherInterface, value []FlowSpecComponentInterface) *FlowSpecIPv6VPN {
+	n := &FlowSpecIPv6VPN{}
+	n.RD = rd
+	n.Value = value
+	n.afi = AFI_IP6
+	n.safi = SAFI_FLOW_SPEC_VPN
+	return n
+}
+
+func rfshift(afi uint16, safi uint8) int {
+	return int(afi)<<16 | int(safi)
+}
+
+const (
+	RF_IPv4_UC        = AFI_IP<<16 | SAFI_UNICAST
+	RF_IPv6_UC        = AFI_IP6<<16 | SAFI_UNICAST
+	RF_IPv4_MC        = AFI_IP<<16 | SAFI_MULTICAST
+	RF_IPv6_MC        = AFI_IP6<<16 | SAFI_MULTICAST
+	RF_IPv4_VPN       = AFI_IP<<16 | SAFI_MPLS_VPN
+	RF_IPv6_VPN       = AFI_IP6<<16 | SAFI_MPLS_VPN
+	RF_IPv4_MPLS      = AFI_IP<<16 | SAFI_MPLS_LABEL
+	RF_IPv6_MPLS      = AFI_IP6<<16 | SAFI_MPLS_LABEL
+	RF_RTC_UC         = AFI_IP<<16 | SAFI_ROUTE_TARGET_CONSTRTAINS
+	RF_RTC_IPv6_UC    = AFI_IP6<<16 | SAFI_ROUTE_TARGET_CONSTRTAINS
+	RF_EVPN           = AFI_L2VPN<<16 | SAFI_EVPN
+	RF_MVPN_IPv4      = AFI_IP<<16 | SAFI_MCAST_VPN
+	RF_MVPN_IPv6      = AFI_IP6<<16 | SAFI_MCAST_VPN
+	RF_IPv4_VPN_MC    = AFI_IP<<16 | SAFI_MPLS_VPN_MULTICAST
+	RF_IPv6_VPN_MC    = AFI_IP6<<16 | SAFI_MPLS_VPN_MULTICAST
+	RF_VPLS           = AFI_L2VPN<<16 | SAFI_VPLS
+	RF_LS             = AFI_LS<<16 | SAFI_LS
+	RF_LS_VPN         = AFI_LS<<16 | SAFI_LS_VPN
+	RF_SR_POLICY_IPv4 = AFI_IP<<16 | SAFI_SR_POLICY
+	RF_SR_POLICY_IPv6 = AFI_IP6<<16 | SAFI_SR_POLICY
+	RF_FS_IPv4_UC     = AFI_IP<<16 | SAFI_FLOW_SPEC_UNICAST
+	RF_FS_IPv6_UC     = AFI_IP6<<16 | SAFI_FLOW_SPEC_UNICAST
+	RF_FS_IPv4_VPN    = AFI_IP<<16 | SAFI_FLOW_SPEC_VPN
+	RF_FS_IPv6_VPN    = AFI_IP6<<16 | SAFI_FLOW_SPEC_VPN
+)
+
+var routeFamilyNames = map[int]string{
+	RF_IPv4_UC:        "ipv4-unicast",
+	RF_IPv6_UC:        "ipv6-unicast",
+	RF_IPv4_MC:        "ipv4-multicast",
+	RF_IPv6_MC:        "ipv6-multicast",
+	RF_IPv4_VPN:       "l3vpn-ipv4-unicast",
+	RF_IPv6_VPN:       "l3vpn-ipv6-unicast",
+	RF_IPv4_MPLS:      "ipv4-labelled-unicast",
+	RF_IPv6_MPLS:      "ipv6-labelled-unicast",
+	RF_RTC_UC:         "rtc",
+	RF_RTC_IPv6_UC:    "rtc-ipv6",
+	RF_EVPN:           "l2vpn-evpn",
+	RF_MVPN_IPv4:      "ipv4-mvpn",
+	RF_MVPN_IPv6:      "ipv6-mvpn",
+	RF_IPv4_VPN_MC:    "l3vpn-ipv4-multicast",
+	RF_IPv6_VPN_MC:    "l3vpn-ipv6-multicast",
+	RF_VPLS:           "l2vpn-vpls",
+	RF_LS:             "ls",
+	RF_LS_VPN:         "ls-vpn",
+	RF_SR_POLICY_IPv4: "ipv4-srpolicy",
+	RF_SR_POLICY_IPv6: "ipv6-srpolicy",
+	RF_FS_IPv4_UC:     "ipv4-flowspec",
+	RF_FS_IPv6_UC:     "ipv6-flowspec",
+	RF_FS_IPv4_VPN:    "l3vpn-ipv4-flowspec",
+	RF_FS_IPv6_VPN:    "l3vpn-ipv6-flowspec",
+}
+
+// routeFamilyName returns the usual name of a family, or "AFI/SAFI"
+// for a family this package does not know.
+func routeFamilyName(afi uint16, safi uint8) string {
+	if name, ok := routeFamilyNames[rfshift(afi, safi)]; ok {
+		return name
+	}
+	return fmt.Sprintf("%d/%d", afi, safi)
+}
+
+// familyJSON is the JSON form of an address family. Family is the name
+// routeFamilyName gives it and is ignored when decoding.
+type familyJSON struct {
+	AFI    uint16 `json:"afi"`
+	SAFI   uint8  `json:"safi"`
+	Family string `json:"family"`
+}
+
+func newFamilyJSON(afi uint16, safi uint8) familyJSON {
+	return familyJSON{AFI: afi, SAFI: safi, Family: routeFamilyName(afi, safi)}
+}
+
+func routeFamilyPrefix(afi uint16, safi uint8) (prefix AddrPrefixInterface) {
+
+	switch rfshift(afi, safi) {
+	case RF_IPv4_UC, RF_IPv4_MC:
+		prefix = &IPAddrPrefix{}
+	case RF_IPv6_UC, RF_IPv6_MC:
+		prefix = NewIPv6AddrPrefix()
+	case RF_IPv4_VPN:
+		prefix = NewLabelledVPNIPAddrPrefix()
+	case RF_IPv6_VPN:
+		prefix = NewLabelledVPNIPv6AddrPrefix()
+	case RF_IPv4_MPLS:
+		prefix = NewLabelledIPAddrPrefix()
+	case RF_IPv6_MPLS:
+		prefix = NewLabelledIPv6AddrPrefix()
+	case RF_RTC_UC, RF_RTC_IPv6_UC:
+		prefix = &RouteTargetMembershipNLRI{}
+	case RF_EVPN:
+		prefix = &EVPNNLRI{}
+	case RF_VPLS:
+		prefix = &VPLSNLRI{}
+	case RF_MVPN_IPv4, RF_MVPN_IPv6:
+		prefix = &MVPNNLRI{}
+	case RF_IPv4_VPN_MC:
+		prefix = NewLabelledVPNIPAddrPrefix()
+	case RF_IPv6_VPN_MC:
+		prefix = NewLabelledVPNIPv6AddrPrefix()
+	case RF_LS:
+		prefix = &LsNLRI{}
+	case RF_LS_VPN:
+		prefix = &LsNLRI{vpn: true}
+	case RF_SR_POLICY_IPv4, RF_SR_POLICY_IPv6:
+		prefix = &SRPolicyNLRI{}
+	case RF_FS_IPv4_UC:
+		prefix = NewFlowSpecIPv4Unicast(nil)
+	case RF_FS_IPv6_UC:
+		prefix = NewFlowSpecIPv6Unicast(nil)
+	case RF_FS_IPv4_VPN:
+		prefix = NewFlowSpecIPv4VPN(nil, nil)
+	case RF_FS_IPv6_VPN:
+		prefix = NewFlowSpecIPv6VPN(nil, nil)
+	default:
+		prefix = &UnknownNLRI{AFI: afi, SAFI: safi}
+	}
+	return prefix
+}
+
+const (
+	BGP_ATTR_FLAG_EXTENDED_LENGTH = 1 << 4
+	BGP_ATTR_FLAG_PARTIAL         = 1 << 5
+	BGP_ATTR_FLAG_TRANSITIVE      = 1 << 6
+	BGP_ATTR_FLAG_OPTIONAL        = 1 << 7
+)
+
+const (
+	_ = iota
+	BGP_ATTR_TYPE_ORIGIN
+	BGP_ATTR_TYPE_AS_PATH
+	BGP_ATTR_TYPE_NEXT_HOP
+	BGP_ATTR_TYPE_MULTI_EXIT_DISC
+	BGP_ATTR_TYPE_LOCAL_PREF
+	BGP_ATTR_TYPE_ATOMIC_AGGREGATE
+	BGP_ATTR_TYPE_AGGREGATOR
+	BGP_ATTR_TYPE_COMMUNITIES
+	BGP_ATTR_TYPE_ORIGINATOR_ID
+	BGP_ATTR_TYPE_CLUSTER_LIST
+	_
+	_
+	_
+	BGP_ATTR_TYPE_MP_REACH_NLRI
+	BGP_ATTR_TYPE_MP_UNREACH_NLRI
+	BGP_ATTR_TYPE_EXTENDED_COMMUNITIES
+	BGP_ATTR_TYPE_AS4_PATH
+	BGP_ATTR_TYPE_AS4_AGGREGATOR
+	_
+	_
+	_
+	BGP_ATTR_TYPE_PMSI_TUNNEL
+	BGP_ATTR_TYPE_TUNNEL_ENCAP
+	_
+	BGP_ATTR_TYPE_IP6_EXTENDED_COMMUNITIES
+	BGP_ATTR_TYPE_AIGP
+	_
+	_
+	BGP_ATTR_TYPE_LS
+	_
+	_
+	BGP_ATTR_TYPE_LARGE_COMMUNITY
+	_
+	_
+	_
+	_
+	_
+	_
+	_
+	BGP_ATTR_TYPE_PREFIX_SID
+)
+
+var pathAttrTypeNames = map[uint8]string{
+	BGP_ATTR_TYPE_ORIGIN:                   "ORIGIN",
+	BGP_ATTR_TYPE_AS_PATH:                  "AS_PATH",
+	BGP_ATTR_TYPE_NEXT_HOP:                 "NEXT_HOP",
+	BGP_ATTR_TYPE_MULTI_EXIT_DISC:          "MULTI_EXIT_DISC",
+	BGP_ATTR_TYPE_LOCAL_PREF:               "LOCAL_PREF",
+	BGP_ATTR_TYPE_ATOMIC_AGGREGATE:         "ATOMIC_AGGREGATE",
+	BGP_ATTR_TYPE_AGGREGATOR:               "AGGREGATOR",
+	BGP_ATTR_TYPE_COMMUNITIES:              "COMMUNITIES",
+	BGP_ATTR_TYPE_ORIGINATOR_ID:            "ORIGINATOR_ID",
+	BGP_ATTR_TYPE_CLUSTER_LIST:             "CLUSTER_LIST",
+	BGP_ATTR_TYPE_MP_REACH_NLRI:            "MP_REACH_NLRI",
+	BGP_ATTR_TYPE_MP_UNREACH_NLRI:          "MP_UNREACH_NLRI",
+	BGP_ATTR_TYPE_EXTENDED_COMMUNITIES:     "EXTENDED_COMMUNITIES",
+	BGP_ATTR_TYPE_AS4_PATH:                 "AS4_PATH",
+	BGP_ATTR_TYPE_AS4_AGGREGATOR:           "AS4_AGGREGATOR",
+	BGP_ATTR_TYPE_PMSI_TUNNEL:              "PMSI_TUNNEL",
+	BGP_ATTR_TYPE_TUNNEL_ENCAP:             "TUNNEL_ENCAP",
+	BGP_ATTR_TYPE_IP6_EXTENDED_COMMUNITIES: "IP6_EXTENDED_COMMUNITIES",
+	BGP_ATTR_TYPE_AIGP:                     "AIGP",
+	BGP_ATTR_TYPE_LS:                       "BGP_LS",
+	BGP_ATTR_TYPE_LARGE_COMMUNITY:          "LARGE_COMMUNITY",
+	BGP_ATTR_TYPE_PREFIX_SID:               "PREFIX_SID",
+}
+
+func pathAttrTypeName(t uint8) string {
+	if name, ok := pathAttrTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("UNKNOWN_ATTR(%d)", t)
+}
+
+// codeToName returns the name a table gives to code, or code in
+// decimal if it has none. nameToCode reverses it.
+func codeToName(names map[uint8]string, code uint8) string {
+	if name, ok := names[code]; ok {
+		return name
+	}
+	return strconv.Itoa(int(code))
+}
+
+func nameToCode(names map[uint8]string, name string) (uint8, error) {
+	for code, n := range names {
+		if n == name {
+			return code, nil
+		}
+	}
+	code, err := strconv.ParseUint(name, 10, 8)
+	if err != nil {
+		return 0, fmt.Errorf("unknown name %q", name)
+	}
+	return uint8(code), nil
+}
+
+// NOTIFICATION Error Code  RFC 4271 4.5.
+const (
+	_ = iota
+	BGP_ERROR_MESSAGE_HEADER_ERROR
+	BGP_ERROR_OPEN_MESSAGE_ERROR
+	BGP_ERROR_UPDATE_MESSAGE_ERROR
+	BGP_ERROR_HOLD_TIMER_EXPIRED
+	BGP_ERROR_FSM_ERROR
+	BGP_ERROR_CEASE
+)
+
+// NOTIFICATION Error Subcode for BGP_ERROR_MESSAGE_HEADER_ERROR
+const (
+	_ = iota
+	BGP_ERROR_SUB_CONNECTION_NOT_SYNCHRONIZED
+	BGP_ERROR_SUB_BAD_MESSAGE_LENGTH
+	BGP_ERROR_SUB_BAD_MESSAGE_TYPE
+)
+
+// NOTIFICATION Error Subcode for BGP_ERROR_OPEN_MESSAGE_ERROR
+const (
+	_ = iota
+	BGP_ERROR_SUB_UNSUPPORTED_VERSION_NUMBER
+	BGP_ERROR_SUB_BAD_PEER_AS
+	BGP_ERROR_SUB_BAD_BGP_IDENTIFIER
+	BGP_ERROR_SUB_UNSUPPORTED_OPTIONAL_PARAMETER
+	BGP_ERROR_SUB_AUTHENTICATION_FAILURE
+	BGP_ERROR_SUB_UNACCEPTABLE_HOLD_TIME
+	BGP_ERROR_SUB_UNSUPPORTED_CAPABILITY
+)
+
+// BGP Role  RFC 9234
+const BGP_ERROR_SUB_ROLE_MISMATCH = 11
+
+// NOTIFICATION Error Subcode for BGP_ERROR_UPDATE_MESSAGE_ERROR
+const (
+	_ = iota
+	BGP_ERROR_SUB_MALFORMED_ATTRIBUTE_LIST
+	BGP_ERROR_SUB_UNRECOGNIZED_WELL_KNOWN_ATTRIBUTE
+	BGP_ERROR_SUB_MISSING_WELL_KNOWN_ATTRIBUTE
+	BGP_ERROR_SUB_ATTRIBUTE_FLAGS_ERROR
+	BGP_ERROR_SUB_ATTRIBUTE_LENGTH_ERROR
+	BGP_ERROR_SUB_INVALID_ORIGIN_ATTRIBUTE
+	BGP_ERROR_SUB_ROUTING_LOOP
+	BGP_ERROR_SUB_INVALID_NEXT_HOP_ATTRIBUTE
+	BGP_ERROR_SUB_OPTIONAL_ATTRIBUTE_ERROR
+	BGP_ERROR_SUB_INVALID_NETWORK_FIELD
+	BGP_ERROR_SUB_MALFORMED_AS_PATH
+)
+
+// NOTIFICATION Error Subcode for BGP_ERROR_HOLD_TIMER_EXPIRED
+const (
+	_ = iota
+	BGP_ERROR_SUB_HOLD_TIMER_EXPIRED
+)
+
+// NOTIFICATION Error Subcode for BGP_ERROR_FSM_ERROR
+const (
+	_ = iota
+	BGP_ERROR_SUB_FSM_ERROR
+)
+
+// NOTIFICATION Error Subcode for BGP_ERROR_FSM_ERROR  RFC 6608
+const (
+	_ = iota
+	BGP_ERROR_SUB_RECEIVE_UNEXPECTED_MESSAGE_IN_OPENSENT_STATE
+	BGP_ERROR_SUB_RECEIVE_UNEXPECTED_MESSAGE_IN_OPENCONFIRM_STATE
+	BGP_ERROR_SUB_RECEIVE_UNEXPECTED_MESSAGE_IN_ESTABLISHED_STATE
+)
+
+// NOTIFICATION Error Subcode for BGP_ERROR_CEASE  (RFC 4486)
+const (
+	_ = iota
+	BGP_ERROR_SUB_MAXIMUM_NUMBER_OF_PREFIXES_REACHED
+	BGP_ERROR_SUB_ADMINISTRATIVE_SHUTDOWN
+	BGP_ERROR_SUB_PEER_DECONFIGURED
+	BGP_ERROR_SUB_ADMINISTRATIVE_RESET
+	BGP_ERROR_SUB_CONNECTION_RESET
+	BGP_ERROR_SUB_OTHER_CONFIGURATION_CHANGE
+	BGP_ERROR_SUB_CONNECTION_COLLISION_RESOLUTION
+	BGP_ERROR_SUB_OUT_OF_RESOURCES
+	BGP_ERROR_SUB_HARD_RESET
+	BGP_ERROR_SUB_BFD_DOWN
+)
+
+var bgpErrorCodeNames = map[uint8]string{
+	BGP_ERROR_MESSAGE_HEADER_ERROR: "Message Header Error",
+	BGP_ERROR_OPEN_MESSAGE_ERROR:   "OPEN Message Error",
+	BGP_ERROR_UPDATE_MESSAGE_ERROR: "UPDATE Message Error",
+	BGP_ERROR_HOLD_TIMER_EXPIRED:   "Hold Timer Expired",
+	BGP_ERROR_FSM_ERROR:            "Finite State Machine Error",
+	BGP_ERROR_CEASE:                "Cease",
+}
+
+var bgpErrorSubcodeNames = map[uint8]map[uint8]string{
+	BGP_ERROR_MESSAGE_HEADER_ERROR: {
+		BGP_ERROR_SUB_CONNECTION_NOT_SYNCHRONIZED: "Connection Not Synchronized",
+		BGP_ERROR_SUB_BAD_MESSAGE_LENGTH:          "Bad Message Length",
+		BGP_ERROR_SUB_BAD_MESSAGE_TYPE:            "Bad Message Type",
+	},
+	BGP_ERROR_OPEN_MESSAGE_ERROR: {
+		BGP_ERROR_SUB_UNSUPPORTED_VERSION_NUMBER:     "Unsupported Version Number",
+		BGP_ERROR_SUB_BAD_PEER_AS:                    "Bad Peer AS",
+		BGP_ERROR_SUB_BAD_BGP_IDENTIFIER:             "Bad BGP Identifier",
+		BGP_ERROR_SUB_UNSUPPORTED_OPTIONAL_PARAMETER: "Unsupported Optional Parameter",
+		BGP_ERROR_SUB_AUTHENTICATION_FAILURE:         "Authentication Failure",
+		BGP_ERROR_SUB_UNACCEPTABLE_HOLD_TIME:         "Unacceptable Hold Time",
+		BGP_ERROR_SUB_UNSUPPORTED_CAPABILITY:         "Unsupported Capability",
+		BGP_ERROR_SUB_ROLE_MISMATCH:                  "Role Mismatch",
+	},
+	BGP_ERROR_UPDATE_MESSAGE_ERROR: {
+		BGP_ERROR_SUB_MALFORMED_ATTRIBUTE_LIST:          "Malformed Attribute List",
+		BGP_ERROR_SUB_UNRECOGNIZED_WELL_KNOWN_ATTRIBUTE: "Unrecognized Well-known Attribute",
+		BGP_ERROR_SUB_MISSING_WELL_KNOWN_ATTRIBUTE:      "Missing Well-known Attribute",
+		BGP_ERROR_SUB_ATTRIBUTE_FLAGS_ERROR:             "Attribute Flags Error",
+		BGP_ERROR_SUB_ATTRIBUTE_LENGTH_ERROR:            "Attribute Length Error",
+		BGP_ERROR_SUB_INVALID_ORIGIN_ATTRIBUTE:          "Invalid ORIGIN Attribute",
+		BGP_ERROR_SUB_ROUTING_LOOP:                      "AS Routing Loop",
+		BGP_ERROR_SUB_INVALID_NEXT_HOP_ATTRIBUTE:        "Invalid NEXT_HOP Attribute",
+		BGP_ERROR_SUB_OPTIONAL_ATTRIBUTE_ERROR:          "Optional Attribute Error",
+		BGP_ERROR_SUB_INVALID_NETWORK_FIELD:             "Invalid Network Field",
+		BGP_ERROR_SUB_MALFORMED_AS_PATH:                 "Malformed AS_PATH",
+	},
+	BGP_ERROR_FSM_ERROR: {
+		BGP_ERROR_SUB_RECEIVE_UNEXPECTED_MESSAGE_IN_OPENSENT_STATE:    "Receive Unexpected Message in OpenSent State",
+		BGP_ERROR_SUB_RECEIVE_UNEXPECTED_MESSAGE_IN_OPENCONFIRM_STATE: "Receive Unexpected Message in OpenConfirm State",
+		BGP_ERROR_SUB_RECEIVE_UNEXPECTED_MESSAGE_IN_ESTABLISHED_STATE: "Receive Unexpected Message in Established State",
+	},
+	BGP_ERROR_CEASE: {
+		BGP_ERROR_SUB_MAXIMUM_NUMBER_OF_PREFIXES_REACHED: "Maximum Number of Prefixes Reached",
+		BGP_ERROR_SUB_ADMINISTRATIVE_SHUTDOWN:            "Administrative Shutdown",
+		BGP_ERROR_SUB_PEER_DECONFIGURED:                  "Peer De-configured",
+		BGP_ERROR_SUB_ADMINISTRATIVE_RESET:               "Administrative Reset",
+		BGP_ERROR_SUB_CONNECTION_RESET:                   "Connection Rejected",
+		BGP_ERROR_SUB_OTHER_CONFIGURATION_CHANGE:         "Other Configuration Change",
+		BGP_ERROR_SUB_CONNECTION_COLLISION_RESOLUTION:    "Connection Collision Resolution",
+		BGP_ERROR_SUB_OUT_OF_RESOURCES:                   "Out of Resources",
+		BGP_ERROR_SUB_HARD_RESET:                         "Hard Reset",
+		BGP_ERROR_SUB_BFD_DOWN:                           "BFD Down",
+	},
+}
+
+// BGPErrorCode and the subcode types below give the NOTIFICATION
+// error codes and subcodes their names when printed.
+type BGPErrorCode uint8
+
+func (c BGPErrorCode) String() string {
+	if name, ok := bgpErrorCodeNames[uint8(c)]; ok {
+		return name
+	}
+	return fmt.Sprintf("Unknown(%d)", uint8(c))
+}
+
+func (c BGPErrorCode) subcodeString(subcode uint8) string {
+	if name, ok := bgpErrorSubcodeNames[uint8(c)][subcode]; ok {
+		return name
+	}
+	return strconv.Itoa(int(subcode))
+}
+
+type BGPMessageHeaderErrorSubcode uint8
+
+func (s BGPMessageHeaderErrorSubcode) String() string {
+	return BGPErrorCode(BGP_ERROR_MESSAGE_HEADER_ERROR).subcodeString(uint8(s))
+}
+
+type BGPOpenMessageErrorSubcode uint8
+
+func (s BGPOpenMessageErrorSubcode) String() string {
+	return BGPErrorCode(BGP_ERROR_OPEN_MESSAGE_ERROR).subcodeString(uint8(s))
+}
+
+type BGPUpdateMessageErrorSubcode uint8
+
+func (s BGPUpdateMessageErrorSubcode) String() string {
+	return BGPErrorCode(BGP_ERROR_UPDATE_MESSAGE_ERROR).subcodeString(uint8(s))
+}
+
+type BGPHoldTimerExpiredSubcode uint8
+
+func (s BGPHoldTimerExpiredSubcode) String() string {
+	return BGPErrorCode(BGP_ERROR_HOLD_TIMER_EXPIRED).subcodeString(uint8(s))
+}
+
+type BGPFSMErrorSubcode uint8
+
+func (s BGPFSMErrorSubcode) String() string {
+	return BGPErrorCode(BGP_ERROR_FSM_ERROR).subcodeString(uint8(s))
+}
+
+type BGPCeaseSubcode uint8
+
+func (s BGPCeaseSubcode) String() string {
+	return BGPErrorCode(BGP_ERROR_CEASE).subcodeString(uint8(s))
+}
+
+type bgpUnknownErrorSubcode uint8
+
+func (s bgpUnknownErrorSubcode) String() string {
+	return strconv.Itoa(int(s))
+}
+
+// bgpErrorSubcode types subcode after the family code belongs to.
+func bgpErrorSubcode(code, subcode uint8) fmt.Stringer {
+	switch code {
+	case BGP_ERROR_MESSAGE_HEADER_ERROR:
+		return BGPMessageHeaderErrorSubcode(subcode)
+	case BGP_ERROR_OPEN_MESSAGE_ERROR:
+		return BGPOpenMessageErrorSubcode(subcode)
+	case BGP_ERROR_UPDATE_MESSAGE_ERROR:
+		return BGPUpdateMessageErrorSubcode(subcode)
+	case BGP_ERROR_HOLD_TIMER_EXPIRED:
+		return BGPHoldTimerExpiredSubcode(subcode)
+	case BGP_ERROR_FSM_ERROR:
+		return BGPFSMErrorSubcode(subcode)
+	case BGP_ERROR_CEASE:
+		return BGPCeaseSubcode(subcode)
+	}
+	return bgpUnknownErrorSubcode(subcode)
+}
+
+// bgpErrorName returns "Code/Subcode" of a NOTIFICATION, leaving out
+// the unspecific subcode 0.
+func bgpErrorName(code, subcode uint8) string {
+	name := BGPErrorCode(code).String()
+	if subcode == 0 {
+		return name
+	}
+	return name + "/" + bgpErrorSubcode(code, subcode).String()
+}
+
+type PathAttributeInterface interface {
+	DecodeFromBytes([]byte) error
+	Len() int
+	String() string
+	MarshalJSON() ([]byte, error)
+}
+
+type PathAttribute struct {
+	Flags  uint8
+	Type   uint8
+	Length uint16
+	Value  []byte
+}
+
+func (p *PathAttribute) Len() int {
+	l := 2 + p.Length
+	if p.Flags&BGP_ATTR_FLAG_EXTENDED_LENGTH != 0 {
+		l += 2
+	} else {
+		l += 1
+	}
+	return int(l)
+}
+
+func (p *PathAttribute) DecodeFromBytes(data []byte) error {
+	if len(data) < 3 {
+		return fmt.Errorf("Not all PathAttribute bytes available")
+	}
+	p.Flags = data[0]
+	p.Type = data[1]
+
+	if p.Flags&BGP_ATTR_FLAG_EXTENDED_LENGTH != 0 {
+		if len(data) < 4 {
+			return fmt.Errorf("Not all PathAttribute bytes available")
+		}
+		p.Length = binary.BigEndian.Uint16(data[2:4])
+		data = data[4:]
+	} else {
+		p.Length = uint16(data[2])
+		data = data[3:]
+	}
+	if len(data) < int(p.Length) {
+		return fmt.Errorf("Not all PathAttribute bytes available")
+	}
+	p.Value = data[:p.Length]
+
+	return nil
+}
+
+// String shows the raw value, attributes this package decodes override it.
+func (p *PathAttribute) String() string {
+	return fmt.Sprintf("%s: %x", pathAttrTypeName(p.Type), p.Value)
+}
+
+func (p *PathAttribute) Serialize() ([]byte, error) {
+	return p.serialize(p.Value)
+}
+
+// serialize encodes the attribute header in front of value, switching to
+// the extended length form when value does not fit in one octet.
+func (p *PathAttribute) serialize(value []byte) ([]byte, error) {
+	if len(value) > math.MaxUint16 {
+		return nil, fmt.Errorf("path attribute %d is too long", p.Type)
+	}
+	p.Value = value
+	p.Length = uint16(len(value))
+	if len(value) > math.MaxUint8 {
+		p.Flags |= BGP_ATTR_FLAG_EXTENDED_LENGTH
+	}
+	var buf []byte
+	if p.Flags&BGP_ATTR_FLAG_EXTENDED_LENGTH != 0 {
+		buf = make([]byte, 4)
+		binary.BigEndian.PutUint16(buf[2:4], p.Length)
+	} else {
+		buf = make([]byte, 3)
+		buf[2] = uint8(p.Length)
+	}
+	buf[0] = p.Flags
+	buf[1] = p.Type
+	return append(buf, value...), nil
+}
+
+// pathAttributeJSON is the JSON form of every path attribute. Value is
+// structured for the attributes listed in pathAttributeValueFromJSON.
+// For the others it is informational only, and Raw carries the
+// attribute value in hex, from which the attribute is decoded again.
+type pathAttributeJSON struct {
+	Type  string          `json:"type"`
+	Code  uint8           `json:"code"`
+	Flags uint8           `json:"flags"`
+	Value json.RawMessage `json:"value,omitempty"`
+	Raw   string          `json:"raw,omitempty"`
+}
+
+func (p *PathAttribute) newJSON(value interface{}) (*pathAttributeJSON, error) {
+	j := &pathAttributeJSON{
+		Type:  pathAttrTypeName(p.Type),
+		Code:  p.Type,
+		Flags: p.Flags,
+	}
+	if value != nil {
+		v, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		j.Value = v
+	}
+	return j, nil
+}
+
+func (p *PathAttribute) marshalJSON(value interface{}) ([]byte, error) {
+	j, err := p.newJSON(value)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(j)
+}
+
+// marshalJSONRaw adds the raw attribute value, serializing s first if
+// the attribute was built rather than decoded.
+func (p *PathAttribute) marshalJSONRaw(value interface{}, s interface {
+	Serialize() ([]byte, error)
+}) ([]byte, error) {
+	if len(p.Value) == 0 {
+		if _, err := s.Serialize(); err != nil {
+			return nil, err
+		}
+	}
+	j, err := p.newJSON(value)
+	if err != nil {
+		return nil, err
+	}
+	j.Raw = fmt.Sprintf("%x", p.Value)
+	return json.Marshal(j)
+}
+
+// decodePathAttributeJSON rebuilds the wire form of an attribute from
+// its JSON form and decodes it, so the result is the same as for an
+// attribute received from a peer.
+func decodePathAttributeJSON(data []byte) (PathAttributeInterface, error) {
+	var j pathAttributeJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, err
+	}
+	value, err := pathAttributeValueFromJSON(&j)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s attribute: %s", pathAttrTypeName(j.Code), err)
+	}
+	if len(value) > math.MaxUint16 {
+		return nil, fmt.Errorf("path attribute %d is too long", j.Code)
+	}
+	flags := j.Flags
+	if len(value) > math.MaxUint8 {
+		flags |= BGP_ATTR_FLAG_EXTENDED_LENGTH
+	}
+	var buf []byte
+	if flags&BGP_ATTR_FLAG_EXTENDED_LENGTH != 0 {
+		buf = []byte{flags, j.Code, 0, 0}
+		binary.BigEndian.PutUint16(buf[2:4], uint16(len(value)))
+	} else {
+		buf = []byte{flags, j.Code, uint8(len(value))}
+	}
+	buf = append(buf, value...)
+	p := getPathAttribute(buf)
+	if a, ok := p.(*PathAttributeAsPath); ok {
+		a.asLen = 4
+	}
+	if err := p.DecodeFromBytes(buf); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func parseJSONAddress(s string) (net.IP, error) {
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid address %q", s)
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4, nil
+	}
+	return ip, nil
+}
+
+func pathAttributeValueFromJSON(j *pathAttributeJSON) ([]byte, error) {
+	switch j.Code {
+	case BGP_ATTR_TYPE_ORIGIN:
+		var name string
+		if err := json.Unmarshal(j.Value, &name); err != nil {
+			return nil, err
+		}
+		origin, err := nameToCode(bgpOriginNames, name)
+		if err != nil {
+			return nil, err
+		}
+		return []byte{origin}, nil
+	case BGP_ATTR_TYPE_AS_PATH, BGP_ATTR_TYPE_AS4_PATH:
+		var segments []asPathSegmentJSON
+		if err := json.Unmarshal(j.Value, &segments); err != nil {
+			return nil, err
+		}
+		var buf []byte
+		for _, s := range segments {
+			t, err := nameToCode(asPathSegmentTypeNames, s.Type)
+			if err != nil {
+				return nil, err
+			}
+			if len(s.AS) > math.MaxUint8 {
+				return nil, fmt.Errorf("too many AS numbers in a segment")
+			}
+			buf = append(buf, t, uint8(len(s.AS)))
+			for _, as := range s.AS {
+				buf = binary.BigEndian.AppendUint32(buf, as)
+			}
+		}
+		return buf, nil
+	case BGP_ATTR_TYPE_NEXT_HOP, BGP_ATTR_TYPE_ORIGINATOR_ID:
+		var s string
+		if err := json.Unmarshal(j.Value, &s); err != nil {
+			return nil, err
+		}
+		return parseJSONAddress(s)
+	case BGP_ATTR_TYPE_MULTI_EXIT_DISC, BGP_ATTR_TYPE_LOCAL_PREF:
+		var v uint32
+		if err := json.Unmarshal(j.Value, &v); err != nil {
+			return nil, err
+		}
+		return binary.BigEndian.AppendUint32(nil, v), nil
+	case BGP_ATTR_TYPE_ATOMIC_AGGREGATE:
+		return nil, nil
+	case BGP_ATTR_TYPE_AGGREGATOR, BGP_ATTR_TYPE_AS4_AGGREGATOR:
+		var a aggregatorJSON
+		if err := json.Unmarshal(j.Value, &a); err != nil {
+			return nil, err
+		}
+		addr, err := parseJSONAddress(a.Address)
+		if err != nil {
+			return nil, err
+		}
+		return append(binary.BigEndian.AppendUint32(nil, a.AS), addr...), nil
+	case BGP_ATTR_TYPE_COMMUNITIES:
+		var values []string
+		if err := json.Unmarshal(j.Value, &values); err != nil {
+			return nil, err
+		}
+		var buf []byte
+		for _, s := range values {
+			c, err := ParseCommunity(s)
+			if err != nil {
+				return nil, err
+			}
+			buf = binary.BigEndian.AppendUint32(buf, c)
+		}
+		return buf, nil
+	case BGP_ATTR_TYPE_CLUSTER_LIST:
+		var values []string
+		if err := json.Unmarshal(j.Value, &values); err != nil {
+			return nil, err
+		}
+		var buf []byte
+		for _, s := range values {
+			id, err := parseJSONAddress(s)
+			if err != nil {
+				return nil, err
+			}
+			buf = append(buf, id...)
+		}
+		return buf, nil
+	case BGP_ATTR_TYPE_LARGE_COMMUNITY:
+		var values []string
+		if err := json.Unmarshal(j.Value, &values); err != nil {
+			return nil, err
+		}
+		var buf []byte
+		for _, s := range values {
+			c, err := ParseLargeCommunity(s)
+			if err != nil {
+				return nil, err
+			}
+			buf = binary.BigEndian.AppendUint32(buf, c.GlobalAdmin)
+			buf = binary.BigEndian.AppendUint32(buf, c.LocalData1)
+			buf = binary.BigEndian.AppendUint32(buf, c.LocalData2)
+		}
+		return buf, nil
+	}
+	return hex.DecodeString(j.Raw)
+}
+
+// ORIGIN attribute values  RFC 4271 4.3
+const (
+	BGP_ORIGIN_ATTR_TYPE_IGP        = 0
+	BGP_ORIGIN_ATTR_TYPE_EGP        = 1
+	BGP_ORIGIN_ATTR_TYPE_INCOMPLETE = 2
+)
+
+type PathAttributeOrigin struct {
+	PathAttribute
+}
+
+var bgpOriginNames = map[uint8]string{
+	BGP_ORIGIN_ATTR_TYPE_IGP:        "igp",
+	BGP_ORIGIN_ATTR_TYPE_EGP:        "egp",
+	BGP_ORIGIN_ATTR_TYPE_INCOMPLETE: "incomplete",
+}
+
+func (p *PathAttributeOrigin) String() string {
+	if len(p.PathAttribute.Value) != 1 {
+		return fmt.Sprintf("ORIGIN: %x", p.PathAttribute.Value)
+	}
+	return "ORIGIN: " + codeToName(bgpOriginNames, p.PathAttribute.Value[0])
+}
+
+func (p *PathAttributeOrigin) MarshalJSON() ([]byte, error) {
+	if len(p.PathAttribute.Value) != 1 {
+		return nil, fmt.Errorf("invalid ORIGIN length %d", len(p.PathAttribute.Value))
+	}
+	return p.PathAttribute.marshalJSON(codeToName(bgpOriginNames, p.PathAttribute.Value[0]))
+}
+
+// AS_PATH segment types  RFC 4271 4.3, RFC 5065 3
+const (
+	BGP_ASPATH_ATTR_TYPE_SET        = 1
+	BGP_ASPATH_ATTR_TYPE_SEQ        = 2
+	BGP_ASPATH_ATTR_TYPE_CONFED_SEQ = 3
+	BGP_ASPATH_ATTR_TYPE_CONFED_SET = 4
+)
+
+type AsPathParam struct {
+	Type uint8
+	Num  uint8
+	AS   []uint32
+}
+
+// String writes a sequence as space separated AS numbers, a set in
+// braces, and confederation sequences and sets in parentheses and
+// brackets.
+func (a *AsPathParam) String() string {
+	as := make([]string, len(a.AS))
+	for i, n := range a.AS {
+		as[i] = strconv.FormatUint(uint64(n), 10)
+	}
+	switch a.Type {
+	case BGP_ASPATH_ATTR_TYPE_SET:
+		return "{" + strings.Join(as, ",") + "}"
+	case BGP_ASPATH_ATTR_TYPE_CONFED_SEQ:
+		return "(" + strings.Join(as, " ") + ")"
+	case BGP_ASPATH_ATTR_TYPE_CONFED_SET:
+		return "[" + strings.Join(as, ",") + "]"
+	}
+	return strings.Join(as, " ")
+}
+
+func asPathString(params []AsPathParam) string {
+	s := make([]string, len(params))
+	for i := range params {
+		s[i] = params[i].String()
+	}
+	return strings.Join(s, " ")
+}
+
+var asPathSegmentTypeNames = map[uint8]string{
+	BGP_ASPATH_ATTR_TYPE_SET:        "set",
+	BGP_ASPATH_ATTR_TYPE_SEQ:        "sequence",
+	BGP_ASPATH_ATTR_TYPE_CONFED_SEQ: "confed-sequence",
+	BGP_ASPATH_ATTR_TYPE_CONFED_SET: "confed-set",
+}
+
+type asPathSegmentJSON struct {
+	Type string   `json:"type"`
+	AS   []uint32 `json:"as"`
+}
+
+func asPathJSON(params []AsPathParam) []asPathSegmentJSON {
+	j := make([]asPathSegmentJSON, len(params))
+	for i, a := range params {
+		j[i] = asPathSegmentJSON{Type: codeToName(asPathSegmentTypeNames, a.Type), AS: a.AS}
+	}
+	return j
 }
 
 type DefaultAsPath struct {
 }
 
-func (p *DefaultAsPath) isValidAspath(data []byte) bool {
-	for len(data) > 0 {
-		segType := data[0]
-		asNum := data[1]
-		if segType == 0 || segType > 4 {
-			return false
-		}
-		data = data[2:]
-		if len(data) < int(asNum)*2 {
-			return false
-		}
-		data = data[2*asNum:]
+// isValidAspath reports whether data holds whole path segments of AS
+// numbers asLen octets long.
+func (p *DefaultAsPath) isValidAspath(data []byte, asLen int) bool {
+	for len(data) > 0 {
+		if len(data) < 2 {
+			return false
+		}
+		segType := data[0]
+		asNum := data[1]
+		if segType == 0 || segType > 4 {
+			return false
+		}
+		data = data[2:]
+		if len(data) < int(asNum)*asLen {
+			return false
+		}
+		data = data[int(asNum)*asLen:]
+	}
+	return true
+}
+
+// TODO: could marge two functions nicely with reflect?
+func (p *DefaultAsPath) decodeAspath(data []byte) []AsPathParam {
+	var param []AsPathParam
+
+	for len(data) > 0 {
+		a := AsPathParam{}
+		a.Type = data[0]
+		a.Num = data[1]
+		data = data[2:]
+		for i := 0; i < int(a.Num); i++ {
+			a.AS = append(a.AS, uint32(binary.BigEndian.Uint16(data)))
+			data = data[2:]
+		}
+		param = append(param, a)
+	}
+	return param
+}
+
+func (p *DefaultAsPath) decodeAs4path(data []byte) []AsPathParam {
+	var param []AsPathParam
+
+	for len(data) > 0 {
+		a := AsPathParam{}
+		a.Type = data[0]
+		a.Num = data[1]
+		data = data[2:]
+		for i := 0; i < int(a.Num); i++ {
+			a.AS = append(a.AS, binary.BigEndian.Uint32(data))
+			data = data[4:]
+		}
+		param = append(param, a)
+	}
+	return param
+}
+
+type PathAttributeAsPath struct {
+	DefaultAsPath
+	PathAttribute
+	Value []AsPathParam
+	asLen int
+}
+
+func (p *PathAttributeAsPath) DecodeFromBytes(data []byte) error {
+	p.PathAttribute.DecodeFromBytes(data)
+	asLen := p.asLen
+	if asLen == 0 {
+		// without a negotiated AS number size, guess from the encoding
+		asLen = 4
+		if p.DefaultAsPath.isValidAspath(p.PathAttribute.Value, 2) {
+			asLen = 2
+		}
+	}
+	if !p.DefaultAsPath.isValidAspath(p.PathAttribute.Value, asLen) {
+		return fmt.Errorf("invalid AS_PATH of %d octet AS numbers", asLen)
+	}
+	if asLen == 2 {
+		p.Value = p.DefaultAsPath.decodeAspath(p.PathAttribute.Value)
+	} else {
+		p.Value = p.DefaultAsPath.decodeAs4path(p.PathAttribute.Value)
+	}
+	return nil
+}
+
+func (p *PathAttributeAsPath) String() string {
+	return "AS_PATH: " + asPathString(p.Value)
+}
+
+func (p *PathAttributeAsPath) MarshalJSON() ([]byte, error) {
+	return p.PathAttribute.marshalJSON(asPathJSON(p.Value))
+}
+
+type PathAttributeNextHop struct {
+	PathAttribute
+	Value net.IP
+}
+
+func (p *PathAttributeNextHop) DecodeFromBytes(data []byte) error {
+	p.PathAttribute.DecodeFromBytes(data)
+	p.Value = p.PathAttribute.Value
+	return nil
+}
+
+func (p *PathAttributeNextHop) String() string {
+	return fmt.Sprintf("NEXT_HOP: %s", p.Value)
+}
+
+func (p *PathAttributeNextHop) MarshalJSON() ([]byte, error) {
+	return p.PathAttribute.marshalJSON(p.Value.String())
+}
+
+type PathAttributeMultiExitDisc struct {
+	PathAttribute
+	Value uint32
+}
+
+func (p *PathAttributeMultiExitDisc) DecodeFromBytes(data []byte) error {
+	p.PathAttribute.DecodeFromBytes(data)
+	p.Value = binary.BigEndian.Uint32(p.PathAttribute.Value)
+	return nil
+}
+
+func (p *PathAttributeMultiExitDisc) String() string {
+	return fmt.Sprintf("MULTI_EXIT_DISC: %d", p.Value)
+}
+
+func (p *PathAttributeMultiExitDisc) MarshalJSON() ([]byte, error) {
+	return p.PathAttribute.marshalJSON(p.Value)
+}
+
+type PathAttributeLocalPref struct {
+	PathAttribute
+	Value uint32
+}
+
+func (p *PathAttributeLocalPref) DecodeFromBytes(data []byte) error {
+	p.PathAttribute.DecodeFromBytes(data)
+	p.Value = binary.BigEndian.Uint32(p.PathAttribute.Value)
+	return nil
+}
+
+func (p *PathAttributeLocalPref) String() string {
+	return fmt.Sprintf("LOCAL_PREF: %d", p.Value)
+}
+
+func (p *PathAttributeLocalPref) MarshalJSON() ([]byte, error) {
+	return p.PathAttribute.marshalJSON(p.Value)
+}
+
+type PathAttributeAtomicAggregate struct {
+	PathAttribute
+}
+
+func (p *PathAttributeAtomicAggregate) String() string {
+	return "ATOMIC_AGGREGATE"
+}
+
+func (p *PathAttributeAtomicAggregate) MarshalJSON() ([]byte, error) {
+	return p.PathAttribute.marshalJSON(nil)
+}
+
+type PathAttributeAggregatorParam struct {
+	AS      uint32
+	Address net.IP
+}
+
+type PathAttributeAggregator struct {
+	PathAttribute
+	Value PathAttributeAggregatorParam
+}
+
+func (p *PathAttributeAggregator) DecodeFromBytes(data []byte) error {
+	p.PathAttribute.DecodeFromBytes(data)
+	if len(p.PathAttribute.Value) == 6 {
+		p.Value.AS = uint32(binary.BigEndian.Uint16(p.PathAttribute.Value[0:2]))
+		p.Value.Address = p.PathAttribute.Value[2:]
+	} else {
+		p.Value.AS = binary.BigEndian.Uint32(p.PathAttribute.Value[0:4])
+		p.Value.Address = p.PathAttribute.Value[4:]
+	}
+	return nil
+}
+
+func (p *PathAttributeAggregator) String() string {
+	return fmt.Sprintf("AGGREGATOR: %d %s", p.Value.AS, p.Value.Address)
+}
+
+type aggregatorJSON struct {
+	AS      uint32 `json:"as"`
+	Address string `json:"address"`
+}
+
+func (p *PathAttributeAggregator) MarshalJSON() ([]byte, error) {
+	return p.PathAttribute.marshalJSON(aggregatorJSON{p.Value.AS, p.Value.Address.String()})
+}
+
+type WellKnownCommunity uint32
+
+const (
+	COMMUNITY_GRACEFUL_SHUTDOWN   WellKnownCommunity = 0xffff0000
+	COMMUNITY_ACCEPT_OWN                             = 0xffff0001
+	COMMUNITY_LLGR_STALE                             = 0xffff0006
+	COMMUNITY_NO_LLGR                                = 0xffff0007
+	COMMUNITY_BLACKHOLE                              = 0xffff029a
+	COMMUNITY_NO_EXPORT                              = 0xffffff01
+	COMMUNITY_NO_ADVERTISE                           = 0xffffff02
+	COMMUNITY_NO_EXPORT_SUBCONFED                    = 0xffffff03
+	COMMUNITY_NO_PEER                                = 0xffffff04
+)
+
+var wellKnownCommunityNames = map[WellKnownCommunity]string{
+	COMMUNITY_GRACEFUL_SHUTDOWN:   "graceful-shutdown",
+	COMMUNITY_ACCEPT_OWN:          "accept-own",
+	COMMUNITY_LLGR_STALE:          "llgr-stale",
+	COMMUNITY_NO_LLGR:             "no-llgr",
+	COMMUNITY_BLACKHOLE:           "blackhole",
+	COMMUNITY_NO_EXPORT:           "no-export",
+	COMMUNITY_NO_ADVERTISE:        "no-advertise",
+	COMMUNITY_NO_EXPORT_SUBCONFED: "no-export-subconfed",
+	COMMUNITY_NO_PEER:             "no-peer",
+}
+
+func (c WellKnownCommunity) String() string {
+	if name, ok := wellKnownCommunityNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("%d:%d", uint32(c)>>16, uint32(c)&0xffff)
+}
+
+// FormatCommunity returns the name of a well-known community or the
+// "AS:value" form of any other one.
+func FormatCommunity(c uint32) string {
+	return WellKnownCommunity(c).String()
+}
+
+// ParseCommunity accepts a well-known community name, the "AS:value"
+// form or a plain 32 bit number. A name may also be spelt as in the RFCs,
+// such as "NO_EXPORT".
+func ParseCommunity(value string) (uint32, error) {
+	name := strings.ReplaceAll(value, "_", "-")
+	for c, n := range wellKnownCommunityNames {
+		if strings.EqualFold(name, n) {
+			return uint32(c), nil
+		}
+	}
+	elems := strings.Split(value, ":")
+	switch len(elems) {
+	case 1:
+		v, err := strconv.ParseUint(value, 10, 32)
+		if err == nil {
+			return uint32(v), nil
+		}
+	case 2:
+		as, err1 := strconv.ParseUint(elems[0], 10, 16)
+		v, err2 := strconv.ParseUint(elems[1], 10, 16)
+		if err1 == nil && err2 == nil {
+			return uint32(as<<16 | v), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid community format: %s", value)
+}
+
+type PathAttributeCommunities struct {
+	PathAttribute
+	Value []uint32
+}
+
+func NewPathAttributeCommunities(values []uint32) *PathAttributeCommunities {
+	p := &PathAttributeCommunities{}
+	p.Flags = BGP_ATTR_FLAG_OPTIONAL | BGP_ATTR_FLAG_TRANSITIVE
+	p.Type = BGP_ATTR_TYPE_COMMUNITIES
+	p.Value = values
+	p.PathAttribute.Length = uint16(4 * len(values))
+	return p
+}
+
+func (p *PathAttributeCommunities) DecodeFromBytes(data []byte) error {
+	p.PathAttribute.DecodeFromBytes(data)
+	value := p.PathAttribute.Value
+	for len(value) >= 4 {
+		p.Value = append(p.Value, binary.BigEndian.Uint32(value))
+		value = value[4:]
+	}
+	return nil
+}
+
+func (p *PathAttributeCommunities) Serialize() ([]byte, error) {
+	buf := make([]byte, 4*len(p.Value))
+	for i, v := range p.Value {
+		binary.BigEndian.PutUint32(buf[4*i:], v)
+	}
+	return p.PathAttribute.serialize(buf)
+}
+
+func (p *PathAttributeCommunities) String() string {
+	s := make([]string, len(p.Value))
+	for i, v := range p.Value {
+		s[i] = FormatCommunity(v)
+	}
+	return "COMMUNITIES: " + strings.Join(s, " ")
+}
+
+func (p *PathAttributeCommunities) MarshalJSON() ([]byte, error) {
+	s := make([]string, len(p.Value))
+	for i, v := range p.Value {
+		s[i] = FormatCommunity(v)
+	}
+	return p.PathAttribute.marshalJSON(s)
+}
+
+func (p *PathAttributeCommunities) HasCommunity(c uint32) bool {
+	for _, v := range p.Value {
+		if v == c {
+			return true
+		}
+	}
+	return false
+}
+
+// CanAdvertise applies the RFC 1997 well-known communities on export.
+// external is set for eBGP peers, confedMember for eBGP peers that are
+// members of the local confederation.
+func (p *PathAttributeCommunities) CanAdvertise(external, confedMember bool) bool {
+	if p.HasCommunity(COMMUNITY_NO_ADVERTISE) {
+		return false
+	}
+	if external && p.HasCommunity(COMMUNITY_NO_EXPORT_SUBCONFED) {
+		return false
+	}
+	if external && !confedMember && p.HasCommunity(COMMUNITY_NO_EXPORT) {
+		return false
+	}
+	return true
+}
+
+type PathAttributeOriginatorId struct {
+	PathAttribute
+	Value net.IP
+}
+
+func (p *PathAttributeOriginatorId) DecodeFromBytes(data []byte) error {
+	p.PathAttribute.DecodeFromBytes(data)
+	p.Value = p.PathAttribute.Value
+	return nil
+}
+
+func (p *PathAttributeOriginatorId) String() string {
+	return fmt.Sprintf("ORIGINATOR_ID: %s", p.Value)
+}
+
+func (p *PathAttributeOriginatorId) MarshalJSON() ([]byte, error) {
+	return p.PathAttribute.marshalJSON(p.Value.String())
+}
+
+type PathAttributeClusterList struct {
+	PathAttribute
+	Value []net.IP
+}
+
+func (p *PathAttributeClusterList) DecodeFromBytes(data []byte) error {
+	p.PathAttribute.DecodeFromBytes(data)
+	value := p.PathAttribute.Value
+	for len(value) >= 4 {
+		p.Value = append(p.Value, value[:4])
+		value = value[4:]
+	}
+	return nil
+}
+
+func (p *PathAttributeClusterList) String() string {
+	s := make([]string, len(p.Value))
+	for i, v := range p.Value {
+		s[i] = v.String()
+	}
+	return "CLUSTER_LIST: " + strings.Join(s, " ")
+}
+
+func (p *PathAttributeClusterList) MarshalJSON() ([]byte, error) {
+	s := make([]string, len(p.Value))
+	for i, v := range p.Value {
+		s[i] = v.String()
+	}
+	return p.PathAttribute.marshalJSON(s)
+}
+
+func nlriString(prefixes []AddrPrefixInterface) string {
+	s := make([]string, len(prefixes))
+	for i, p := range prefixes {
+		s[i] = p.String()
+	}
+	return "[" + strings.Join(s, " ") + "]"
+}
+
+// prefixJSON is the JSON form of an NLRI, the prefix written the way
+// its String method writes it.
+type prefixJSON struct {
+	Prefix string `json:"prefix"`
+	PathID uint32 `json:"path_id,omitempty"`
+}
+
+func newPrefixJSON(p AddrPrefixInterface) prefixJSON {
+	j := prefixJSON{Prefix: p.String()}
+	if i, ok := p.(interface {
+		pathIdentifier() uint32
+	}); ok {
+		j.PathID = i.pathIdentifier()
+	}
+	return j
+}
+
+func nlriJSON(prefixes []AddrPrefixInterface) []prefixJSON {
+	j := make([]prefixJSON, len(prefixes))
+	for i, p := range prefixes {
+		j[i] = newPrefixJSON(p)
+	}
+	return j
+}
+
+// ipAddrPrefix parses the IPv4 unicast prefixes carried outside of
+// MP_REACH_NLRI and MP_UNREACH_NLRI.
+func (j prefixJSON) ipAddrPrefix() (*IPAddrPrefix, error) {
+	p, err := netip.ParsePrefix(j.Prefix)
+	if err != nil {
+		return nil, err
+	}
+	r, err := NewIPAddrPrefixFromNetip(p)
+	if err != nil {
+		return nil, err
+	}
+	r.PathIdentifier = j.PathID
+	return r, nil
+}
+
+// mpNLRIJSON is the informational value of MP_REACH_NLRI and
+// MP_UNREACH_NLRI. The family is left out of attributes that were not
+// decoded from the wire.
+type mpNLRIJSON struct {
+	AFI     uint16       `json:"afi,omitempty"`
+	SAFI    uint8        `json:"safi,omitempty"`
+	Family  string       `json:"family,omitempty"`
+	Nexthop string       `json:"nexthop,omitempty"`
+	NLRI    []prefixJSON `json:"nlri"`
+}
+
+func newMpNLRIJSON(value []byte, prefixes []AddrPrefixInterface) *mpNLRIJSON {
+	j := &mpNLRIJSON{NLRI: nlriJSON(prefixes)}
+	if len(value) >= 3 {
+		j.AFI = binary.BigEndian.Uint16(value[0:2])
+		j.SAFI = value[2]
+		j.Family = routeFamilyName(j.AFI, j.SAFI)
+	}
+	return j
+}
+
+type PathAttributeMpReachNLRI struct {
+	PathAttribute
+	Nexthop net.IP
+	// RawNexthop is the next hop field as received. Nexthop is nil when
+	// the family is unknown, RawNexthop is the only place it is kept.
+	RawNexthop []byte
+	Value      []AddrPrefixInterface
+	features   *SessionFeatures
+}
+
+func (p *PathAttributeMpReachNLRI) DecodeFromBytes(data []byte) error {
+	p.PathAttribute.DecodeFromBytes(data)
+
+	value := p.PathAttribute.Value
+	if len(value) < 5 || len(value) < 5+int(value[3]) {
+		return fmt.Errorf("Not all MP_REACH_NLRI bytes available")
+	}
+	afi := binary.BigEndian.Uint16(value[0:2])
+	safi := value[2]
+	nexthopLen := value[3]
+	nexthopbin := value[4 : 4+nexthopLen]
+	value = value[4+nexthopLen:]
+	p.RawNexthop = nexthopbin
+	// the next hop of an unknown family is not decoded, Nexthop stays nil
+	_, unknown := routeFamilyPrefix(afi, safi).(*UnknownNLRI)
+	if nexthopLen > 0 && !unknown {
+		offset := 0
+		if safi == SAFI_MPLS_VPN || safi == SAFI_MPLS_VPN_MULTICAST {
+			offset = 8
+		}
+		// an IPv6 global address may be followed by a link-local one
+		addrlen := int(nexthopLen) - offset
+		if addrlen == 32 || addrlen == 40 {
+			addrlen = 16
+		}
+		if addrlen != 4 && addrlen != 16 {
+			return fmt.Errorf("invalid MP_REACH_NLRI nexthop length %d", nexthopLen)
+		}
+		p.Nexthop = nexthopbin[offset : offset+addrlen]
+	}
+	// skip reserved
+	value = value[1:]
+	addpath := p.features.ReceiveAddPath(afi, safi)
+	for len(value) > 0 {
+		prefix := routeFamilyPrefix(afi, safi)
+		rest, err := decodePathIdentifier(prefix, value, addpath)
+		if err != nil {
+			return err
+		}
+		if err := prefix.DecodeFromBytes(rest); err != nil {
+			return err
+		}
+		if l, ok := prefix.(labelledPrefix); ok && p.features != nil {
+			if n := l.labelCount(); n > p.features.ReceiveLabelCount(afi, safi) {
+				return fmt.Errorf("MP_REACH_NLRI route carries %d labels, more than negotiated", n)
+			}
+		}
+		value = rest[prefix.Len():]
+		p.Value = append(p.Value, prefix)
+	}
+	return nil
+}
+
+func (p *PathAttributeMpReachNLRI) String() string {
+	return fmt.Sprintf("MP_REACH_NLRI: nexthop %s %s", p.Nexthop, nlriString(p.Value))
+}
+
+func (p *PathAttributeMpReachNLRI) MarshalJSON() ([]byte, error) {
+	j := newMpNLRIJSON(p.PathAttribute.Value, p.Value)
+	if p.Nexthop != nil {
+		j.Nexthop = p.Nexthop.String()
+	}
+	return p.PathAttribute.marshalJSONRaw(j, p)
+}
+
+type PathAttributeMpUnreachNLRI struct {
+	PathAttribute
+	Value    []AddrPrefixInterface
+	features *SessionFeatures
+}
+
+func (p *PathAttributeMpUnreachNLRI) DecodeFromBytes(data []byte) error {
+	p.PathAttribute.DecodeFromBytes(data)
+
+	value := p.PathAttribute.Value
+	if len(value) < 3 {
+		return fmt.Errorf("Not all MP_UNREACH_NLRI bytes available")
+	}
+	afi := binary.BigEndian.Uint16(value[0:2])
+	safi := value[2]
+	value = value[3:]
+	addpath := p.features.ReceiveAddPath(afi, safi)
+	for len(value) > 0 {
+		prefix := routeFamilyPrefix(afi, safi)
+		rest, err := decodePathIdentifier(prefix, value, addpath)
+		if err != nil {
+			return err
+		}
+		// RFC 8277 2.4: a withdrawn labelled route has a single label
+		// field whatever the label stack was
+		if w, ok := prefix.(interface {
+			setWithdraw()
+		}); ok {
+			w.setWithdraw()
+		}
+		if err := prefix.DecodeFromBytes(rest); err != nil {
+			return err
+		}
+		value = rest[prefix.Len():]
+		p.Value = append(p.Value, prefix)
+	}
+	return nil
+}
+
+func (p *PathAttributeMpUnreachNLRI) String() string {
+	return "MP_UNREACH_NLRI: " + nlriString(p.Value)
+}
+
+func (p *PathAttributeMpUnreachNLRI) MarshalJSON() ([]byte, error) {
+	return p.PathAttribute.marshalJSONRaw(newMpNLRIJSON(p.PathAttribute.Value, p.Value), p)
+}
+
+type ExtendedCommunityAttrType uint8
+
+const (
+	EC_TYPE_TRANSITIVE_TWO_OCTET_AS_SPECIFIC      ExtendedCommunityAttrType = 0x00
+	EC_TYPE_TRANSITIVE_IP4_SPECIFIC                                         = 0x01
+	EC_TYPE_TRANSITIVE_FOUR_OCTET_AS_SPECIFIC                               = 0x02
+	EC_TYPE_TRANSITIVE_OPAQUE                                               = 0x03
+	EC_TYPE_EVPN                                                            = 0x06
+	EC_TYPE_NON_TRANSITIVE_TWO_OCTET_AS_SPECIFIC                            = 0x40
+	EC_TYPE_NON_TRANSITIVE_IP4_SPECIFIC                                     = 0x41
+	EC_TYPE_NON_TRANSITIVE_FOUR_OCTET_AS_SPECIFIC                           = 0x42
+	EC_TYPE_NON_TRANSITIVE_OPAQUE                                           = 0x43
+)
+
+type ExtendedCommunityAttrSubType uint8
+
+const (
+	EC_SUBTYPE_ROUTE_TARGET     ExtendedCommunityAttrSubType = 0x02
+	EC_SUBTYPE_ROUTE_ORIGIN                                  = 0x03
+	EC_SUBTYPE_LINK_BANDWIDTH                                = 0x04
+	EC_SUBTYPE_OSPF_DOMAIN_ID                                = 0x05
+	EC_SUBTYPE_OSPF_ROUTE_TYPE                               = 0x06
+	EC_SUBTYPE_OSPF_ROUTER_ID                                = 0x07
+	EC_SUBTYPE_SOURCE_AS                                     = 0x09
+	EC_SUBTYPE_VRF_ROUTE_IMPORT                              = 0x0b
+	EC_SUBTYPE_COLOR                                         = 0x0b
+	EC_SUBTYPE_ENCAPSULATION                                 = 0x0c
+)
+
+// extendedCommunityTypes is a type and subtype pair. A subtype only has
+// a meaning within its type, COLOR and VRF_ROUTE_IMPORT share a value.
+type extendedCommunityTypes struct {
+	Type    ExtendedCommunityAttrType
+	SubType ExtendedCommunityAttrSubType
+}
+
+var extendedCommunitySubTypeNames = map[extendedCommunityTypes]string{
+	{EC_TYPE_TRANSITIVE_TWO_OCTET_AS_SPECIFIC, EC_SUBTYPE_ROUTE_TARGET}:    "RT",
+	{EC_TYPE_TRANSITIVE_TWO_OCTET_AS_SPECIFIC, EC_SUBTYPE_ROUTE_ORIGIN}:    "SoO",
+	{EC_TYPE_TRANSITIVE_TWO_OCTET_AS_SPECIFIC, EC_SUBTYPE_OSPF_DOMAIN_ID}:  "OSPF-DOMAIN-ID",
+	{EC_TYPE_TRANSITIVE_TWO_OCTET_AS_SPECIFIC, EC_SUBTYPE_SOURCE_AS}:       "SOURCE-AS",
+	{EC_TYPE_TRANSITIVE_IP4_SPECIFIC, EC_SUBTYPE_ROUTE_TARGET}:             "RT",
+	{EC_TYPE_TRANSITIVE_IP4_SPECIFIC, EC_SUBTYPE_ROUTE_ORIGIN}:             "SoO",
+	{EC_TYPE_TRANSITIVE_IP4_SPECIFIC, EC_SUBTYPE_OSPF_DOMAIN_ID}:           "OSPF-DOMAIN-ID",
+	{EC_TYPE_TRANSITIVE_IP4_SPECIFIC, EC_SUBTYPE_OSPF_ROUTER_ID}:           "OSPF-ROUTER-ID",
+	{EC_TYPE_TRANSITIVE_IP4_SPECIFIC, EC_SUBTYPE_VRF_ROUTE_IMPORT}:         "VRF-IMPORT",
+	{EC_TYPE_TRANSITIVE_FOUR_OCTET_AS_SPECIFIC, EC_SUBTYPE_ROUTE_TARGET}:   "RT",
+	{EC_TYPE_TRANSITIVE_FOUR_OCTET_AS_SPECIFIC, EC_SUBTYPE_ROUTE_ORIGIN}:   "SoO",
+	{EC_TYPE_TRANSITIVE_FOUR_OCTET_AS_SPECIFIC, EC_SUBTYPE_OSPF_DOMAIN_ID}: "OSPF-DOMAIN-ID",
+	{EC_TYPE_TRANSITIVE_FOUR_OCTET_AS_SPECIFIC, EC_SUBTYPE_SOURCE_AS}:      "SOURCE-AS",
+}
+
+// extendedCommunitySubTypeName names the subtype of a transitive or
+// non-transitive type, or writes it in hex when it has no name there.
+// The name of a non-transitive one is prefixed with "NT-".
+func extendedCommunitySubTypeName(t ExtendedCommunityAttrType, subtype ExtendedCommunityAttrSubType) string {
+	name, ok := extendedCommunitySubTypeNames[extendedCommunityTypes{t &^ 0x40, subtype}]
+	if !ok {
+		name = subtype.String()
+	}
+	if t&0x40 != 0 {
+		return "NT-" + name
+	}
+	return name
+}
+
+func (t ExtendedCommunityAttrSubType) String() string {
+	return fmt.Sprintf("0x%02x", uint8(t))
+}
+
+func extendedCommunityType(base ExtendedCommunityAttrType, isTransitive bool) ExtendedCommunityAttrType {
+	if isTransitive {
+		return base
+	}
+	return base | 0x40
+}
+
+type ExtendedCommunityInterface interface {
+	Serialize() ([]byte, error)
+	String() string
+	GetTypes() (ExtendedCommunityAttrType, ExtendedCommunityAttrSubType)
+}
+
+type TwoOctetAsSpecificExtended struct {
+	SubType      ExtendedCommunityAttrSubType
+	AS           uint16
+	LocalAdmin   uint32
+	IsTransitive bool
+}
+
+func NewTwoOctetAsSpecificExtended(subtype ExtendedCommunityAttrSubType, as uint16, localAdmin uint32, isTransitive bool) *TwoOctetAsSpecificExtended {
+	return &TwoOctetAsSpecificExtended{subtype, as, localAdmin, isTransitive}
+}
+
+func (e *TwoOctetAsSpecificExtended) Serialize() ([]byte, error) {
+	buf := make([]byte, 8)
+	buf[0] = uint8(extendedCommunityType(EC_TYPE_TRANSITIVE_TWO_OCTET_AS_SPECIFIC, e.IsTransitive))
+	buf[1] = uint8(e.SubType)
+	binary.BigEndian.PutUint16(buf[2:4], e.AS)
+	binary.BigEndian.PutUint32(buf[4:8], e.LocalAdmin)
+	return buf, nil
+}
+
+func (e *TwoOctetAsSpecificExtended) String() string {
+	return fmt.Sprintf("%s:%d:%d", extendedCommunitySubTypeName(e.GetTypes()), e.AS, e.LocalAdmin)
+}
+
+func (e *TwoOctetAsSpecificExtended) GetTypes() (ExtendedCommunityAttrType, ExtendedCommunityAttrSubType) {
+	return extendedCommunityType(EC_TYPE_TRANSITIVE_TWO_OCTET_AS_SPECIFIC, e.IsTransitive), e.SubType
+}
+
+type IPv4AddressSpecificExtended struct {
+	SubType      ExtendedCommunityAttrSubType
+	IPv4         net.IP
+	LocalAdmin   uint16
+	IsTransitive bool
+}
+
+func NewIPv4AddressSpecificExtended(subtype ExtendedCommunityAttrSubType, ip net.IP, localAdmin uint16, isTransitive bool) *IPv4AddressSpecificExtended {
+	return &IPv4AddressSpecificExtended{subtype, ip, localAdmin, isTransitive}
+}
+
+func (e *IPv4AddressSpecificExtended) Serialize() ([]byte, error) {
+	ip := e.IPv4.To4()
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IPv4 address in extended community: %s", e.IPv4)
+	}
+	buf := make([]byte, 8)
+	buf[0] = uint8(extendedCommunityType(EC_TYPE_TRANSITIVE_IP4_SPECIFIC, e.IsTransitive))
+	buf[1] = uint8(e.SubType)
+	copy(buf[2:6], ip)
+	binary.BigEndian.PutUint16(buf[6:8], e.LocalAdmin)
+	return buf, nil
+}
+
+func (e *IPv4AddressSpecificExtended) String() string {
+	return fmt.Sprintf("%s:%s:%d", extendedCommunitySubTypeName(e.GetTypes()), e.IPv4, e.LocalAdmin)
+}
+
+func (e *IPv4AddressSpecificExtended) GetTypes() (ExtendedCommunityAttrType, ExtendedCommunityAttrSubType) {
+	return extendedCommunityType(EC_TYPE_TRANSITIVE_IP4_SPECIFIC, e.IsTransitive), e.SubType
+}
+
+type FourOctetAsSpecificExtended struct {
+	SubType      ExtendedCommunityAttrSubType
+	AS           uint32
+	LocalAdmin   uint16
+	IsTransitive bool
+}
+
+func NewFourOctetAsSpecificExtended(subtype ExtendedCommunityAttrSubType, as uint32, localAdmin uint16, isTransitive bool) *FourOctetAsSpecificExtended {
+	return &FourOctetAsSpecificExtended{subtype, as, localAdmin, isTransitive}
+}
+
+func (e *FourOctetAsSpecificExtended) Serialize() ([]byte, error) {
+	buf := make([]byte, 8)
+	buf[0] = uint8(extendedCommunityType(EC_TYPE_TRANSITIVE_FOUR_OCTET_AS_SPECIFIC, e.IsTransitive))
+	buf[1] = uint8(e.SubType)
+	binary.BigEndian.PutUint32(buf[2:6], e.AS)
+	binary.BigEndian.PutUint16(buf[6:8], e.LocalAdmin)
+	return buf, nil
+}
+
+// String marks ASes that would also fit in two octets with an "L" so
+// that ParseExtendedCommunity gives back the same type.
+func (e *FourOctetAsSpecificExtended) String() string {
+	name := extendedCommunitySubTypeName(e.GetTypes())
+	if e.AS <= math.MaxUint16 {
+		return fmt.Sprintf("%s:%dL:%d", name, e.AS, e.LocalAdmin)
+	}
+	return fmt.Sprintf("%s:%d:%d", name, e.AS, e.LocalAdmin)
+}
+
+func (e *FourOctetAsSpecificExtended) GetTypes() (ExtendedCommunityAttrType, ExtendedCommunityAttrSubType) {
+	return extendedCommunityType(EC_TYPE_TRANSITIVE_FOUR_OCTET_AS_SPECIFIC, e.IsTransitive), e.SubType
+}
+
+// LinkBandwidthExtended carries the bandwidth of the link to an eBGP
+// neighbor in bytes per second (draft-ietf-idr-link-bandwidth). It is
+// usually non-transitive, but a transitive one keeps its type.
+type LinkBandwidthExtended struct {
+	AS           uint16
+	Bandwidth    float32
+	IsTransitive bool
+}
+
+func NewLinkBandwidthExtended(as uint16, bandwidth float32) *LinkBandwidthExtended {
+	return &LinkBandwidthExtended{AS: as, Bandwidth: bandwidth}
+}
+
+func (e *LinkBandwidthExtended) Serialize() ([]byte, error) {
+	buf := make([]byte, 8)
+	buf[0] = uint8(extendedCommunityType(EC_TYPE_TRANSITIVE_TWO_OCTET_AS_SPECIFIC, e.IsTransitive))
+	buf[1] = EC_SUBTYPE_LINK_BANDWIDTH
+	binary.BigEndian.PutUint16(buf[2:4], e.AS)
+	binary.BigEndian.PutUint32(buf[4:8], math.Float32bits(e.Bandwidth))
+	return buf, nil
+}
+
+func (e *LinkBandwidthExtended) String() string {
+	return fmt.Sprintf("LB:%d:%g", e.AS, e.Bandwidth)
+}
+
+func (e *LinkBandwidthExtended) GetTypes() (ExtendedCommunityAttrType, ExtendedCommunityAttrSubType) {
+	return extendedCommunityType(EC_TYPE_TRANSITIVE_TWO_OCTET_AS_SPECIFIC, e.IsTransitive), EC_SUBTYPE_LINK_BANDWIDTH
+}
+
+type OpaqueExtended struct {
+	SubType      ExtendedCommunityAttrSubType
+	Value        []byte
+	IsTransitive bool
+}
+
+func (e *OpaqueExtended) Serialize() ([]byte, error) {
+	if len(e.Value) != 6 {
+		return nil, fmt.Errorf("opaque extended community value must be 6 bytes")
+	}
+	buf := make([]byte, 2, 8)
+	buf[0] = uint8(extendedCommunityType(EC_TYPE_TRANSITIVE_OPAQUE, e.IsTransitive))
+	buf[1] = uint8(e.SubType)
+	return append(buf, e.Value...), nil
+}
+
+func (e *OpaqueExtended) String() string {
+	if e.SubType == EC_SUBTYPE_OSPF_ROUTE_TYPE && len(e.Value) == 6 {
+		return fmt.Sprintf("OSPF-ROUTE-TYPE:%s:%d:%d", net.IP(e.Value[0:4]), e.Value[4], e.Value[5])
+	}
+	return fmt.Sprintf("%s:%x", extendedCommunitySubTypeName(e.GetTypes()), e.Value)
+}
+
+func (e *OpaqueExtended) GetTypes() (ExtendedCommunityAttrType, ExtendedCommunityAttrSubType) {
+	return extendedCommunityType(EC_TYPE_TRANSITIVE_OPAQUE, e.IsTransitive), e.SubType
+}
+
+// Tunnel types of the Encapsulation extended community and the Tunnel
+// Encapsulation attribute  RFC 9012
+type TunnelType uint16
+
+const (
+	TUNNEL_TYPE_L2TP3       TunnelType = 1
+	TUNNEL_TYPE_GRE                    = 2
+	TUNNEL_TYPE_IP_IN_IP               = 7
+	TUNNEL_TYPE_VXLAN                  = 8
+	TUNNEL_TYPE_NVGRE                  = 9
+	TUNNEL_TYPE_MPLS                   = 10
+	TUNNEL_TYPE_MPLS_IN_GRE            = 11
+	TUNNEL_TYPE_VXLAN_GPE              = 12
+	TUNNEL_TYPE_MPLS_IN_UDP            = 13
+	TUNNEL_TYPE_SR_POLICY              = 15
+	TUNNEL_TYPE_GENEVE                 = 19
+)
+
+var tunnelTypeNames = map[TunnelType]string{
+	TUNNEL_TYPE_L2TP3:       "L2TPv3",
+	TUNNEL_TYPE_GRE:         "GRE",
+	TUNNEL_TYPE_IP_IN_IP:    "IP-in-IP",
+	TUNNEL_TYPE_VXLAN:       "VXLAN",
+	TUNNEL_TYPE_NVGRE:       "NVGRE",
+	TUNNEL_TYPE_MPLS:        "MPLS",
+	TUNNEL_TYPE_MPLS_IN_GRE: "MPLS-in-GRE",
+	TUNNEL_TYPE_VXLAN_GPE:   "VXLAN-GPE",
+	TUNNEL_TYPE_MPLS_IN_UDP: "MPLS-in-UDP",
+	TUNNEL_TYPE_SR_POLICY:   "SR-Policy",
+	TUNNEL_TYPE_GENEVE:      "Geneve",
+}
+
+func (t TunnelType) String() string {
+	if name, ok := tunnelTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("%d", uint16(t))
+}
+
+func parseTunnelType(value string) (TunnelType, error) {
+	for t, name := range tunnelTypeNames {
+		if strings.EqualFold(value, name) {
+			return t, nil
+		}
+	}
+	v, err := strconv.ParseUint(value, 10, 16)
+	if err != nil {
+		return 0, fmt.Errorf("unknown tunnel type: %s", value)
+	}
+	return TunnelType(v), nil
+}
+
+type EncapExtended struct {
+	TunnelType TunnelType
+}
+
+func NewEncapExtended(tunnelType TunnelType) *EncapExtended {
+	return &EncapExtended{tunnelType}
+}
+
+func (e *EncapExtended) Serialize() ([]byte, error) {
+	buf := make([]byte, 8)
+	buf[0] = EC_TYPE_TRANSITIVE_OPAQUE
+	buf[1] = EC_SUBTYPE_ENCAPSULATION
+	binary.BigEndian.PutUint16(buf[6:8], uint16(e.TunnelType))
+	return buf, nil
+}
+
+func (e *EncapExtended) String() string {
+	return fmt.Sprintf("ENCAP:%s", e.TunnelType)
+}
+
+func (e *EncapExtended) GetTypes() (ExtendedCommunityAttrType, ExtendedCommunityAttrSubType) {
+	return EC_TYPE_TRANSITIVE_OPAQUE, EC_SUBTYPE_ENCAPSULATION
+}
+
+// ColorExtended steers a route into the SR Policy of the same color.
+// ColorOnly holds the CO bits of RFC 9256 8.8.
+type ColorExtended struct {
+	Color     uint32
+	ColorOnly uint8
+}
+
+func NewColorExtended(color uint32, colorOnly uint8) *ColorExtended {
+	return &ColorExtended{color, colorOnly}
+}
+
+func (e *ColorExtended) Serialize() ([]byte, error) {
+	if e.ColorOnly > 3 {
+		return nil, fmt.Errorf("invalid CO bits: %d", e.ColorOnly)
+	}
+	buf := make([]byte, 8)
+	buf[0] = EC_TYPE_TRANSITIVE_OPAQUE
+	buf[1] = EC_SUBTYPE_COLOR
+	buf[2] = e.ColorOnly << 6
+	binary.BigEndian.PutUint32(buf[4:8], e.Color)
+	return buf, nil
+}
+
+func (e *ColorExtended) String() string {
+	if e.ColorOnly != 0 {
+		return fmt.Sprintf("COLOR:%d:%02b", e.Color, e.ColorOnly)
+	}
+	return fmt.Sprintf("COLOR:%d", e.Color)
+}
+
+func (e *ColorExtended) GetTypes() (ExtendedCommunityAttrType, ExtendedCommunityAttrSubType) {
+	return EC_TYPE_TRANSITIVE_OPAQUE, EC_SUBTYPE_COLOR
+}
+
+func parseColor(value, rest string) (ExtendedCommunityInterface, error) {
+	elems := strings.Split(rest, ":")
+	if len(elems) > 2 {
+		return nil, fmt.Errorf("invalid extended community format: %s", value)
+	}
+	color, err := strconv.ParseUint(elems[0], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid extended community format: %s", value)
+	}
+	var co uint64
+	if len(elems) == 2 {
+		co, err = strconv.ParseUint(elems[1], 2, 2)
+		if err != nil {
+			return nil, fmt.Errorf("invalid extended community format: %s", value)
+		}
+	}
+	return NewColorExtended(uint32(color), uint8(co)), nil
+}
+
+// EVPN extended communities  RFC 7432 7.5 - 7.9, RFC 8214 3.1
+const (
+	EC_SUBTYPE_MAC_MOBILITY      ExtendedCommunityAttrSubType = 0x00
+	EC_SUBTYPE_ESI_LABEL                                      = 0x01
+	EC_SUBTYPE_ES_IMPORT                                      = 0x02
+	EC_SUBTYPE_ROUTER_MAC                                     = 0x03
+	EC_SUBTYPE_LAYER2_ATTRIBUTES                              = 0x04
+	EC_SUBTYPE_DEFAULT_GATEWAY                                = 0x0d
+)
+
+type MacMobilityExtended struct {
+	Sequence uint32
+	IsSticky bool
+}
+
+func NewMacMobilityExtended(seq uint32, isSticky bool) *MacMobilityExtended {
+	return &MacMobilityExtended{seq, isSticky}
+}
+
+func (e *MacMobilityExtended) Serialize() ([]byte, error) {
+	buf := make([]byte, 8)
+	buf[0] = EC_TYPE_EVPN
+	buf[1] = uint8(EC_SUBTYPE_MAC_MOBILITY)
+	if e.IsSticky {
+		buf[2] = 1
+	}
+	binary.BigEndian.PutUint32(buf[4:8], e.Sequence)
+	return buf, nil
+}
+
+func (e *MacMobilityExtended) String() string {
+	if e.IsSticky {
+		return fmt.Sprintf("MAC-MOBILITY:%d:sticky", e.Sequence)
+	}
+	return fmt.Sprintf("MAC-MOBILITY:%d", e.Sequence)
+}
+
+func (e *MacMobilityExtended) GetTypes() (ExtendedCommunityAttrType, ExtendedCommunityAttrSubType) {
+	return EC_TYPE_EVPN, EC_SUBTYPE_MAC_MOBILITY
+}
+
+type ESILabelExtended struct {
+	Label          uint32
+	IsSingleActive bool
+}
+
+func NewESILabelExtended(label uint32, isSingleActive bool) *ESILabelExtended {
+	return &ESILabelExtended{label, isSingleActive}
+}
+
+func (e *ESILabelExtended) Serialize() ([]byte, error) {
+	if e.Label > MPLS_LABEL_MAX {
+		return nil, fmt.Errorf("label %d is out of range", e.Label)
+	}
+	buf := make([]byte, 8)
+	buf[0] = EC_TYPE_EVPN
+	buf[1] = EC_SUBTYPE_ESI_LABEL
+	if e.IsSingleActive {
+		buf[2] = 1
+	}
+	label := e.Label << 4
+	buf[5] = byte(label >> 16)
+	buf[6] = byte(label >> 8)
+	buf[7] = byte(label)
+	return buf, nil
+}
+
+func (e *ESILabelExtended) String() string {
+	if e.IsSingleActive {
+		return fmt.Sprintf("ESI-LABEL:%d:single-active", e.Label)
+	}
+	return fmt.Sprintf("ESI-LABEL:%d", e.Label)
+}
+
+func (e *ESILabelExtended) GetTypes() (ExtendedCommunityAttrType, ExtendedCommunityAttrSubType) {
+	return EC_TYPE_EVPN, EC_SUBTYPE_ESI_LABEL
+}
+
+type ESImportRouteTarget struct {
+	ESImport net.HardwareAddr
+}
+
+func NewESImportRouteTarget(mac net.HardwareAddr) *ESImportRouteTarget {
+	return &ESImportRouteTarget{mac}
+}
+
+func (e *ESImportRouteTarget) Serialize() ([]byte, error) {
+	if len(e.ESImport) != 6 {
+		return nil, fmt.Errorf("invalid ES-Import MAC address: %s", e.ESImport)
+	}
+	buf := make([]byte, 2, 8)
+	buf[0] = EC_TYPE_EVPN
+	buf[1] = EC_SUBTYPE_ES_IMPORT
+	return append(buf, e.ESImport...), nil
+}
+
+func (e *ESImportRouteTarget) String() string {
+	return fmt.Sprintf("ES-IMPORT:%s", e.ESImport)
+}
+
+func (e *ESImportRouteTarget) GetTypes() (ExtendedCommunityAttrType, ExtendedCommunityAttrSubType) {
+	return EC_TYPE_EVPN, EC_SUBTYPE_ES_IMPORT
+}
+
+type RouterMacExtended struct {
+	Mac net.HardwareAddr
+}
+
+func NewRouterMacExtended(mac net.HardwareAddr) *RouterMacExtended {
+	return &RouterMacExtended{mac}
+}
+
+func (e *RouterMacExtended) Serialize() ([]byte, error) {
+	if len(e.Mac) != 6 {
+		return nil, fmt.Errorf("invalid router MAC address: %s", e.Mac)
+	}
+	buf := make([]byte, 2, 8)
+	buf[0] = EC_TYPE_EVPN
+	buf[1] = EC_SUBTYPE_ROUTER_MAC
+	return append(buf, e.Mac...), nil
+}
+
+func (e *RouterMacExtended) String() string {
+	return fmt.Sprintf("ROUTER-MAC:%s", e.Mac)
+}
+
+func (e *RouterMacExtended) GetTypes() (ExtendedCommunityAttrType, ExtendedCommunityAttrSubType) {
+	return EC_TYPE_EVPN, EC_SUBTYPE_ROUTER_MAC
+}
+
+// Control flags of the Layer 2 Attributes extended community
+const (
+	LAYER2_ATTR_FLAG_BACKUP       = 1 << 0
+	LAYER2_ATTR_FLAG_PRIMARY      = 1 << 1
+	LAYER2_ATTR_FLAG_CONTROL_WORD = 1 << 2
+)
+
+type Layer2AttributesExtended struct {
+	ControlFlags uint16
+	MTU          uint16
+}
+
+func NewLayer2AttributesExtended(flags uint16, mtu uint16) *Layer2AttributesExtended {
+	return &Layer2AttributesExtended{flags, mtu}
+}
+
+func (e *Layer2AttributesExtended) Serialize() ([]byte, error) {
+	buf := make([]byte, 8)
+	buf[0] = EC_TYPE_EVPN
+	buf[1] = EC_SUBTYPE_LAYER2_ATTRIBUTES
+	binary.BigEndian.PutUint16(buf[2:4], e.ControlFlags)
+	binary.BigEndian.PutUint16(buf[4:6], e.MTU)
+	return buf, nil
+}
+
+func (e *Layer2AttributesExtended) String() string {
+	var flags []string
+	if e.ControlFlags&LAYER2_ATTR_FLAG_PRIMARY != 0 {
+		flags = append(flags, "P")
+	}
+	if e.ControlFlags&LAYER2_ATTR_FLAG_BACKUP != 0 {
+		flags = append(flags, "B")
+	}
+	if e.ControlFlags&LAYER2_ATTR_FLAG_CONTROL_WORD != 0 {
+		flags = append(flags, "C")
+	}
+	return fmt.Sprintf("L2-ATTR:%d:%s", e.MTU, strings.Join(flags, ""))
+}
+
+func (e *Layer2AttributesExtended) GetTypes() (ExtendedCommunityAttrType, ExtendedCommunityAttrSubType) {
+	return EC_TYPE_EVPN, EC_SUBTYPE_LAYER2_ATTRIBUTES
+}
+
+type DefaultGatewayExtended struct {
+}
+
+func NewDefaultGatewayExtended() *DefaultGatewayExtended {
+	return &DefaultGatewayExtended{}
+}
+
+func (e *DefaultGatewayExtended) Serialize() ([]byte, error) {
+	buf := make([]byte, 8)
+	buf[0] = EC_TYPE_TRANSITIVE_OPAQUE
+	buf[1] = EC_SUBTYPE_DEFAULT_GATEWAY
+	return buf, nil
+}
+
+func (e *DefaultGatewayExtended) String() string {
+	return "DEFAULT-GATEWAY"
+}
+
+func (e *DefaultGatewayExtended) GetTypes() (ExtendedCommunityAttrType, ExtendedCommunityAttrSubType) {
+	return EC_TYPE_TRANSITIVE_OPAQUE, EC_SUBTYPE_DEFAULT_GATEWAY
+}
+
+func parseEvpnExtended(data []byte) ExtendedCommunityInterface {
+	switch ExtendedCommunityAttrSubType(data[1]) {
+	case EC_SUBTYPE_MAC_MOBILITY:
+		e := &MacMobilityExtended{}
+		e.IsSticky = data[2]&1 != 0
+		e.Sequence = binary.BigEndian.Uint32(data[4:8])
+		return e
+	case EC_SUBTYPE_ESI_LABEL:
+		e := &ESILabelExtended{}
+		e.IsSingleActive = data[2]&1 != 0
+		e.Label = (uint32(data[5])<<16 | uint32(data[6])<<8 | uint32(data[7])) >> 4
+		return e
+	case EC_SUBTYPE_ES_IMPORT:
+		return &ESImportRouteTarget{net.HardwareAddr(data[2:8])}
+	case EC_SUBTYPE_ROUTER_MAC:
+		return &RouterMacExtended{net.HardwareAddr(data[2:8])}
+	case EC_SUBTYPE_LAYER2_ATTRIBUTES:
+		e := &Layer2AttributesExtended{}
+		e.ControlFlags = binary.BigEndian.Uint16(data[2:4])
+		e.MTU = binary.BigEndian.Uint16(data[4:6])
+		return e
+	}
+	e := &UnknownExtended{}
+	e.Type = ExtendedCommunityAttrType(data[0])
+	e.SubType = ExtendedCommunityAttrSubType(data[1])
+	e.Value = data[2:8]
+	return e
+}
+
+// parseEvpnCommunity parses the part after the name of the String form
+// of an EVPN extended community.
+func parseEvpnCommunity(value, name, rest string) (ExtendedCommunityInterface, error) {
+	switch name {
+	case "es-import", "router-mac":
+		mac, err := net.ParseMAC(rest)
+		if err != nil || len(mac) != 6 {
+			return nil, fmt.Errorf("invalid extended community format: %s", value)
+		}
+		if name == "es-import" {
+			return NewESImportRouteTarget(mac), nil
+		}
+		return NewRouterMacExtended(mac), nil
+	}
+	elems := strings.Split(rest, ":")
+	if len(elems) > 2 {
+		return nil, fmt.Errorf("invalid extended community format: %s", value)
+	}
+	v, err := strconv.ParseUint(elems[0], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid extended community format: %s", value)
+	}
+	flag := ""
+	if len(elems) == 2 {
+		flag = elems[1]
+		if flag == "" && name != "l2-attr" {
+			return nil, fmt.Errorf("invalid extended community format: %s", value)
+		}
+	}
+	switch name {
+	case "mac-mobility":
+		if flag != "" && flag != "sticky" {
+			break
+		}
+		return NewMacMobilityExtended(uint32(v), flag == "sticky"), nil
+	case "esi-label":
+		if v > MPLS_LABEL_MAX || flag != "" && flag != "single-active" {
+			break
+		}
+		return NewESILabelExtended(uint32(v), flag == "single-active"), nil
+	case "l2-attr":
+		if v > math.MaxUint16 || len(elems) != 2 {
+			break
+		}
+		var flags uint16
+		for _, c := range flag {
+			switch c {
+			case 'P':
+				flags |= LAYER2_ATTR_FLAG_PRIMARY
+			case 'B':
+				flags |= LAYER2_ATTR_FLAG_BACKUP
+			case 'C':
+				flags |= LAYER2_ATTR_FLAG_CONTROL_WORD
+			default:
+				return nil, fmt.Errorf("invalid extended community format: %s", value)
+			}
+		}
+		return NewLayer2AttributesExtended(flags, uint16(v)), nil
+	}
+	return nil, fmt.Errorf("invalid extended community format: %s", value)
+}
+
+// Flow Specification actions  RFC 8955 7
+const (
+	EC_TYPE_GENERIC_TRANSITIVE_EXPERIMENTAL  ExtendedCommunityAttrType = 0x80
+	EC_TYPE_GENERIC_TRANSITIVE_EXPERIMENTAL2                           = 0x81
+	EC_TYPE_GENERIC_TRANSITIVE_EXPERIMENTAL3                           = 0x82
+)
+
+const (
+	EC_SUBTYPE_FLOWSPEC_TRAFFIC_RATE         ExtendedCommunityAttrSubType = 0x06
+	EC_SUBTYPE_FLOWSPEC_TRAFFIC_ACTION                                    = 0x07
+	EC_SUBTYPE_FLOWSPEC_REDIRECT                                          = 0x08
+	EC_SUBTYPE_FLOWSPEC_TRAFFIC_REMARK                                    = 0x09
+	EC_SUBTYPE_FLOWSPEC_TRAFFIC_RATE_PACKETS                              = 0x0c
+)
+
+// TrafficRateExtended limits the matching traffic to Rate bytes per
+// second, or packets per second when InPackets is set. A rate of 0
+// discards the traffic.
+type TrafficRateExtended struct {
+	AS        uint16
+	Rate      float32
+	InPackets bool
+}
+
+func NewTrafficRateExtended(as uint16, rate float32) *TrafficRateExtended {
+	return &TrafficRateExtended{AS: as, Rate: rate}
+}
+
+func (e *TrafficRateExtended) subtype() ExtendedCommunityAttrSubType {
+	if e.InPackets {
+		return EC_SUBTYPE_FLOWSPEC_TRAFFIC_RATE_PACKETS
+	}
+	return EC_SUBTYPE_FLOWSPEC_TRAFFIC_RATE
+}
+
+func (e *TrafficRateExtended) Serialize() ([]byte, error) {
+	buf := make([]byte, 8)
+	buf[0] = uint8(EC_TYPE_GENERIC_TRANSITIVE_EXPERIMENTAL)
+	buf[1] = uint8(e.subtype())
+	binary.BigEndian.PutUint16(buf[2:4], e.AS)
+	binary.BigEndian.PutUint32(buf[4:8], math.Float32bits(e.Rate))
+	return buf, nil
+}
+
+func (e *TrafficRateExtended) String() string {
+	unit := ""
+	if e.InPackets {
+		unit = "pps"
+	}
+	if e.Rate == 0 {
+		return "discard"
+	}
+	if e.AS != 0 {
+		return fmt.Sprintf("rate:%d:%g%s", e.AS, e.Rate, unit)
+	}
+	return fmt.Sprintf("rate:%g%s", e.Rate, unit)
+}
+
+func (e *TrafficRateExtended) GetTypes() (ExtendedCommunityAttrType, ExtendedCommunityAttrSubType) {
+	return EC_TYPE_GENERIC_TRANSITIVE_EXPERIMENTAL, e.subtype()
+}
+
+type TrafficActionExtended struct {
+	Terminal bool
+	Sample   bool
+}
+
+func NewTrafficActionExtended(terminal bool, sample bool) *TrafficActionExtended {
+	return &TrafficActionExtended{terminal, sample}
+}
+
+func (e *TrafficActionExtended) Serialize() ([]byte, error) {
+	buf := make([]byte, 8)
+	buf[0] = uint8(EC_TYPE_GENERIC_TRANSITIVE_EXPERIMENTAL)
+	buf[1] = EC_SUBTYPE_FLOWSPEC_TRAFFIC_ACTION
+	if e.Terminal {
+		buf[7] |= 0x01
+	}
+	if e.Sample {
+		buf[7] |= 0x02
+	}
+	return buf, nil
+}
+
+func (e *TrafficActionExtended) String() string {
+	var actions []string
+	if e.Terminal {
+		actions = append(actions, "terminal")
+	}
+	if e.Sample {
+		actions = append(actions, "sample")
+	}
+	return fmt.Sprintf("action:%s", strings.Join(actions, "-"))
+}
+
+func (e *TrafficActionExtended) GetTypes() (ExtendedCommunityAttrType, ExtendedCommunityAttrSubType) {
+	return EC_TYPE_GENERIC_TRANSITIVE_EXPERIMENTAL, EC_SUBTYPE_FLOWSPEC_TRAFFIC_ACTION
+}
+
+// RedirectExtended redirects the matching traffic to the VRF importing
+// Target, which is a route target shaped two octet AS, IPv4 address or
+// four octet AS specific community.
+type RedirectExtended struct {
+	Target ExtendedCommunityInterface
+}
+
+func NewRedirectExtended(target ExtendedCommunityInterface) *RedirectExtended {
+	return &RedirectExtended{target}
+}
+
+func (e *RedirectExtended) Serialize() ([]byte, error) {
+	if e.Target == nil {
+		return nil, fmt.Errorf("redirect extended community has no target")
+	}
+	buf, err := e.Target.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	t, _ := e.Target.GetTypes()
+	switch t {
+	case EC_TYPE_TRANSITIVE_TWO_OCTET_AS_SPECIFIC:
+		buf[0] = uint8(EC_TYPE_GENERIC_TRANSITIVE_EXPERIMENTAL)
+	case EC_TYPE_TRANSITIVE_IP4_SPECIFIC:
+		buf[0] = EC_TYPE_GENERIC_TRANSITIVE_EXPERIMENTAL2
+	case EC_TYPE_TRANSITIVE_FOUR_OCTET_AS_SPECIFIC:
+		buf[0] = EC_TYPE_GENERIC_TRANSITIVE_EXPERIMENTAL3
+	default:
+		return nil, fmt.Errorf("invalid redirect target: %s", e.Target)
+	}
+	buf[1] = EC_SUBTYPE_FLOWSPEC_REDIRECT
+	return buf, nil
+}
+
+func (e *RedirectExtended) String() string {
+	s := e.Target.String()
+	return "redirect:" + s[strings.Index(s, ":")+1:]
+}
+
+func (e *RedirectExtended) GetTypes() (ExtendedCommunityAttrType, ExtendedCommunityAttrSubType) {
+	t, _ := e.Target.GetTypes()
+	switch t {
+	case EC_TYPE_TRANSITIVE_IP4_SPECIFIC:
+		return EC_TYPE_GENERIC_TRANSITIVE_EXPERIMENTAL2, EC_SUBTYPE_FLOWSPEC_REDIRECT
+	case EC_TYPE_TRANSITIVE_FOUR_OCTET_AS_SPECIFIC:
+		return EC_TYPE_GENERIC_TRANSITIVE_EXPERIMENTAL3, EC_SUBTYPE_FLOWSPEC_REDIRECT
+	}
+	return EC_TYPE_GENERIC_TRANSITIVE_EXPERIMENTAL, EC_SUBTYPE_FLOWSPEC_REDIRECT
+}
+
+type TrafficRemarkExtended struct {
+	DSCP uint8
+}
+
+func NewTrafficRemarkExtended(dscp uint8) *TrafficRemarkExtended {
+	return &TrafficRemarkExtended{dscp}
+}
+
+func (e *TrafficRemarkExtended) Serialize() ([]byte, error) {
+	if e.DSCP > 0x3f {
+		return nil, fmt.Errorf("invalid DSCP value: %d", e.DSCP)
+	}
+	buf := make([]byte, 8)
+	buf[0] = uint8(EC_TYPE_GENERIC_TRANSITIVE_EXPERIMENTAL)
+	buf[1] = EC_SUBTYPE_FLOWSPEC_TRAFFIC_REMARK
+	buf[7] = e.DSCP
+	return buf, nil
+}
+
+func (e *TrafficRemarkExtended) String() string {
+	return fmt.Sprintf("mark:%d", e.DSCP)
+}
+
+func (e *TrafficRemarkExtended) GetTypes() (ExtendedCommunityAttrType, ExtendedCommunityAttrSubType) {
+	return EC_TYPE_GENERIC_TRANSITIVE_EXPERIMENTAL, EC_SUBTYPE_FLOWSPEC_TRAFFIC_REMARK
+}
+
+// parseFlowSpecAction parses the part after the name of the String form
+// of a Flow Specification action.
+func parseFlowSpecAction(value, name, rest string) (ExtendedCommunityInterface, error) {
+	switch name {
+	case "rate":
+		e := &TrafficRateExtended{}
+		if i := strings.Index(rest, ":"); i >= 0 {
+			as, err := strconv.ParseUint(rest[:i], 10, 16)
+			if err != nil {
+				return nil, fmt.Errorf("invalid extended community format: %s", value)
+			}
+			e.AS = uint16(as)
+			rest = rest[i+1:]
+		}
+		if strings.HasSuffix(rest, "pps") {
+			e.InPackets = true
+			rest = strings.TrimSuffix(rest, "pps")
+		}
+		rate, err := strconv.ParseFloat(rest, 32)
+		if err != nil || rate <= 0 {
+			return nil, fmt.Errorf("invalid extended community format: %s", value)
+		}
+		e.Rate = float32(rate)
+		return e, nil
+	case "action":
+		e := &TrafficActionExtended{}
+		if rest == "" {
+			return e, nil
+		}
+		for _, a := range strings.Split(rest, "-") {
+			switch {
+			case a == "terminal" && !e.Terminal:
+				e.Terminal = true
+			case a == "sample" && !e.Sample:
+				e.Sample = true
+			default:
+				return nil, fmt.Errorf("invalid extended community format: %s", value)
+			}
+		}
+		return e, nil
+	case "redirect":
+		target, err := parseAdminAssigned(EC_SUBTYPE_ROUTE_TARGET, rest, true)
+		if err != nil {
+			return nil, err
+		}
+		return NewRedirectExtended(target), nil
+	case "mark":
+		dscp, err := strconv.ParseUint(rest, 10, 8)
+		if err != nil || dscp > 0x3f {
+			return nil, fmt.Errorf("invalid extended community format: %s", value)
+		}
+		return NewTrafficRemarkExtended(uint8(dscp)), nil
+	}
+	return nil, fmt.Errorf("invalid extended community format: %s", value)
+}
+
+// Layer2 Info Extended Community  RFC 4761 3.2.4
+const EC_SUBTYPE_L2_INFO ExtendedCommunityAttrSubType = 0x0a
+
+type Layer2EncapsulationType uint8
+
+const (
+	LAYER2_ENCAP_TYPE_FRAME_RELAY     Layer2EncapsulationType = 1
+	LAYER2_ENCAP_TYPE_ATM_AAL5                                = 2
+	LAYER2_ENCAP_TYPE_ATM_CELL                                = 3
+	LAYER2_ENCAP_TYPE_ETHERNET_TAGGED                         = 4
+	LAYER2_ENCAP_TYPE_ETHERNET                                = 5
+	LAYER2_ENCAP_TYPE_HDLC                                    = 6
+	LAYER2_ENCAP_TYPE_PPP                                     = 7
+	LAYER2_ENCAP_TYPE_VPLS                                    = 19
+)
+
+var layer2EncapTypeNames = map[Layer2EncapsulationType]string{
+	LAYER2_ENCAP_TYPE_FRAME_RELAY:     "frame-relay",
+	LAYER2_ENCAP_TYPE_ATM_AAL5:        "atm-aal5",
+	LAYER2_ENCAP_TYPE_ATM_CELL:        "atm-cell",
+	LAYER2_ENCAP_TYPE_ETHERNET_TAGGED: "ethernet-tagged",
+	LAYER2_ENCAP_TYPE_ETHERNET:        "ethernet",
+	LAYER2_ENCAP_TYPE_HDLC:            "hdlc",
+	LAYER2_ENCAP_TYPE_PPP:             "ppp",
+	LAYER2_ENCAP_TYPE_VPLS:            "vpls",
+}
+
+func (t Layer2EncapsulationType) String() string {
+	if name, ok := layer2EncapTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("%d", t)
+}
+
+const (
+	L2_INFO_FLAG_SEQUENCED    = 1 << 0
+	L2_INFO_FLAG_CONTROL_WORD = 1 << 1
+)
+
+type Layer2InfoExtended struct {
+	EncapsulationType Layer2EncapsulationType
+	ControlFlags      uint8
+	MTU               uint16
+}
+
+func NewLayer2InfoExtended(encap Layer2EncapsulationType, flags uint8, mtu uint16) *Layer2InfoExtended {
+	return &Layer2InfoExtended{encap, flags, mtu}
+}
+
+func (e *Layer2InfoExtended) Serialize() ([]byte, error) {
+	buf := make([]byte, 8)
+	buf[0] = uint8(EC_TYPE_GENERIC_TRANSITIVE_EXPERIMENTAL)
+	buf[1] = uint8(EC_SUBTYPE_L2_INFO)
+	buf[2] = uint8(e.EncapsulationType)
+	buf[3] = e.ControlFlags
+	binary.BigEndian.PutUint16(buf[4:6], e.MTU)
+	return buf, nil
+}
+
+func (e *Layer2InfoExtended) String() string {
+	var flags []string
+	if e.ControlFlags&L2_INFO_FLAG_CONTROL_WORD != 0 {
+		flags = append(flags, "C")
+	}
+	if e.ControlFlags&L2_INFO_FLAG_SEQUENCED != 0 {
+		flags = append(flags, "S")
+	}
+	return fmt.Sprintf("L2-INFO:%s:%d:%s", e.EncapsulationType, e.MTU, strings.Join(flags, ""))
+}
+
+func (e *Layer2InfoExtended) GetTypes() (ExtendedCommunityAttrType, ExtendedCommunityAttrSubType) {
+	return EC_TYPE_GENERIC_TRANSITIVE_EXPERIMENTAL, EC_SUBTYPE_L2_INFO
+}
+
+// parseLayer2Info parses the "<encapsulation>:<mtu>:<flags>" part of the
+// String form of a Layer2 Info community.
+func parseLayer2Info(value, rest string) (ExtendedCommunityInterface, error) {
+	elems := strings.Split(rest, ":")
+	if len(elems) != 3 {
+		return nil, fmt.Errorf("invalid extended community format: %s", value)
+	}
+	e := &Layer2InfoExtended{}
+	found := false
+	for t, name := range layer2EncapTypeNames {
+		if strings.EqualFold(elems[0], name) {
+			e.EncapsulationType = t
+			found = true
+		}
+	}
+	if !found {
+		t, err := strconv.ParseUint(elems[0], 10, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid extended community format: %s", value)
+		}
+		e.EncapsulationType = Layer2EncapsulationType(t)
+	}
+	mtu, err := strconv.ParseUint(elems[1], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid extended community format: %s", value)
+	}
+	e.MTU = uint16(mtu)
+	for _, c := range elems[2] {
+		switch c {
+		case 'C':
+			e.ControlFlags |= L2_INFO_FLAG_CONTROL_WORD
+		case 'S':
+			e.ControlFlags |= L2_INFO_FLAG_SEQUENCED
+		default:
+			return nil, fmt.Errorf("invalid extended community format: %s", value)
+		}
+	}
+	return e, nil
+}
+
+func parseExperimentalExtended(data []byte) ExtendedCommunityInterface {
+	t := ExtendedCommunityAttrType(data[0])
+	subtype := ExtendedCommunityAttrSubType(data[1])
+	if subtype == EC_SUBTYPE_FLOWSPEC_REDIRECT {
+		target := make([]byte, 8)
+		copy(target, data)
+		switch t {
+		case EC_TYPE_GENERIC_TRANSITIVE_EXPERIMENTAL:
+			target[0] = uint8(EC_TYPE_TRANSITIVE_TWO_OCTET_AS_SPECIFIC)
+		case EC_TYPE_GENERIC_TRANSITIVE_EXPERIMENTAL2:
+			target[0] = EC_TYPE_TRANSITIVE_IP4_SPECIFIC
+		case EC_TYPE_GENERIC_TRANSITIVE_EXPERIMENTAL3:
+			target[0] = EC_TYPE_TRANSITIVE_FOUR_OCTET_AS_SPECIFIC
+		}
+		target[1] = uint8(EC_SUBTYPE_ROUTE_TARGET)
+		return &RedirectExtended{parseExtended(target)}
+	}
+	if t == EC_TYPE_GENERIC_TRANSITIVE_EXPERIMENTAL {
+		switch subtype {
+		case EC_SUBTYPE_FLOWSPEC_TRAFFIC_RATE, EC_SUBTYPE_FLOWSPEC_TRAFFIC_RATE_PACKETS:
+			e := &TrafficRateExtended{}
+			e.AS = binary.BigEndian.Uint16(data[2:4])
+			e.Rate = math.Float32frombits(binary.BigEndian.Uint32(data[4:8]))
+			e.InPackets = subtype == EC_SUBTYPE_FLOWSPEC_TRAFFIC_RATE_PACKETS
+			return e
+		case EC_SUBTYPE_FLOWSPEC_TRAFFIC_ACTION:
+			return &TrafficActionExtended{data[7]&0x01 != 0, data[7]&0x02 != 0}
+		case EC_SUBTYPE_FLOWSPEC_TRAFFIC_REMARK:
+			return &TrafficRemarkExtended{data[7] & 0x3f}
+		case EC_SUBTYPE_L2_INFO:
+			e := &Layer2InfoExtended{}
+			e.EncapsulationType = Layer2EncapsulationType(data[2])
+			e.ControlFlags = data[3]
+			e.MTU = binary.BigEndian.Uint16(data[4:6])
+			return e
+		}
+	}
+	e := &UnknownExtended{}
+	e.Type = t
+	e.SubType = subtype
+	e.Value = data[2:8]
+	return e
+}
+
+type UnknownExtended struct {
+	Type    ExtendedCommunityAttrType
+	SubType ExtendedCommunityAttrSubType
+	Value   []byte
+}
+
+func (e *UnknownExtended) Serialize() ([]byte, error) {
+	if len(e.Value) != 6 {
+		return nil, fmt.Errorf("extended community value must be 6 bytes")
+	}
+	buf := make([]byte, 2, 8)
+	buf[0] = uint8(e.Type)
+	buf[1] = uint8(e.SubType)
+	return append(buf, e.Value...), nil
+}
+
+func (e *UnknownExtended) String() string {
+	return fmt.Sprintf("0x%02x:0x%02x:%x", uint8(e.Type), uint8(e.SubType), e.Value)
+}
+
+func (e *UnknownExtended) GetTypes() (ExtendedCommunityAttrType, ExtendedCommunityAttrSubType) {
+	return e.Type, e.SubType
+}
+
+type PathAttributeExtendedCommunities struct {
+	PathAttribute
+	Value []ExtendedCommunityInterface
+}
+
+func NewPathAttributeExtendedCommunities(values []ExtendedCommunityInterface) *PathAttributeExtendedCommunities {
+	p := &PathAttributeExtendedCommunities{}
+	p.Flags = BGP_ATTR_FLAG_OPTIONAL | BGP_ATTR_FLAG_TRANSITIVE
+	p.Type = BGP_ATTR_TYPE_EXTENDED_COMMUNITIES
+	p.Value = values
+	p.PathAttribute.Length = uint16(8 * len(values))
+	return p
+}
+
+func parseExtended(data []byte) ExtendedCommunityInterface {
+	isTransitive := data[0]&0x40 == 0
+	subtype := ExtendedCommunityAttrSubType(data[1])
+	// only the first four types have a non-transitive twin, any other
+	// type with the bit set is kept as is  RFC 7153 2
+	typehigh := data[0]
+	if typehigh&^0x40 <= 3 {
+		typehigh &^= 0x40
+	}
+	switch typehigh {
+	case 0:
+		if subtype == EC_SUBTYPE_LINK_BANDWIDTH {
+			e := &LinkBandwidthExtended{}
+			e.AS = binary.BigEndian.Uint16(data[2:4])
+			e.Bandwidth = math.Float32frombits(binary.BigEndian.Uint32(data[4:8]))
+			e.IsTransitive = isTransitive
+			return e
+
+		}
+		e := &TwoOctetAsSpecificExtended{}
+		e.SubType = subtype
+		e.AS = binary.BigEndian.Uint16(data[2:4])
+		e.LocalAdmin = binary.BigEndian.Uint32(data[4:8])
+		e.IsTransitive = isTransitive
+		return e
+	case 1:
+		e := &IPv4AddressSpecificExtended{}
+		e.SubType = subtype
+		e.IPv4 = data[2:6]
+		e.LocalAdmin = binary.BigEndian.Uint16(data[6:8])
+		e.IsTransitive = isTransitive
+		return e
+	case 2:
+		e := &FourOctetAsSpecificExtended{}
+		e.SubType = subtype
+		e.AS = binary.BigEndian.Uint32(data[2:6])
+		e.LocalAdmin = binary.BigEndian.Uint16(data[6:8])
+		e.IsTransitive = isTransitive
+		return e
+	case 3:
+		if isTransitive {
+			switch subtype {
+			case EC_SUBTYPE_COLOR:
+				e := &ColorExtended{}
+				e.ColorOnly = data[2] >> 6
+				e.Color = binary.BigEndian.Uint32(data[4:8])
+				return e
+			case EC_SUBTYPE_ENCAPSULATION:
+				return &EncapExtended{TunnelType(binary.BigEndian.Uint16(data[6:8]))}
+			case EC_SUBTYPE_DEFAULT_GATEWAY:
+				return &DefaultGatewayExtended{}
+			}
+		}
+		e := &OpaqueExtended{}
+		e.SubType = subtype
+		e.Value = data[2:8]
+		e.IsTransitive = isTransitive
+		return e
+	case EC_TYPE_EVPN:
+		return parseEvpnExtended(data)
+	case uint8(EC_TYPE_GENERIC_TRANSITIVE_EXPERIMENTAL), EC_TYPE_GENERIC_TRANSITIVE_EXPERIMENTAL2,
+		EC_TYPE_GENERIC_TRANSITIVE_EXPERIMENTAL3:
+		return parseExperimentalExtended(data)
+	}
+	e := &UnknownExtended{}
+	e.Type = ExtendedCommunityAttrType(data[0])
+	e.SubType = subtype
+	e.Value = data[2:8]
+	return e
+}
+
+// ParseExtendedCommunity parses the "<subtype>:<admin>:<assigned>" form
+// produced by String, e.g. "RT:65000:100", "SoO:10.0.0.1:5" or
+// "RT:4200000000:7". A four octet AS that fits in two octets is written
+// with an "L" suffix, as in "RT:65000L:100", and a non-transitive one
+// with an "NT-" prefix, as in "NT-RT:65000:100". IPv6 administrators
+// are parsed by ParseIPv6ExtendedCommunity. The EVPN communities are
+// parsed from their String forms as well, and so are the Flow
+// Specification actions and the Layer2 Info community.
+func ParseExtendedCommunity(value string) (ExtendedCommunityInterface, error) {
+	switch strings.ToLower(value) {
+	case "default-gateway":
+		return NewDefaultGatewayExtended(), nil
+	case "discard":
+		return NewTrafficRateExtended(0, 0), nil
+	}
+	elems := strings.SplitN(value, ":", 2)
+	if len(elems) != 2 {
+		return nil, fmt.Errorf("invalid extended community format: %s", value)
+	}
+	switch name := strings.ToLower(elems[0]); name {
+	case "mac-mobility", "esi-label", "es-import", "router-mac", "l2-attr":
+		return parseEvpnCommunity(value, name, elems[1])
+	case "rate", "action", "redirect", "mark":
+		return parseFlowSpecAction(value, name, elems[1])
+	case "l2-info":
+		return parseLayer2Info(value, elems[1])
+	case "lb":
+		return parseLinkBandwidth(value, elems[1])
+	case "color":
+		return parseColor(value, elems[1])
+	case "encap":
+		t, err := parseTunnelType(elems[1])
+		if err != nil {
+			return nil, err
+		}
+		return NewEncapExtended(t), nil
+	}
+	name, transitive := elems[0], true
+	if len(name) > 3 && strings.EqualFold(name[:3], "NT-") {
+		name, transitive = name[3:], false
+	}
+	var subtype ExtendedCommunityAttrSubType
+	switch strings.ToLower(name) {
+	case "rt", "target":
+		subtype = EC_SUBTYPE_ROUTE_TARGET
+	case "soo", "ro", "origin":
+		subtype = EC_SUBTYPE_ROUTE_ORIGIN
+	default:
+		found := false
+		for t, n := range extendedCommunitySubTypeNames {
+			if strings.EqualFold(name, n) {
+				subtype = t.SubType
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown extended community subtype: %s", elems[0])
+		}
+		e, err := parseAdminAssigned(subtype, elems[1], transitive)
+		if err != nil {
+			return nil, err
+		}
+		// the subtype has to be defined for the administrator given
+		if !strings.EqualFold(elems[0], extendedCommunitySubTypeName(e.GetTypes())) {
+			return nil, fmt.Errorf("invalid extended community format: %s", value)
+		}
+		return e, nil
+	}
+	return parseAdminAssigned(subtype, elems[1], transitive)
+}
+
+// ParseRouteTarget parses a route target given as "admin:assigned".
+func ParseRouteTarget(value string) (ExtendedCommunityInterface, error) {
+	return parseAdminAssigned(EC_SUBTYPE_ROUTE_TARGET, value, true)
+}
+
+func parseAdminAssigned(subtype ExtendedCommunityAttrSubType, value string, isTransitive bool) (ExtendedCommunityInterface, error) {
+	i := strings.LastIndex(value, ":")
+	if i < 0 {
+		return nil, fmt.Errorf("invalid extended community format: %s", value)
+	}
+	admin, assigned := value[:i], value[i+1:]
+	if ip := net.ParseIP(admin).To4(); ip != nil {
+		v, err := strconv.ParseUint(assigned, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid extended community format: %s", value)
+		}
+		return NewIPv4AddressSpecificExtended(subtype, ip, uint16(v), isTransitive), nil
+	}
+	fourOctet := strings.HasSuffix(admin, "L")
+	as, err := strconv.ParseUint(strings.TrimSuffix(admin, "L"), 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid extended community format: %s", value)
+	}
+	v, err := strconv.ParseUint(assigned, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid extended community format: %s", value)
+	}
+	if fourOctet || as > math.MaxUint16 {
+		if v > math.MaxUint16 {
+			return nil, fmt.Errorf("invalid extended community format: %s", value)
+		}
+		return NewFourOctetAsSpecificExtended(subtype, uint32(as), uint16(v), isTransitive), nil
+	}
+	return NewTwoOctetAsSpecificExtended(subtype, uint16(as), uint32(v), isTransitive), nil
+}
+
+func parseLinkBandwidth(value, rest string) (ExtendedCommunityInterface, error) {
+	elems := strings.Split(rest, ":")
+	if len(elems) != 2 {
+		return nil, fmt.Errorf("invalid extended community format: %s", value)
+	}
+	as, err := strconv.ParseUint(elems[0], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid extended community format: %s", value)
+	}
+	bw, err := strconv.ParseFloat(elems[1], 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid extended community format: %s", value)
+	}
+	return NewLinkBandwidthExtended(uint16(as), float32(bw)), nil
+}
+
+func (p *PathAttributeExtendedCommunities) DecodeFromBytes(data []byte) error {
+	p.PathAttribute.DecodeFromBytes(data)
+
+	value := p.PathAttribute.Value
+	for len(value) >= 8 {
+		e := parseExtended(value)
+		p.Value = append(p.Value, e)
+		value = value[8:]
+	}
+	return nil
+}
+
+func extendedCommunitiesString(values []ExtendedCommunityInterface) string {
+	return strings.Join(extendedCommunitiesJSON(values), " ")
+}
+
+func extendedCommunitiesJSON(values []ExtendedCommunityInterface) []string {
+	s := make([]string, len(values))
+	for i, v := range values {
+		s[i] = v.String()
+	}
+	return s
+}
+
+func (p *PathAttributeExtendedCommunities) String() string {
+	return "EXTENDED_COMMUNITIES: " + extendedCommunitiesString(p.Value)
+}
+
+func (p *PathAttributeExtendedCommunities) MarshalJSON() ([]byte, error) {
+	return p.PathAttribute.marshalJSONRaw(extendedCommunitiesJSON(p.Value), p)
+}
+
+// HasExtendedCommunity reports whether the attribute carries a community
+// with the same encoding as c.
+func (p *PathAttributeExtendedCommunities) HasExtendedCommunity(c ExtendedCommunityInterface) bool {
+	want, err := c.Serialize()
+	if err != nil {
+		return false
+	}
+	for _, e := range p.Value {
+		b, err := e.Serialize()
+		if err == nil && bytes.Equal(b, want) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *PathAttributeExtendedCommunities) Serialize() ([]byte, error) {
+	buf := make([]byte, 0, 8*len(p.Value))
+	for _, e := range p.Value {
+		b, err := e.Serialize()
+		if err != nil {
+			return nil, err
+		}
+		if len(b) != 8 {
+			return nil, fmt.Errorf("%s does not fit in the EXTENDED_COMMUNITIES attribute", e)
+		}
+		buf = append(buf, b...)
+	}
+	return p.PathAttribute.serialize(buf)
+}
+
+// IPv6 Address Specific Extended Community attribute  RFC 5701
+const (
+	EC_TYPE_TRANSITIVE_IP6_SPECIFIC     ExtendedCommunityAttrType = 0x00
+	EC_TYPE_NON_TRANSITIVE_IP6_SPECIFIC                           = 0x40
+)
+
+// ip6ExtendedCommunitySubTypeNames holds the subtypes of the IPv6
+// address specific type, whose value is also that of the two-octet AS
+// specific type of the other attribute.
+var ip6ExtendedCommunitySubTypeNames = map[ExtendedCommunityAttrSubType]string{
+	EC_SUBTYPE_ROUTE_TARGET:     "RT",
+	EC_SUBTYPE_ROUTE_ORIGIN:     "SoO",
+	EC_SUBTYPE_VRF_ROUTE_IMPORT: "VRF-IMPORT",
+}
+
+func ip6ExtendedCommunitySubTypeName(subtype ExtendedCommunityAttrSubType) string {
+	if name, ok := ip6ExtendedCommunitySubTypeNames[subtype]; ok {
+		return name
+	}
+	return subtype.String()
+}
+
+type IPv6AddressSpecificExtended struct {
+	SubType      ExtendedCommunityAttrSubType
+	IPv6         net.IP
+	LocalAdmin   uint16
+	IsTransitive bool
+}
+
+func NewIPv6AddressSpecificExtended(subtype ExtendedCommunityAttrSubType, ip net.IP, localAdmin uint16, isTransitive bool) *IPv6AddressSpecificExtended {
+	return &IPv6AddressSpecificExtended{subtype, ip, localAdmin, isTransitive}
+}
+
+func (e *IPv6AddressSpecificExtended) Serialize() ([]byte, error) {
+	ip := e.IPv6.To16()
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IPv6 address in extended community: %s", e.IPv6)
+	}
+	buf := make([]byte, 20)
+	buf[0] = uint8(extendedCommunityType(EC_TYPE_TRANSITIVE_IP6_SPECIFIC, e.IsTransitive))
+	buf[1] = uint8(e.SubType)
+	copy(buf[2:18], ip)
+	binary.BigEndian.PutUint16(buf[18:20], e.LocalAdmin)
+	return buf, nil
+}
+
+func (e *IPv6AddressSpecificExtended) String() string {
+	return fmt.Sprintf("%s:%s:%d", ip6ExtendedCommunitySubTypeName(e.SubType), e.IPv6, e.LocalAdmin)
+}
+
+func (e *IPv6AddressSpecificExtended) GetTypes() (ExtendedCommunityAttrType, ExtendedCommunityAttrSubType) {
+	return extendedCommunityType(EC_TYPE_TRANSITIVE_IP6_SPECIFIC, e.IsTransitive), e.SubType
+}
+
+// ParseIPv6ExtendedCommunity parses the "<subtype>:<address>:<assigned>"
+// form produced by String, e.g. "RT:2001:db8::1:100". The community only
+// fits in the attribute of RFC 5701.
+func ParseIPv6ExtendedCommunity(value string) (*IPv6AddressSpecificExtended, error) {
+	elems := strings.SplitN(value, ":", 2)
+	if len(elems) != 2 {
+		return nil, fmt.Errorf("invalid extended community format: %s", value)
+	}
+	var subtype ExtendedCommunityAttrSubType
+	found := false
+	for t, name := range ip6ExtendedCommunitySubTypeNames {
+		if strings.EqualFold(elems[0], name) {
+			subtype = t
+			found = true
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("unknown extended community subtype: %s", elems[0])
+	}
+	i := strings.LastIndex(elems[1], ":")
+	if i < 0 {
+		return nil, fmt.Errorf("invalid extended community format: %s", value)
+	}
+	ip := net.ParseIP(elems[1][:i])
+	if ip == nil {
+		return nil, fmt.Errorf("invalid extended community format: %s", value)
+	}
+	v, err := strconv.ParseUint(elems[1][i+1:], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid extended community format: %s", value)
+	}
+	return NewIPv6AddressSpecificExtended(subtype, ip, uint16(v), true), nil
+}
+
+type PathAttributeIP6ExtendedCommunities struct {
+	PathAttribute
+	Value []ExtendedCommunityInterface
+}
+
+func NewPathAttributeIP6ExtendedCommunities(values []ExtendedCommunityInterface) *PathAttributeIP6ExtendedCommunities {
+	p := &PathAttributeIP6ExtendedCommunities{}
+	p.Flags = BGP_ATTR_FLAG_OPTIONAL | BGP_ATTR_FLAG_TRANSITIVE
+	p.Type = BGP_ATTR_TYPE_IP6_EXTENDED_COMMUNITIES
+	p.Value = values
+	p.PathAttribute.Length = uint16(20 * len(values))
+	return p
+}
+
+func (p *PathAttributeIP6ExtendedCommunities) DecodeFromBytes(data []byte) error {
+	p.PathAttribute.DecodeFromBytes(data)
+
+	value := p.PathAttribute.Value
+	if len(value)%20 != 0 {
+		return fmt.Errorf("IPv6 extended communities length %d is not a multiple of 20", len(value))
+	}
+	for len(value) >= 20 {
+		e := &IPv6AddressSpecificExtended{}
+		e.IsTransitive = value[0]&0x40 == 0
+		e.SubType = ExtendedCommunityAttrSubType(value[1])
+		e.IPv6 = value[2:18]
+		e.LocalAdmin = binary.BigEndian.Uint16(value[18:20])
+		p.Value = append(p.Value, e)
+		value = value[20:]
+	}
+	return nil
+}
+
+func (p *PathAttributeIP6ExtendedCommunities) String() string {
+	return "IP6_EXTENDED_COMMUNITIES: " + extendedCommunitiesString(p.Value)
+}
+
+func (p *PathAttributeIP6ExtendedCommunities) MarshalJSON() ([]byte, error) {
+	return p.PathAttribute.marshalJSONRaw(extendedCommunitiesJSON(p.Value), p)
+}
+
+func (p *PathAttributeIP6ExtendedCommunities) Serialize() ([]byte, error) {
+	buf := make([]byte, 0, 20*len(p.Value))
+	for _, e := range p.Value {
+		b, err := e.Serialize()
+		if err != nil {
+			return nil, err
+		}
+		if len(b) != 20 {
+			return nil, fmt.Errorf("%s is not an IPv6 address specific extended community", e)
+		}
+		buf = append(buf, b...)
+	}
+	return p.PathAttribute.serialize(buf)
+}
+
+type PathAttributeAs4Path struct {
+	PathAttribute
+	Value []AsPathParam
+	DefaultAsPath
+}
+
+func (p *PathAttributeAs4Path) DecodeFromBytes(data []byte) error {
+	p.PathAttribute.DecodeFromBytes(data)
+	if !p.DefaultAsPath.isValidAspath(p.PathAttribute.Value, 4) {
+		return fmt.Errorf("invalid AS4_PATH")
+	}
+	p.Value = p.DefaultAsPath.decodeAs4path(p.PathAttribute.Value)
+	return nil
+}
+
+func (p *PathAttributeAs4Path) String() string {
+	return "AS4_PATH: " + asPathString(p.Value)
+}
+
+func (p *PathAttributeAs4Path) MarshalJSON() ([]byte, error) {
+	return p.PathAttribute.marshalJSON(asPathJSON(p.Value))
+}
+
+type PathAttributeAs4Aggregator struct {
+	PathAttribute
+	Value PathAttributeAggregatorParam
+}
+
+func (p *PathAttributeAs4Aggregator) DecodeFromBytes(data []byte) error {
+	p.PathAttribute.DecodeFromBytes(data)
+
+	p.Value.AS = binary.BigEndian.Uint32(p.PathAttribute.Value[0:4])
+	p.Value.Address = p.PathAttribute.Value[4:]
+	return nil
+}
+
+func (p *PathAttributeAs4Aggregator) String() string {
+	return fmt.Sprintf("AS4_AGGREGATOR: %d %s", p.Value.AS, p.Value.Address)
+}
+
+func (p *PathAttributeAs4Aggregator) MarshalJSON() ([]byte, error) {
+	return p.PathAttribute.marshalJSON(aggregatorJSON{p.Value.AS, p.Value.Address.String()})
+}
+
+type LargeCommunity struct {
+	GlobalAdmin uint32
+	LocalData1  uint32
+	LocalData2  uint32
+}
+
+func (c *LargeCommunity) String() string {
+	return fmt.Sprintf("%d:%d:%d", c.GlobalAdmin, c.LocalData1, c.LocalData2)
+}
+
+// ParseLargeCommunity parses the "GlobalAdmin:LocalData1:LocalData2"
+// form used by RFC 8092 implementations, e.g. "65000:1:2".
+func ParseLargeCommunity(value string) (*LargeCommunity, error) {
+	elems := strings.Split(value, ":")
+	if len(elems) != 3 {
+		return nil, fmt.Errorf("invalid large community format: %s", value)
+	}
+	v := make([]uint32, 3)
+	for i, elem := range elems {
+		n, err := strconv.ParseUint(elem, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid large community format: %s", value)
+		}
+		v[i] = uint32(n)
+	}
+	return &LargeCommunity{v[0], v[1], v[2]}, nil
+}
+
+type PathAttributeLargeCommunities struct {
+	PathAttribute
+	Value []*LargeCommunity
+}
+
+func NewPathAttributeLargeCommunities(values []*LargeCommunity) *PathAttributeLargeCommunities {
+	p := &PathAttributeLargeCommunities{}
+	p.Flags = BGP_ATTR_FLAG_OPTIONAL | BGP_ATTR_FLAG_TRANSITIVE
+	p.Type = BGP_ATTR_TYPE_LARGE_COMMUNITY
+	p.Value = values
+	p.PathAttribute.Length = uint16(12 * len(values))
+	return p
+}
+
+func (p *PathAttributeLargeCommunities) DecodeFromBytes(data []byte) error {
+	p.PathAttribute.DecodeFromBytes(data)
+	value := p.PathAttribute.Value
+	if len(value)%12 != 0 {
+		return fmt.Errorf("large communities length %d is not a multiple of 12", len(value))
+	}
+	for len(value) >= 12 {
+		c := &LargeCommunity{}
+		c.GlobalAdmin = binary.BigEndian.Uint32(value[0:4])
+		c.LocalData1 = binary.BigEndian.Uint32(value[4:8])
+		c.LocalData2 = binary.BigEndian.Uint32(value[8:12])
+		p.Value = append(p.Value, c)
+		value = value[12:]
+	}
+	return nil
+}
+
+func (p *PathAttributeLargeCommunities) Serialize() ([]byte, error) {
+	buf := make([]byte, 12*len(p.Value))
+	for i, c := range p.Value {
+		binary.BigEndian.PutUint32(buf[12*i:], c.GlobalAdmin)
+		binary.BigEndian.PutUint32(buf[12*i+4:], c.LocalData1)
+		binary.BigEndian.PutUint32(buf[12*i+8:], c.LocalData2)
+	}
+	return p.PathAttribute.serialize(buf)
+}
+
+func (p *PathAttributeLargeCommunities) String() string {
+	s := make([]string, len(p.Value))
+	for i, v := range p.Value {
+		s[i] = v.String()
+	}
+	return "LARGE_COMMUNITY: " + strings.Join(s, " ")
+}
+
+func (p *PathAttributeLargeCommunities) MarshalJSON() ([]byte, error) {
+	s := make([]string, len(p.Value))
+	for i, c := range p.Value {
+		s[i] = c.String()
+	}
+	return p.PathAttribute.marshalJSON(s)
+}
+
+// HasLargeCommunity reports whether the attribute carries c, for use
+// when matching routes against a community list.
+func (p *PathAttributeLargeCommunities) HasLargeCommunity(c *LargeCommunity) bool {
+	for _, v := range p.Value {
+		if *v == *c {
+			return true
+		}
+	}
+	return false
+}
+
+// PMSI Tunnel attribute  RFC 6514 5
+const (
+	PMSI_TUNNEL_TYPE_NO_TUNNEL      = 0
+	PMSI_TUNNEL_TYPE_RSVP_TE_P2MP   = 1
+	PMSI_TUNNEL_TYPE_MLDP_P2MP      = 2
+	PMSI_TUNNEL_TYPE_PIM_SSM_TREE   = 3
+	PMSI_TUNNEL_TYPE_PIM_SM_TREE    = 4
+	PMSI_TUNNEL_TYPE_BIDIR_PIM_TREE = 5
+	PMSI_TUNNEL_TYPE_INGRESS_REPL   = 6
+	PMSI_TUNNEL_TYPE_MLDP_MP2MP     = 7
+)
+
+var pmsiTunnelTypeNames = map[uint8]string{
+	PMSI_TUNNEL_TYPE_NO_TUNNEL:      "no-tunnel",
+	PMSI_TUNNEL_TYPE_RSVP_TE_P2MP:   "rsvp-te-p2mp",
+	PMSI_TUNNEL_TYPE_MLDP_P2MP:      "mldp-p2mp",
+	PMSI_TUNNEL_TYPE_PIM_SSM_TREE:   "pim-ssm",
+	PMSI_TUNNEL_TYPE_PIM_SM_TREE:    "pim-sm",
+	PMSI_TUNNEL_TYPE_BIDIR_PIM_TREE: "bidir-pim",
+	PMSI_TUNNEL_TYPE_INGRESS_REPL:   "ingress-replication",
+	PMSI_TUNNEL_TYPE_MLDP_MP2MP:     "mldp-mp2mp",
+}
+
+const PMSI_TUNNEL_FLAG_LEAF_INFO_REQUIRED = 1 << 0
+
+type PMSITunnelIDInterface interface {
+	DecodeFromBytes([]byte) error
+	Serialize() ([]byte, error)
+	String() string
+}
+
+// RSVP-TE P2MP LSP identifier, the SESSION object of RFC 4875
+type RsvpTeP2MPTunnelID struct {
+	P2MPID           uint32
+	TunnelID         uint16
+	ExtendedTunnelID net.IP
+}
+
+func (t *RsvpTeP2MPTunnelID) DecodeFromBytes(data []byte) error {
+	if len(data) != 12 {
+		return fmt.Errorf("invalid RSVP-TE P2MP tunnel identifier length %d", len(data))
+	}
+	t.P2MPID = binary.BigEndian.Uint32(data[0:4])
+	t.TunnelID = binary.BigEndian.Uint16(data[6:8])
+	t.ExtendedTunnelID = net.IP(data[8:12])
+	return nil
+}
+
+func (t *RsvpTeP2MPTunnelID) Serialize() ([]byte, error) {
+	id := t.ExtendedTunnelID.To4()
+	if id == nil {
+		return nil, fmt.Errorf("invalid RSVP-TE extended tunnel ID %s", t.ExtendedTunnelID)
+	}
+	buf := make([]byte, 12)
+	binary.BigEndian.PutUint32(buf[0:4], t.P2MPID)
+	binary.BigEndian.PutUint16(buf[6:8], t.TunnelID)
+	copy(buf[8:12], id)
+	return buf, nil
+}
+
+func (t *RsvpTeP2MPTunnelID) String() string {
+	return fmt.Sprintf("[p2mp-id:%d][tunnel-id:%d][extended-tunnel-id:%s]", t.P2MPID, t.TunnelID, t.ExtendedTunnelID)
+}
+
+// mLDP P2MP or MP2MP FEC element  RFC 6388 2.2
+type MldpTunnelID struct {
+	FECType  uint8
+	RootNode net.IP
+	Opaque   []byte
+}
+
+func (t *MldpTunnelID) DecodeFromBytes(data []byte) error {
+	if len(data) < 4 {
+		return fmt.Errorf("Not all mLDP tunnel identifier bytes available")
+	}
+	t.FECType = data[0]
+	addrlen := int(data[3])
+	data = data[4:]
+	if addrlen != net.IPv4len && addrlen != net.IPv6len || len(data) < addrlen+2 {
+		return fmt.Errorf("invalid mLDP root node address length %d", addrlen)
+	}
+	t.RootNode = net.IP(data[:addrlen])
+	data = data[addrlen:]
+	oplen := int(binary.BigEndian.Uint16(data[0:2]))
+	if len(data) != 2+oplen {
+		return fmt.Errorf("invalid mLDP opaque value length %d", oplen)
+	}
+	t.Opaque = data[2:]
+	return nil
+}
+
+func (t *MldpTunnelID) Serialize() ([]byte, error) {
+	afi, root := uint16(AFI_IP), t.RootNode.To4()
+	if root == nil {
+		afi, root = AFI_IP6, t.RootNode.To16()
+	}
+	if root == nil {
+		return nil, fmt.Errorf("mLDP tunnel identifier has no root node")
+	}
+	buf := make([]byte, 4)
+	buf[0] = t.FECType
+	binary.BigEndian.PutUint16(buf[1:3], afi)
+	buf[3] = uint8(len(root))
+	buf = append(buf, root...)
+	obuf := make([]byte, 2)
+	binary.BigEndian.PutUint16(obuf, uint16(len(t.Opaque)))
+	buf = append(buf, obuf...)
+	return append(buf, t.Opaque...), nil
+}
+
+func (t *MldpTunnelID) String() string {
+	return fmt.Sprintf("[fec-type:%d][root:%s][opaque:%x]", t.FECType, t.RootNode, t.Opaque)
+}
+
+// PIM-SSM, PIM-SM and BIDIR-PIM tree identifier
+type PimTunnelID struct {
+	Sender net.IP
+	Group  net.IP
+}
+
+func (t *PimTunnelID) DecodeFromBytes(data []byte) error {
+	if len(data) != 2*net.IPv4len && len(data) != 2*net.IPv6len {
+		return fmt.Errorf("invalid PIM tunnel identifier length %d", len(data))
+	}
+	t.Sender = net.IP(data[:len(data)/2])
+	t.Group = net.IP(data[len(data)/2:])
+	return nil
+}
+
+func (t *PimTunnelID) Serialize() ([]byte, error) {
+	_, sender := evpnIPSerialize(t.Sender)
+	_, group := evpnIPSerialize(t.Group)
+	if sender == nil || len(sender) != len(group) {
+		return nil, fmt.Errorf("invalid PIM tunnel identifier %s %s", t.Sender, t.Group)
+	}
+	return append(append([]byte{}, sender...), group...), nil
+}
+
+func (t *PimTunnelID) String() string {
+	return fmt.Sprintf("[sender:%s][group:%s]", t.Sender, t.Group)
+}
+
+type IngressReplTunnelID struct {
+	Endpoint net.IP
+}
+
+func (t *IngressReplTunnelID) DecodeFromBytes(data []byte) error {
+	if len(data) != net.IPv4len && len(data) != net.IPv6len {
+		return fmt.Errorf("invalid ingress replication tunnel identifier length %d", len(data))
+	}
+	t.Endpoint = net.IP(data)
+	return nil
+}
+
+func (t *IngressReplTunnelID) Serialize() ([]byte, error) {
+	_, buf := evpnIPSerialize(t.Endpoint)
+	if buf == nil {
+		return nil, fmt.Errorf("ingress replication tunnel identifier has no endpoint")
+	}
+	return buf, nil
+}
+
+func (t *IngressReplTunnelID) String() string {
+	return fmt.Sprintf("[endpoint:%s]", t.Endpoint)
+}
+
+type UnknownTunnelID struct {
+	Value []byte
+}
+
+func (t *UnknownTunnelID) DecodeFromBytes(data []byte) error {
+	t.Value = data
+	return nil
+}
+
+func (t *UnknownTunnelID) Serialize() ([]byte, error) {
+	return t.Value, nil
+}
+
+func (t *UnknownTunnelID) String() string {
+	return fmt.Sprintf("[value:%x]", t.Value)
+}
+
+func getPMSITunnelID(t uint8) PMSITunnelIDInterface {
+	switch t {
+	case PMSI_TUNNEL_TYPE_RSVP_TE_P2MP:
+		return &RsvpTeP2MPTunnelID{}
+	case PMSI_TUNNEL_TYPE_MLDP_P2MP, PMSI_TUNNEL_TYPE_MLDP_MP2MP:
+		return &MldpTunnelID{}
+	case PMSI_TUNNEL_TYPE_PIM_SSM_TREE, PMSI_TUNNEL_TYPE_PIM_SM_TREE, PMSI_TUNNEL_TYPE_BIDIR_PIM_TREE:
+		return &PimTunnelID{}
+	case PMSI_TUNNEL_TYPE_INGRESS_REPL:
+		return &IngressReplTunnelID{}
+	}
+	return &UnknownTunnelID{}
+}
+
+// PathAttributePmsiTunnel keeps Label as the raw 24 bit field like the
+// EVPN routes do, since EVPN puts a VNI there for VXLAN tunnels.
+type PathAttributePmsiTunnel struct {
+	PathAttribute
+	TunnelFlags uint8
+	TunnelType  uint8
+	Label       uint32
+	TunnelID    PMSITunnelIDInterface
+}
+
+func NewPathAttributePmsiTunnel(t uint8, flags uint8, label uint32, id PMSITunnelIDInterface) *PathAttributePmsiTunnel {
+	p := &PathAttributePmsiTunnel{}
+	p.Flags = BGP_ATTR_FLAG_OPTIONAL | BGP_ATTR_FLAG_TRANSITIVE
+	p.Type = BGP_ATTR_TYPE_PMSI_TUNNEL
+	p.TunnelFlags = flags
+	p.TunnelType = t
+	p.Label = label
+	p.TunnelID = id
+	return p
+}
+
+func (p *PathAttributePmsiTunnel) DecodeFromBytes(data []byte) error {
+	p.PathAttribute.DecodeFromBytes(data)
+	value := p.PathAttribute.Value
+	if len(value) < 5 {
+		return fmt.Errorf("Not all PMSI tunnel attribute bytes available")
+	}
+	p.TunnelFlags = value[0]
+	p.TunnelType = value[1]
+	p.Label = evpnLabelDecode(value[2:5])
+	p.TunnelID = getPMSITunnelID(p.TunnelType)
+	return p.TunnelID.DecodeFromBytes(value[5:])
+}
+
+func (p *PathAttributePmsiTunnel) Serialize() ([]byte, error) {
+	buf := []byte{p.TunnelFlags, p.TunnelType}
+	buf = append(buf, evpnLabelSerialize(p.Label)...)
+	if p.TunnelID != nil {
+		ibuf, err := p.TunnelID.Serialize()
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, ibuf...)
+	}
+	return p.PathAttribute.serialize(buf)
+}
+
+func (p *PathAttributePmsiTunnel) String() string {
+	name, ok := pmsiTunnelTypeNames[p.TunnelType]
+	if !ok {
+		name = strconv.Itoa(int(p.TunnelType))
+	}
+	buf := bytes.NewBuffer(nil)
+	buf.WriteString(fmt.Sprintf("PMSI_TUNNEL: [type:%s][label:%d]", name, p.Label))
+	if p.TunnelFlags&PMSI_TUNNEL_FLAG_LEAF_INFO_REQUIRED != 0 {
+		buf.WriteString("[leaf-info-required]")
+	}
+	if p.TunnelID != nil {
+		buf.WriteString(p.TunnelID.String())
+	}
+	return buf.String()
+}
+
+func (p *PathAttributePmsiTunnel) MarshalJSON() ([]byte, error) {
+	return p.PathAttribute.marshalJSONRaw(strings.TrimPrefix(p.String(), "PMSI_TUNNEL: "), p)
+}
+
+// Tunnel Encapsulation attribute  RFC 9012 with the SR Policy sub-TLVs
+// of RFC 9830 2.4
+const (
+	TUNNEL_ENCAP_SUB_TLV_ENCAPSULATION     = 1
+	TUNNEL_ENCAP_SUB_TLV_PROTOCOL          = 2
+	TUNNEL_ENCAP_SUB_TLV_COLOR             = 4
+	TUNNEL_ENCAP_SUB_TLV_EGRESS_ENDPOINT   = 6
+	TUNNEL_ENCAP_SUB_TLV_UDP_DEST_PORT     = 8
+	TUNNEL_ENCAP_SUB_TLV_SR_PREFERENCE     = 12
+	TUNNEL_ENCAP_SUB_TLV_SR_BINDING_SID    = 13
+	TUNNEL_ENCAP_SUB_TLV_SR_ENLP           = 14
+	TUNNEL_ENCAP_SUB_TLV_SR_PRIORITY       = 15
+	TUNNEL_ENCAP_SUB_TLV_SR_SEGMENT_LIST   = 128
+	TUNNEL_ENCAP_SUB_TLV_SR_CANDIDATE_PATH = 129
+	TUNNEL_ENCAP_SUB_TLV_SR_POLICY_NAME    = 130
+)
+
+// sub-TLVs of a segment list  RFC 9830 2.4.4
+const (
+	TUNNEL_ENCAP_SUB_TLV_SR_SEGMENT_TYPE_A = 1
+	TUNNEL_ENCAP_SUB_TLV_SR_SEGMENT_TYPE_C = 3
+	TUNNEL_ENCAP_SUB_TLV_SR_SEGMENT_TYPE_D = 4
+	TUNNEL_ENCAP_SUB_TLV_SR_SEGMENT_TYPE_E = 5
+	TUNNEL_ENCAP_SUB_TLV_SR_SEGMENT_TYPE_F = 6
+	TUNNEL_ENCAP_SUB_TLV_SR_SEGMENT_TYPE_G = 7
+	TUNNEL_ENCAP_SUB_TLV_SR_SEGMENT_TYPE_H = 8
+	TUNNEL_ENCAP_SUB_TLV_SR_WEIGHT         = 9
+	TUNNEL_ENCAP_SUB_TLV_SR_SEGMENT_TYPE_B = 13
+	TUNNEL_ENCAP_SUB_TLV_SR_SEGMENT_TYPE_I = 14
+	TUNNEL_ENCAP_SUB_TLV_SR_SEGMENT_TYPE_J = 15
+	TUNNEL_ENCAP_SUB_TLV_SR_SEGMENT_TYPE_K = 16
+)
+
+const (
+	SR_BINDING_SID_FLAG_SPECIFIED_ONLY    = 1 << 7
+	SR_BINDING_SID_FLAG_DROP_UPON_INVALID = 1 << 6
+)
+
+// Segment flags  RFC 9830 2.4.4.2.12
+const (
+	SR_SEGMENT_FLAG_VERIFICATION = 1 << 7
+	SR_SEGMENT_FLAG_ALGORITHM    = 1 << 6
+	SR_SEGMENT_FLAG_BEHAVIOR     = 1 << 4
+)
+
+type TunnelEncapSubTLVInterface interface {
+	DecodeFromBytes([]byte) error
+	Serialize() ([]byte, error)
+	String() string
+}
+
+type TunnelEncapSubTLVUnknown struct {
+	Value []byte
+}
+
+func (t *TunnelEncapSubTLVUnknown) DecodeFromBytes(data []byte) error {
+	t.Value = data
+	return nil
+}
+
+func (t *TunnelEncapSubTLVUnknown) Serialize() ([]byte, error) {
+	return t.Value, nil
+}
+
+func (t *TunnelEncapSubTLVUnknown) String() string {
+	return fmt.Sprintf("[value:%x]", t.Value)
+}
+
+type TunnelEncapSubTLVSRPreference struct {
+	Flags      uint8
+	Preference uint32
+}
+
+func (t *TunnelEncapSubTLVSRPreference) DecodeFromBytes(data []byte) error {
+	if len(data) != 6 {
+		return fmt.Errorf("invalid SR preference sub-TLV length %d", len(data))
+	}
+	t.Flags = data[0]
+	t.Preference = binary.BigEndian.Uint32(data[2:6])
+	return nil
+}
+
+func (t *TunnelEncapSubTLVSRPreference) Serialize() ([]byte, error) {
+	buf := make([]byte, 6)
+	buf[0] = t.Flags
+	binary.BigEndian.PutUint32(buf[2:6], t.Preference)
+	return buf, nil
+}
+
+func (t *TunnelEncapSubTLVSRPreference) String() string {
+	return fmt.Sprintf("[preference:%d]", t.Preference)
+}
+
+// TunnelEncapSubTLVSRBindingSID carries no SID, an MPLS label stack
+// entry or an SRv6 SID.
+type TunnelEncapSubTLVSRBindingSID struct {
+	Flags   uint8
+	Label   *uint32
+	SRv6SID net.IP
+}
+
+func (t *TunnelEncapSubTLVSRBindingSID) DecodeFromBytes(data []byte) error {
+	if len(data) < 2 {
+		return fmt.Errorf("Not all SR binding SID sub-TLV bytes available")
+	}
+	t.Flags = data[0]
+	data = data[2:]
+	switch len(data) {
+	case 0:
+	case 4:
+		v := binary.BigEndian.Uint32(data)
+		t.Label = &v
+	case 16:
+		t.SRv6SID = net.IP(data)
+	default:
+		return fmt.Errorf("invalid SR binding SID length %d", len(data))
+	}
+	return nil
+}
+
+func (t *TunnelEncapSubTLVSRBindingSID) Serialize() ([]byte, error) {
+	buf := []byte{t.Flags, 0}
+	switch {
+	case t.Label != nil && t.SRv6SID != nil:
+		return nil, fmt.Errorf("SR binding SID is both an MPLS label and an SRv6 SID")
+	case t.Label != nil:
+		lbuf := make([]byte, 4)
+		binary.BigEndian.PutUint32(lbuf, *t.Label)
+		buf = append(buf, lbuf...)
+	case t.SRv6SID != nil:
+		buf = append(buf, t.SRv6SID.To16()...)
+	}
+	return buf, nil
+}
+
+func (t *TunnelEncapSubTLVSRBindingSID) String() string {
+	switch {
+	case t.Label != nil:
+		return fmt.Sprintf("[binding-sid:%d]", *t.Label>>12)
+	case t.SRv6SID != nil:
+		return fmt.Sprintf("[binding-sid:%s]", t.SRv6SID)
+	}
+	return "[binding-sid:none]"
+}
+
+type TunnelEncapSubTLVSRENLP struct {
+	Flags uint8
+	ENLP  uint8
+}
+
+func (t *TunnelEncapSubTLVSRENLP) DecodeFromBytes(data []byte) error {
+	if len(data) != 3 {
+		return fmt.Errorf("invalid SR ENLP sub-TLV length %d", len(data))
+	}
+	t.Flags = data[0]
+	t.ENLP = data[2]
+	return nil
+}
+
+func (t *TunnelEncapSubTLVSRENLP) Serialize() ([]byte, error) {
+	return []byte{t.Flags, 0, t.ENLP}, nil
+}
+
+func (t *TunnelEncapSubTLVSRENLP) String() string {
+	return fmt.Sprintf("[enlp:%d]", t.ENLP)
+}
+
+type TunnelEncapSubTLVSRPriority struct {
+	Priority uint8
+}
+
+func (t *TunnelEncapSubTLVSRPriority) DecodeFromBytes(data []byte) error {
+	if len(data) != 2 {
+		return fmt.Errorf("invalid SR priority sub-TLV length %d", len(data))
+	}
+	t.Priority = data[0]
+	return nil
+}
+
+func (t *TunnelEncapSubTLVSRPriority) Serialize() ([]byte, error) {
+	return []byte{t.Priority, 0}, nil
+}
+
+func (t *TunnelEncapSubTLVSRPriority) String() string {
+	return fmt.Sprintf("[priority:%d]", t.Priority)
+}
+
+// TunnelEncapSubTLVSRName is the SR Policy Name and the Candidate Path
+// Name sub-TLV.
+type TunnelEncapSubTLVSRName struct {
+	Name string
+}
+
+func (t *TunnelEncapSubTLVSRName) DecodeFromBytes(data []byte) error {
+	if len(data) < 1 {
+		return fmt.Errorf("Not all SR name sub-TLV bytes available")
+	}
+	t.Name = string(data[1:])
+	return nil
+}
+
+func (t *TunnelEncapSubTLVSRName) Serialize() ([]byte, error) {
+	return append([]byte{0}, t.Name...), nil
+}
+
+func (t *TunnelEncapSubTLVSRName) String() string {
+	return fmt.Sprintf("[name:%s]", t.Name)
+}
+
+// SRv6EndpointBehavior is the SRv6 Endpoint Behavior and SID Structure
+// of segment types B, I, J and K  RFC 9830 2.4.4.2.4
+type SRv6EndpointBehavior struct {
+	Behavior       uint16
+	BlockLength    uint8
+	NodeLength     uint8
+	FunctionLength uint8
+	ArgumentLength uint8
+}
+
+type srSegmentLayout struct {
+	algorithm  bool
+	localIfID  bool
+	localLen   int
+	remoteIfID bool
+	remoteLen  int
+	mpls       bool
+	srv6       bool
+}
+
+var srSegmentLayouts = map[uint8]srSegmentLayout{
+	TUNNEL_ENCAP_SUB_TLV_SR_SEGMENT_TYPE_A: {mpls: true},
+	TUNNEL_ENCAP_SUB_TLV_SR_SEGMENT_TYPE_B: {srv6: true},
+	TUNNEL_ENCAP_SUB_TLV_SR_SEGMENT_TYPE_C: {algorithm: true, localLen: 4, mpls: true},
+	TUNNEL_ENCAP_SUB_TLV_SR_SEGMENT_TYPE_D: {algorithm: true, localLen: 16, mpls: true},
+	TUNNEL_ENCAP_SUB_TLV_SR_SEGMENT_TYPE_E: {localIfID: true, localLen: 4, mpls: true},
+	TUNNEL_ENCAP_SUB_TLV_SR_SEGMENT_TYPE_F: {localLen: 4, remoteLen: 4, mpls: true},
+	TUNNEL_ENCAP_SUB_TLV_SR_SEGMENT_TYPE_G: {localIfID: true, localLen: 16, remoteIfID: true, remoteLen: 16, mpls: true},
+	TUNNEL_ENCAP_SUB_TLV_SR_SEGMENT_TYPE_H: {localLen: 16, remoteLen: 16, mpls: true},
+	TUNNEL_ENCAP_SUB_TLV_SR_SEGMENT_TYPE_I: {algorithm: true, localLen: 16, srv6: true},
+	TUNNEL_ENCAP_SUB_TLV_SR_SEGMENT_TYPE_J: {algorithm: true, localIfID: true, localLen: 16, remoteIfID: true, remoteLen: 16, srv6: true},
+	TUNNEL_ENCAP_SUB_TLV_SR_SEGMENT_TYPE_K: {algorithm: true, localLen: 16, remoteLen: 16, srv6: true},
+}
+
+// SRSegment is a segment of types A to K. Which fields are used depends
+// on Type: LocalAddr is the node address of types C, D, E and I. SID is
+// a 32 bit MPLS label stack entry, mandatory for type A and optional for
+// types C to H, SRv6SID is mandatory for type B and optional for types
+// I, J and K. Segments of unknown types keep their encoding in Value.
+type SRSegment struct {
+	Type              uint8
+	Flags             uint8
+	Algorithm         uint8
+	LocalInterfaceID  uint32
+	LocalAddr         net.IP
+	RemoteInterfaceID uint32
+	RemoteAddr        net.IP
+	SID               *uint32
+	SRv6SID           net.IP
+	Behavior          *SRv6EndpointBehavior
+	Value             []byte
+}
+
+// NewSRSegmentTypeA returns an SR-MPLS segment for label.
+func NewSRSegmentTypeA(flags uint8, label uint32) *SRSegment {
+	sid := label << 12
+	return &SRSegment{Type: TUNNEL_ENCAP_SUB_TLV_SR_SEGMENT_TYPE_A, Flags: flags, SID: &sid}
+}
+
+// NewSRSegmentTypeB returns an SRv6 segment for sid.
+func NewSRSegmentTypeB(flags uint8, sid net.IP, behavior *SRv6EndpointBehavior) *SRSegment {
+	if behavior != nil {
+		flags |= SR_SEGMENT_FLAG_BEHAVIOR
+	}
+	return &SRSegment{Type: TUNNEL_ENCAP_SUB_TLV_SR_SEGMENT_TYPE_B, Flags: flags, SRv6SID: sid, Behavior: behavior}
+}
+
+// IsSRv6 reports whether the segment belongs to an SRv6 segment list.
+func (s *SRSegment) IsSRv6() bool {
+	return srSegmentLayouts[s.Type].srv6
+}
+
+func (s *SRSegment) DecodeFromBytes(data []byte) error {
+	layout, ok := srSegmentLayouts[s.Type]
+	if !ok {
+		s.Value = data
+		return nil
+	}
+	size := 2
+	if layout.localIfID {
+		size += 4
+	}
+	size += layout.localLen
+	if layout.remoteIfID {
+		size += 4
+	}
+	size += layout.remoteLen
+	if len(data) < size {
+		return fmt.Errorf("Not all SR segment type %d bytes available", s.Type)
+	}
+	s.Flags = data[0]
+	if layout.algorithm {
+		s.Algorithm = data[1]
+	}
+	data = data[2:]
+	if layout.localIfID {
+		s.LocalInterfaceID = binary.BigEndian.Uint32(data[0:4])
+		data = data[4:]
+	}
+	if layout.localLen > 0 {
+		s.LocalAddr = net.IP(data[:layout.localLen])
+		data = data[layout.localLen:]
+	}
+	if layout.remoteIfID {
+		s.RemoteInterfaceID = binary.BigEndian.Uint32(data[0:4])
+		data = data[4:]
+	}
+	if layout.remoteLen > 0 {
+		s.RemoteAddr = net.IP(data[:layout.remoteLen])
+		data = data[layout.remoteLen:]
+	}
+	switch {
+	case layout.mpls && len(data) == 4:
+		v := binary.BigEndian.Uint32(data)
+		s.SID = &v
+	case layout.srv6 && (len(data) == 16 || len(data) == 24):
+		s.SRv6SID = net.IP(data[:16])
+		if len(data) == 24 {
+			s.Behavior = &SRv6EndpointBehavior{
+				Behavior:       binary.BigEndian.Uint16(data[16:18]),
+				BlockLength:    data[20],
+				NodeLength:     data[21],
+				FunctionLength: data[22],
+				ArgumentLength: data[23],
+			}
+		}
+	case len(data) == 0:
+	default:
+		return fmt.Errorf("invalid SR segment type %d length %d", s.Type, size+len(data))
+	}
+	if s.Type == TUNNEL_ENCAP_SUB_TLV_SR_SEGMENT_TYPE_A && s.SID == nil ||
+		s.Type == TUNNEL_ENCAP_SUB_TLV_SR_SEGMENT_TYPE_B && s.SRv6SID == nil {
+		return fmt.Errorf("SR segment type %d has no SID", s.Type)
+	}
+	return nil
+}
+
+func (s *SRSegment) Serialize() ([]byte, error) {
+	layout, ok := srSegmentLayouts[s.Type]
+	if !ok {
+		return s.Value, nil
+	}
+	buf := []byte{s.Flags, 0}
+	if layout.algorithm {
+		buf[1] = s.Algorithm
+	}
+	addr := func(ip net.IP, size int) error {
+		v := ip.To16()
+		if size == net.IPv4len {
+			v = ip.To4()
+		}
+		if v == nil {
+			return fmt.Errorf("invalid address %s in SR segment type %d", ip, s.Type)
+		}
+		buf = append(buf, v...)
+		return nil
+	}
+	ifid := func(id uint32) {
+		ibuf := make([]byte, 4)
+		binary.BigEndian.PutUint32(ibuf, id)
+		buf = append(buf, ibuf...)
+	}
+	if layout.localIfID {
+		ifid(s.LocalInterfaceID)
+	}
+	if layout.localLen > 0 {
+		if err := addr(s.LocalAddr, layout.localLen); err != nil {
+			return nil, err
+		}
+	}
+	if layout.remoteIfID {
+		ifid(s.RemoteInterfaceID)
+	}
+	if layout.remoteLen > 0 {
+		if err := addr(s.RemoteAddr, layout.remoteLen); err != nil {
+			return nil, err
+		}
+	}
+	if layout.mpls && s.SID != nil {
+		ifid(*s.SID)
+	}
+	if layout.srv6 && s.SRv6SID != nil {
+		if err := addr(s.SRv6SID, net.IPv6len); err != nil {
+			return nil, err
+		}
+		if b := s.Behavior; b != nil {
+			bbuf := make([]byte, 8)
+			binary.BigEndian.PutUint16(bbuf[0:2], b.Behavior)
+			bbuf[4] = b.BlockLength
+			bbuf[5] = b.NodeLength
+			bbuf[6] = b.FunctionLength
+			bbuf[7] = b.ArgumentLength
+			buf = append(buf, bbuf...)
+		}
+	}
+	return buf, nil
+}
+
+func (s *SRSegment) String() string {
+	buf := bytes.NewBuffer(nil)
+	buf.WriteString(fmt.Sprintf("[type:%d]", s.Type))
+	if _, ok := srSegmentLayouts[s.Type]; !ok {
+		buf.WriteString(fmt.Sprintf("[value:%x]", s.Value))
+		return buf.String()
+	}
+	if s.LocalAddr != nil {
+		buf.WriteString(fmt.Sprintf("[local:%s]", s.LocalAddr))
+	}
+	if s.RemoteAddr != nil {
+		buf.WriteString(fmt.Sprintf("[remote:%s]", s.RemoteAddr))
+	}
+	if s.SID != nil {
+		buf.WriteString(fmt.Sprintf("[label:%d]", *s.SID>>12))
+	}
+	if s.SRv6SID != nil {
+		buf.WriteString(fmt.Sprintf("[sid:%s]", s.SRv6SID))
+	}
+	if s.Behavior != nil {
+		buf.WriteString(fmt.Sprintf("[behavior:%d]", s.Behavior.Behavior))
+	}
+	return buf.String()
+}
+
+type SRWeight struct {
+	Flags  uint8
+	Weight uint32
+}
+
+type TunnelEncapSubTLVSRSegmentList struct {
+	Weight   *SRWeight
+	Segments []*SRSegment
+}
+
+func (t *TunnelEncapSubTLVSRSegmentList) DecodeFromBytes(data []byte) error {
+	if len(data) < 1 {
+		return fmt.Errorf("Not all SR segment list bytes available")
+	}
+	data = data[1:]
+	for len(data) > 0 {
+		if len(data) < 2 || len(data) < 2+int(data[1]) {
+			return fmt.Errorf("Not all SR segment list bytes available")
+		}
+		typ, value := data[0], data[2:2+data[1]]
+		data = data[2+len(value):]
+		if typ == TUNNEL_ENCAP_SUB_TLV_SR_WEIGHT {
+			if len(value) != 6 {
+				return fmt.Errorf("invalid SR weight sub-TLV length %d", len(value))
+			}
+			t.Weight = &SRWeight{value[0], binary.BigEndian.Uint32(value[2:6])}
+			continue
+		}
+		s := &SRSegment{Type: typ}
+		if err := s.DecodeFromBytes(value); err != nil {
+			return err
+		}
+		t.Segments = append(t.Segments, s)
+	}
+	return nil
+}
+
+func (t *TunnelEncapSubTLVSRSegmentList) Serialize() ([]byte, error) {
+	buf := []byte{0}
+	if t.Weight != nil {
+		wbuf := []byte{TUNNEL_ENCAP_SUB_TLV_SR_WEIGHT, 6, t.Weight.Flags, 0, 0, 0, 0, 0}
+		binary.BigEndian.PutUint32(wbuf[4:8], t.Weight.Weight)
+		buf = append(buf, wbuf...)
+	}
+	for _, s := range t.Segments {
+		sbuf, err := s.Serialize()
+		if err != nil {
+			return nil, err
+		}
+		if len(sbuf) > math.MaxUint8 {
+			return nil, fmt.Errorf("SR segment type %d is too long", s.Type)
+		}
+		buf = append(buf, s.Type, uint8(len(sbuf)))
+		buf = append(buf, sbuf...)
+	}
+	return buf, nil
+}
+
+func (t *TunnelEncapSubTLVSRSegmentList) String() string {
+	buf := bytes.NewBuffer(nil)
+	buf.WriteString("[segment-list:")
+	if t.Weight != nil {
+		buf.WriteString(fmt.Sprintf("[weight:%d]", t.Weight.Weight))
+	}
+	for _, s := range t.Segments {
+		buf.WriteString(s.String())
+	}
+	buf.WriteString("]")
+	return buf.String()
+}
+
+func getTunnelEncapSubTLV(t uint8) TunnelEncapSubTLVInterface {
+	switch t {
+	case TUNNEL_ENCAP_SUB_TLV_SR_PREFERENCE:
+		return &TunnelEncapSubTLVSRPreference{}
+	case TUNNEL_ENCAP_SUB_TLV_SR_BINDING_SID:
+		return &TunnelEncapSubTLVSRBindingSID{}
+	case TUNNEL_ENCAP_SUB_TLV_SR_ENLP:
+		return &TunnelEncapSubTLVSRENLP{}
+	case TUNNEL_ENCAP_SUB_TLV_SR_PRIORITY:
+		return &TunnelEncapSubTLVSRPriority{}
+	case TUNNEL_ENCAP_SUB_TLV_SR_SEGMENT_LIST:
+		return &TunnelEncapSubTLVSRSegmentList{}
+	case TUNNEL_ENCAP_SUB_TLV_SR_POLICY_NAME, TUNNEL_ENCAP_SUB_TLV_SR_CANDIDATE_PATH:
+		return &TunnelEncapSubTLVSRName{}
+	}
+	return &TunnelEncapSubTLVUnknown{}
+}
+
+// TunnelEncapSubTLV has a one octet length for types below 128 and a
+// two octet length for the others.
+type TunnelEncapSubTLV struct {
+	Type   uint8
+	Length uint16
+	Value  TunnelEncapSubTLVInterface
+}
+
+func NewTunnelEncapSubTLV(t uint8, value TunnelEncapSubTLVInterface) *TunnelEncapSubTLV {
+	return &TunnelEncapSubTLV{Type: t, Value: value}
+}
+
+func (t *TunnelEncapSubTLV) headerLen() int {
+	if t.Type >= 128 {
+		return 3
+	}
+	return 2
+}
+
+func (t *TunnelEncapSubTLV) DecodeFromBytes(data []byte) error {
+	if len(data) < 2 {
+		return fmt.Errorf("Not all tunnel encapsulation sub-TLV bytes available")
+	}
+	t.Type = data[0]
+	if t.headerLen() == 3 {
+		if len(data) < 3 {
+			return fmt.Errorf("Not all tunnel encapsulation sub-TLV bytes available")
+		}
+		t.Length = binary.BigEndian.Uint16(data[1:3])
+	} else {
+		t.Length = uint16(data[1])
+	}
+	data = data[t.headerLen():]
+	if len(data) < int(t.Length) {
+		return fmt.Errorf("Not all tunnel encapsulation sub-TLV %d bytes available", t.Type)
+	}
+	t.Value = getTunnelEncapSubTLV(t.Type)
+	return t.Value.DecodeFromBytes(data[:t.Length])
+}
+
+func (t *TunnelEncapSubTLV) Serialize() ([]byte, error) {
+	if t.Value == nil {
+		return nil, fmt.Errorf("tunnel encapsulation sub-TLV %d has no value", t.Type)
+	}
+	value, err := t.Value.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	var buf []byte
+	if t.headerLen() == 3 {
+		if len(value) > math.MaxUint16 {
+			return nil, fmt.Errorf("tunnel encapsulation sub-TLV %d is too long", t.Type)
+		}
+		buf = make([]byte, 3)
+		binary.BigEndian.PutUint16(buf[1:3], uint16(len(value)))
+	} else {
+		if len(value) > math.MaxUint8 {
+			return nil, fmt.Errorf("tunnel encapsulation sub-TLV %d is too long", t.Type)
+		}
+		buf = make([]byte, 2)
+		buf[1] = uint8(len(value))
+	}
+	buf[0] = t.Type
+	t.Length = uint16(len(value))
+	return append(buf, value...), nil
+}
+
+func (t *TunnelEncapSubTLV) Len() int {
+	return t.headerLen() + int(t.Length)
+}
+
+func (t *TunnelEncapSubTLV) String() string {
+	if t.Value == nil {
+		return fmt.Sprintf("[sub-tlv:%d]", t.Type)
+	}
+	if _, ok := t.Value.(*TunnelEncapSubTLVUnknown); ok {
+		return fmt.Sprintf("[sub-tlv:%d]%s", t.Type, t.Value)
+	}
+	return t.Value.String()
+}
+
+type TunnelEncapTLV struct {
+	Type   TunnelType
+	Length uint16
+	Value  []*TunnelEncapSubTLV
+}
+
+func NewTunnelEncapTLV(t TunnelType, value []*TunnelEncapSubTLV) *TunnelEncapTLV {
+	return &TunnelEncapTLV{Type: t, Value: value}
+}
+
+func (t *TunnelEncapTLV) DecodeFromBytes(data []byte) error {
+	if len(data) < 4 {
+		return fmt.Errorf("Not all tunnel encapsulation TLV bytes available")
+	}
+	t.Type = TunnelType(binary.BigEndian.Uint16(data[0:2]))
+	t.Length = binary.BigEndian.Uint16(data[2:4])
+	data = data[4:]
+	if len(data) < int(t.Length) {
+		return fmt.Errorf("Not all tunnel encapsulation TLV bytes available")
+	}
+	data = data[:t.Length]
+	for len(data) > 0 {
+		s := &TunnelEncapSubTLV{}
+		if err := s.DecodeFromBytes(data); err != nil {
+			return err
+		}
+		t.Value = append(t.Value, s)
+		data = data[s.Len():]
+	}
+	return nil
+}
+
+func (t *TunnelEncapTLV) Serialize() ([]byte, error) {
+	buf := make([]byte, 4)
+	for _, s := range t.Value {
+		sbuf, err := s.Serialize()
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, sbuf...)
+	}
+	if len(buf)-4 > math.MaxUint16 {
+		return nil, fmt.Errorf("tunnel encapsulation TLV is too long")
+	}
+	t.Length = uint16(len(buf) - 4)
+	binary.BigEndian.PutUint16(buf[0:2], uint16(t.Type))
+	binary.BigEndian.PutUint16(buf[2:4], t.Length)
+	return buf, nil
+}
+
+func (t *TunnelEncapTLV) Len() int {
+	return 4 + int(t.Length)
+}
+
+func (t *TunnelEncapTLV) String() string {
+	buf := bytes.NewBuffer(nil)
+	buf.WriteString(fmt.Sprintf("[type:%s]", t.Type))
+	for _, s := range t.Value {
+		buf.WriteString(s.String())
+	}
+	return buf.String()
+}
+
+// ValidateSRPolicy checks the rules of RFC 9830 4.2.1 a head-end applies
+// before installing a candidate path from t.
+func (t *TunnelEncapTLV) ValidateSRPolicy() error {
+	if t.Type != TUNNEL_TYPE_SR_POLICY {
+		return fmt.Errorf("tunnel type %s is not an SR Policy", t.Type)
+	}
+	seen := make(map[uint8]bool)
+	lists := 0
+	for _, s := range t.Value {
+		switch s.Type {
+		case TUNNEL_ENCAP_SUB_TLV_SR_SEGMENT_LIST:
+			l, ok := s.Value.(*TunnelEncapSubTLVSRSegmentList)
+			if !ok {
+				return fmt.Errorf("malformed SR segment list")
+			}
+			if len(l.Segments) == 0 {
+				return fmt.Errorf("SR segment list has no segments")
+			}
+			for _, seg := range l.Segments {
+				if _, known := srSegmentLayouts[seg.Type]; !known {
+					return fmt.Errorf("unknown SR segment type %d", seg.Type)
+				}
+				if seg.IsSRv6() != l.Segments[0].IsSRv6() {
+					return fmt.Errorf("SR segment list mixes SR-MPLS and SRv6 segments")
+				}
+			}
+			lists++
+		case TUNNEL_ENCAP_SUB_TLV_SR_PREFERENCE, TUNNEL_ENCAP_SUB_TLV_SR_BINDING_SID,
+			TUNNEL_ENCAP_SUB_TLV_SR_ENLP, TUNNEL_ENCAP_SUB_TLV_SR_PRIORITY,
+			TUNNEL_ENCAP_SUB_TLV_SR_POLICY_NAME, TUNNEL_ENCAP_SUB_TLV_SR_CANDIDATE_PATH:
+			if seen[s.Type] {
+				return fmt.Errorf("SR Policy has more than one sub-TLV %d", s.Type)
+			}
+			seen[s.Type] = true
+			if e, ok := s.Value.(*TunnelEncapSubTLVSRENLP); ok && (e.ENLP < 1 || e.ENLP > 4) {
+				return fmt.Errorf("invalid SR ENLP value %d", e.ENLP)
+			}
+		}
+	}
+	if lists == 0 {
+		return fmt.Errorf("SR Policy has no segment list")
+	}
+	return nil
+}
+
+type PathAttributeTunnelEncap struct {
+	PathAttribute
+	Value []*TunnelEncapTLV
+}
+
+func NewPathAttributeTunnelEncap(value []*TunnelEncapTLV) *PathAttributeTunnelEncap {
+	p := &PathAttributeTunnelEncap{}
+	p.Flags = BGP_ATTR_FLAG_OPTIONAL | BGP_ATTR_FLAG_TRANSITIVE
+	p.Type = BGP_ATTR_TYPE_TUNNEL_ENCAP
+	p.Value = value
+	return p
+}
+
+func (p *PathAttributeTunnelEncap) DecodeFromBytes(data []byte) error {
+	p.PathAttribute.DecodeFromBytes(data)
+	value := p.PathAttribute.Value
+	for len(value) > 0 {
+		t := &TunnelEncapTLV{}
+		if err := t.DecodeFromBytes(value); err != nil {
+			return err
+		}
+		p.Value = append(p.Value, t)
+		value = value[t.Len():]
+	}
+	return nil
+}
+
+func (p *PathAttributeTunnelEncap) Serialize() ([]byte, error) {
+	var buf []byte
+	for _, t := range p.Value {
+		tbuf, err := t.Serialize()
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, tbuf...)
+	}
+	return p.PathAttribute.serialize(buf)
+}
+
+func (p *PathAttributeTunnelEncap) String() string {
+	s := make([]string, len(p.Value))
+	for i, t := range p.Value {
+		s[i] = t.String()
+	}
+	return "TUNNEL_ENCAP: " + strings.Join(s, " ")
+}
+
+func (p *PathAttributeTunnelEncap) MarshalJSON() ([]byte, error) {
+	return p.PathAttribute.marshalJSONRaw(strings.TrimPrefix(p.String(), "TUNNEL_ENCAP: "), p)
+}
+
+// LsSID is a segment identifier carried either as a 20 bit label in
+// three octets or as a four octet index.
+type LsSID struct {
+	Value   uint32
+	IsLabel bool
+}
+
+func decodeLsSID(data []byte) LsSID {
+	if len(data) == 3 {
+		return LsSID{(uint32(data[0])<<16 | uint32(data[1])<<8 | uint32(data[2])) & 0xfffff, true}
+	}
+	return LsSID{binary.BigEndian.Uint32(data), false}
+}
+
+func (s LsSID) serialize() []byte {
+	if s.IsLabel {
+		return []byte{byte(s.Value >> 16 & 0x0f), byte(s.Value >> 8), byte(s.Value)}
+	}
+	buf := make([]byte, 4)
+	binary.BigEndian.PutUint32(buf, s.Value)
+	return buf
+}
+
+func (s LsSID) len() int {
+	if s.IsLabel {
+		return 3
+	}
+	return 4
+}
+
+func (s LsSID) String() string {
+	if s.IsLabel {
+		return fmt.Sprintf("label:%d", s.Value)
+	}
+	return fmt.Sprintf("index:%d", s.Value)
+}
+
+// LsSRRange is a label range of the SR Capabilities and SR Local Block
+// TLVs  RFC 9085 2.1.2, 2.1.4
+type LsSRRange struct {
+	Size  uint32
+	First LsSID
+}
+
+type LsSRBlock struct {
+	Flags  uint8
+	Ranges []LsSRRange
+}
+
+func (b *LsSRBlock) DecodeFromBytes(data []byte) error {
+	if len(data) < 2 {
+		return fmt.Errorf("Not all BGP-LS SR block bytes available")
+	}
+	b.Flags = data[0]
+	data = data[2:]
+	for len(data) > 0 {
+		if len(data) < 3 {
+			return fmt.Errorf("Not all BGP-LS SR range bytes available")
+		}
+		r := LsSRRange{}
+		r.Size = uint32(data[0])<<16 | uint32(data[1])<<8 | uint32(data[2])
+		t := &LsTLV{}
+		if err := t.DecodeFromBytes(data[3:]); err != nil {
+			return err
+		}
+		if t.Type != LS_TLV_SID_LABEL {
+			return fmt.Errorf("unexpected TLV %d in BGP-LS SR range", t.Type)
+		}
+		if err := lsTLVLength(t, 3, 4); err != nil {
+			return err
+		}
+		r.First = decodeLsSID(t.Value)
+		b.Ranges = append(b.Ranges, r)
+		data = data[3+t.Len():]
+	}
+	return nil
+}
+
+func (b *LsSRBlock) Serialize() ([]byte, error) {
+	buf := []byte{b.Flags, 0}
+	for _, r := range b.Ranges {
+		buf = append(buf, byte(r.Size>>16), byte(r.Size>>8), byte(r.Size))
+		tbuf, err := NewLsTLV(LS_TLV_SID_LABEL, r.First.serialize()).Serialize()
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, tbuf...)
+	}
+	return buf, nil
+}
+
+func (b *LsSRBlock) String() string {
+	s := make([]string, len(b.Ranges))
+	for i, r := range b.Ranges {
+		s[i] = fmt.Sprintf("%s/%d", r.First, r.Size)
+	}
+	return strings.Join(s, " ")
+}
+
+// LsAdjacencySID is an Adjacency SID  RFC 9085 2.2.1. NeighborID is the
+// IS-IS system ID or OSPF router ID of a LAN Adjacency SID and is nil
+// for point-to-point adjacencies.
+type LsAdjacencySID struct {
+	Flags      uint8
+	Weight     uint8
+	NeighborID []byte
+	SID        LsSID
+}
+
+func (a *LsAdjacencySID) DecodeFromBytes(data []byte, lan bool) error {
+	if len(data) < 4 {
+		return fmt.Errorf("Not all BGP-LS adjacency SID bytes available")
+	}
+	a.Flags = data[0]
+	a.Weight = data[1]
+	data = data[4:]
+	if lan {
+		switch len(data) {
+		case 4 + 3, 4 + 4:
+			a.NeighborID = data[:4]
+		case 6 + 3, 6 + 4:
+			a.NeighborID = data[:6]
+		default:
+			return fmt.Errorf("invalid BGP-LS LAN adjacency SID length %d", len(data)+4)
+		}
+		data = data[len(a.NeighborID):]
+	}
+	if len(data) != 3 && len(data) != 4 {
+		return fmt.Errorf("invalid BGP-LS adjacency SID length %d", len(data))
+	}
+	a.SID = decodeLsSID(data)
+	return nil
+}
+
+func (a *LsAdjacencySID) Serialize() ([]byte, error) {
+	buf := []byte{a.Flags, a.Weight, 0, 0}
+	buf = append(buf, a.NeighborID...)
+	return append(buf, a.SID.serialize()...), nil
+}
+
+func (a *LsAdjacencySID) String() string {
+	if a.NeighborID != nil {
+		return fmt.Sprintf("[sid:%s][weight:%d][neighbor:%x]", a.SID, a.Weight, a.NeighborID)
+	}
+	return fmt.Sprintf("[sid:%s][weight:%d]", a.SID, a.Weight)
+}
+
+// LsPrefixSID is a Prefix SID  RFC 9085 2.3.1
+type LsPrefixSID struct {
+	Flags     uint8
+	Algorithm uint8
+	SID       LsSID
+}
+
+func (p *LsPrefixSID) DecodeFromBytes(data []byte) error {
+	if len(data) != 7 && len(data) != 8 {
+		return fmt.Errorf("invalid BGP-LS prefix SID length %d", len(data))
+	}
+	p.Flags = data[0]
+	p.Algorithm = data[1]
+	p.SID = decodeLsSID(data[4:])
+	return nil
+}
+
+func (p *LsPrefixSID) Serialize() ([]byte, error) {
+	buf := []byte{p.Flags, p.Algorithm, 0, 0}
+	return append(buf, p.SID.serialize()...), nil
+}
+
+func (p *LsPrefixSID) String() string {
+	return fmt.Sprintf("[sid:%s][algorithm:%d]", p.SID, p.Algorithm)
+}
+
+type LsNodeAttribute struct {
+	Flags           *uint8
+	Name            string
+	IsisAreaIDs     [][]byte
+	LocalRouterID   net.IP
+	LocalRouterIDv6 net.IP
+	SRCapabilities  *LsSRBlock
+	SRAlgorithms    []uint8
+	SRLocalBlock    *LsSRBlock
+}
+
+func (a *LsNodeAttribute) String() string {
+	buf := bytes.NewBuffer(nil)
+	if a.Flags != nil {
+		buf.WriteString(fmt.Sprintf("[node-flags:%#x]", *a.Flags))
+	}
+	if a.Name != "" {
+		buf.WriteString(fmt.Sprintf("[node-name:%s]", a.Name))
+	}
+	for _, id := range a.IsisAreaIDs {
+		buf.WriteString(fmt.Sprintf("[isis-area:%x]", id))
+	}
+	if a.LocalRouterID != nil {
+		buf.WriteString(fmt.Sprintf("[local-router-id:%s]", a.LocalRouterID))
+	}
+	if a.LocalRouterIDv6 != nil {
+		buf.WriteString(fmt.Sprintf("[local-router-id:%s]", a.LocalRouterIDv6))
+	}
+	if a.SRCapabilities != nil {
+		buf.WriteString(fmt.Sprintf("[sr-capabilities:%s]", a.SRCapabilities))
+	}
+	if a.SRAlgorithms != nil {
+		buf.WriteString(fmt.Sprintf("[sr-algorithms:%v]", a.SRAlgorithms))
+	}
+	if a.SRLocalBlock != nil {
+		buf.WriteString(fmt.Sprintf("[sr-local-block:%s]", a.SRLocalBlock))
+	}
+	return buf.String()
+}
+
+type LsLinkAttribute struct {
+	RemoteRouterID         net.IP
+	RemoteRouterIDv6       net.IP
+	AdminGroup             *uint32
+	MaxLinkBandwidth       *float32
+	MaxReservableBandwidth *float32
+	UnreservedBandwidth    []float32
+	TEDefaultMetric        *uint32
+	IGPMetric              *uint32
+	SRLGs                  []uint32
+	Name                   string
+	AdjacencySIDs          []*LsAdjacencySID
+	LANAdjacencySIDs       []*LsAdjacencySID
+	igpMetricLen           int
+}
+
+func (a *LsLinkAttribute) String() string {
+	buf := bytes.NewBuffer(nil)
+	if a.RemoteRouterID != nil {
+		buf.WriteString(fmt.Sprintf("[remote-router-id:%s]", a.RemoteRouterID))
+	}
+	if a.RemoteRouterIDv6 != nil {
+		buf.WriteString(fmt.Sprintf("[remote-router-id:%s]", a.RemoteRouterIDv6))
+	}
+	if a.AdminGroup != nil {
+		buf.WriteString(fmt.Sprintf("[admin-group:%#x]", *a.AdminGroup))
+	}
+	if a.MaxLinkBandwidth != nil {
+		buf.WriteString(fmt.Sprintf("[max-bandwidth:%g]", *a.MaxLinkBandwidth))
+	}
+	if a.MaxReservableBandwidth != nil {
+		buf.WriteString(fmt.Sprintf("[max-reservable-bandwidth:%g]", *a.MaxReservableBandwidth))
+	}
+	if a.UnreservedBandwidth != nil {
+		buf.WriteString(fmt.Sprintf("[unreserved-bandwidth:%v]", a.UnreservedBandwidth))
+	}
+	if a.TEDefaultMetric != nil {
+		buf.WriteString(fmt.Sprintf("[te-metric:%d]", *a.TEDefaultMetric))
+	}
+	if a.IGPMetric != nil {
+		buf.WriteString(fmt.Sprintf("[igp-metric:%d]", *a.IGPMetric))
+	}
+	if a.SRLGs != nil {
+		buf.WriteString(fmt.Sprintf("[srlg:%v]", a.SRLGs))
+	}
+	if a.Name != "" {
+		buf.WriteString(fmt.Sprintf("[link-name:%s]", a.Name))
+	}
+	for _, s := range a.AdjacencySIDs {
+		buf.WriteString(fmt.Sprintf("[adjacency-sid:%s]", s))
+	}
+	for _, s := range a.LANAdjacencySIDs {
+		buf.WriteString(fmt.Sprintf("[lan-adjacency-sid:%s]", s))
+	}
+	return buf.String()
+}
+
+type LsPrefixAttribute struct {
+	IGPFlags     *uint8
+	RouteTags    []uint32
+	PrefixMetric *uint32
+	PrefixSIDs   []*LsPrefixSID
+}
+
+func (a *LsPrefixAttribute) String() string {
+	buf := bytes.NewBuffer(nil)
+	if a.IGPFlags != nil {
+		buf.WriteString(fmt.Sprintf("[igp-flags:%#x]", *a.IGPFlags))
+	}
+	if a.RouteTags != nil {
+		buf.WriteString(fmt.Sprintf("[route-tags:%v]", a.RouteTags))
+	}
+	if a.PrefixMetric != nil {
+		buf.WriteString(fmt.Sprintf("[prefix-metric:%d]", *a.PrefixMetric))
+	}
+	for _, s := range a.PrefixSIDs {
+		buf.WriteString(fmt.Sprintf("[prefix-sid:%s]", s))
+	}
+	return buf.String()
+}
+
+// PathAttributeLs is the BGP-LS attribute  RFC 9552 5.3. TLVs of the
+// node, link and prefix families are decoded into their typed fields,
+// others are kept in Unknown.
+type PathAttributeLs struct {
+	PathAttribute
+	Node    LsNodeAttribute
+	Link    LsLinkAttribute
+	Prefix  LsPrefixAttribute
+	Unknown []*LsTLV
+}
+
+func NewPathAttributeLs() *PathAttributeLs {
+	p := &PathAttributeLs{}
+	p.Flags = BGP_ATTR_FLAG_OPTIONAL
+	p.Type = BGP_ATTR_TYPE_LS
+	return p
+}
+
+func decodeLsUint32s(t *LsTLV) ([]uint32, error) {
+	if t.Length%4 != 0 {
+		return nil, fmt.Errorf("invalid BGP-LS TLV %d length %d", t.Type, t.Length)
+	}
+	v := make([]uint32, 0, t.Length/4)
+	for i := 0; i < int(t.Length); i += 4 {
+		v = append(v, binary.BigEndian.Uint32(t.Value[i:i+4]))
+	}
+	return v, nil
+}
+
+func lsUint32sTLV(t uint16, v []uint32) *LsTLV {
+	buf := make([]byte, 4*len(v))
+	for i, x := range v {
+		binary.BigEndian.PutUint32(buf[4*i:], x)
+	}
+	return NewLsTLV(t, buf)
+}
+
+func (p *PathAttributeLs) decodeTLV(t *LsTLV) error {
+	uint8Value := func() (*uint8, error) {
+		if err := lsTLVLength(t, 1); err != nil {
+			return nil, err
+		}
+		v := t.Value[0]
+		return &v, nil
+	}
+	uint32Value := func() (*uint32, error) {
+		if err := lsTLVLength(t, 4); err != nil {
+			return nil, err
+		}
+		v := binary.BigEndian.Uint32(t.Value)
+		return &v, nil
+	}
+	float32Value := func() (*float32, error) {
+		v, err := uint32Value()
+		if err != nil {
+			return nil, err
+		}
+		f := math.Float32frombits(*v)
+		return &f, nil
+	}
+	var err error
+	switch t.Type {
+	case LS_TLV_NODE_FLAG_BITS:
+		p.Node.Flags, err = uint8Value()
+	case LS_TLV_NODE_NAME:
+		p.Node.Name = string(t.Value)
+	case LS_TLV_ISIS_AREA_ID:
+		p.Node.IsisAreaIDs = append(p.Node.IsisAreaIDs, t.Value)
+	case LS_TLV_IPV4_LOCAL_ROUTER_ID:
+		err = lsTLVLength(t, 4)
+		p.Node.LocalRouterID = net.IP(t.Value)
+	case LS_TLV_IPV6_LOCAL_ROUTER_ID:
+		err = lsTLVLength(t, 16)
+		p.Node.LocalRouterIDv6 = net.IP(t.Value)
+	case LS_TLV_SR_CAPABILITIES:
+		p.Node.SRCapabilities = &LsSRBlock{}
+		err = p.Node.SRCapabilities.DecodeFromBytes(t.Value)
+	case LS_TLV_SR_ALGORITHM:
+		p.Node.SRAlgorithms = t.Value
+	case LS_TLV_SR_LOCAL_BLOCK:
+		p.Node.SRLocalBlock = &LsSRBlock{}
+		err = p.Node.SRLocalBlock.DecodeFromBytes(t.Value)
+	case LS_TLV_IPV4_REMOTE_ROUTER_ID:
+		err = lsTLVLength(t, 4)
+		p.Link.RemoteRouterID = net.IP(t.Value)
+	case LS_TLV_IPV6_REMOTE_ROUTER_ID:
+		err = lsTLVLength(t, 16)
+		p.Link.RemoteRouterIDv6 = net.IP(t.Value)
+	case LS_TLV_ADMIN_GROUP:
+		p.Link.AdminGroup, err = uint32Value()
+	case LS_TLV_MAX_LINK_BANDWIDTH:
+		p.Link.MaxLinkBandwidth, err = float32Value()
+	case LS_TLV_MAX_RESERVABLE_BANDWIDTH:
+		p.Link.MaxReservableBandwidth, err = float32Value()
+	case LS_TLV_UNRESERVED_BANDWIDTH:
+		if err = lsTLVLength(t, 32); err == nil {
+			p.Link.UnreservedBandwidth = make([]float32, 8)
+			for i := range p.Link.UnreservedBandwidth {
+				p.Link.UnreservedBandwidth[i] = math.Float32frombits(binary.BigEndian.Uint32(t.Value[4*i:]))
+			}
+		}
+	case LS_TLV_TE_DEFAULT_METRIC:
+		p.Link.TEDefaultMetric, err = uint32Value()
+	case LS_TLV_IGP_METRIC:
+		// one octet IS-IS small metric, two octet OSPF metric or three
+		// octet IS-IS wide metric
+		if err = lsTLVLength(t, 1, 2, 3); err == nil {
+			var v uint32
+			for _, b := range t.Value {
+				v = v<<8 | uint32(b)
+			}
+			if t.Length == 1 {
+				v &= 0x3f
+			}
+			p.Link.IGPMetric = &v
+			p.Link.igpMetricLen = int(t.Length)
+		}
+	case LS_TLV_SRLG:
+		p.Link.SRLGs, err = decodeLsUint32s(t)
+	case LS_TLV_LINK_NAME:
+		p.Link.Name = string(t.Value)
+	case LS_TLV_ADJACENCY_SID, LS_TLV_LAN_ADJACENCY_SID:
+		a := &LsAdjacencySID{}
+		lan := t.Type == LS_TLV_LAN_ADJACENCY_SID
+		if err = a.DecodeFromBytes(t.Value, lan); err == nil {
+			if lan {
+				p.Link.LANAdjacencySIDs = append(p.Link.LANAdjacencySIDs, a)
+			} else {
+				p.Link.AdjacencySIDs = append(p.Link.AdjacencySIDs, a)
+			}
+		}
+	case LS_TLV_IGP_FLAGS:
+		p.Prefix.IGPFlags, err = uint8Value()
+	case LS_TLV_ROUTE_TAG:
+		p.Prefix.RouteTags, err = decodeLsUint32s(t)
+	case LS_TLV_PREFIX_METRIC:
+		p.Prefix.PrefixMetric, err = uint32Value()
+	case LS_TLV_PREFIX_SID:
+		s := &LsPrefixSID{}
+		if err = s.DecodeFromBytes(t.Value); err == nil {
+			p.Prefix.PrefixSIDs = append(p.Prefix.PrefixSIDs, s)
+		}
+	default:
+		p.Unknown = append(p.Unknown, t)
+	}
+	return err
+}
+
+func (p *PathAttributeLs) DecodeFromBytes(data []byte) error {
+	p.PathAttribute.DecodeFromBytes(data)
+	tlvs, err := decodeLsTLVs(p.PathAttribute.Value)
+	if err != nil {
+		return err
+	}
+	for _, t := range tlvs {
+		if err := p.decodeTLV(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *PathAttributeLs) tlvs() ([]*LsTLV, error) {
+	tlvs := append([]*LsTLV{}, p.Unknown...)
+	add := func(t *LsTLV) {
+		tlvs = append(tlvs, t)
+	}
+	addBlock := func(t uint16, b *LsSRBlock) error {
+		buf, err := b.Serialize()
+		if err != nil {
+			return err
+		}
+		add(NewLsTLV(t, buf))
+		return nil
+	}
+
+	n := &p.Node
+	if n.Flags != nil {
+		add(lsUint8TLV(LS_TLV_NODE_FLAG_BITS, *n.Flags))
+	}
+	if n.Name != "" {
+		add(NewLsTLV(LS_TLV_NODE_NAME, []byte(n.Name)))
+	}
+	for _, id := range n.IsisAreaIDs {
+		add(NewLsTLV(LS_TLV_ISIS_AREA_ID, id))
+	}
+	if n.LocalRouterID != nil {
+		add(NewLsTLV(LS_TLV_IPV4_LOCAL_ROUTER_ID, []byte(n.LocalRouterID.To4())))
+	}
+	if n.LocalRouterIDv6 != nil {
+		add(NewLsTLV(LS_TLV_IPV6_LOCAL_ROUTER_ID, []byte(n.LocalRouterIDv6.To16())))
+	}
+	if n.SRCapabilities != nil {
+		if err := addBlock(LS_TLV_SR_CAPABILITIES, n.SRCapabilities); err != nil {
+			return nil, err
+		}
+	}
+	if n.SRAlgorithms != nil {
+		add(NewLsTLV(LS_TLV_SR_ALGORITHM, n.SRAlgorithms))
+	}
+	if n.SRLocalBlock != nil {
+		if err := addBlock(LS_TLV_SR_LOCAL_BLOCK, n.SRLocalBlock); err != nil {
+			return nil, err
+		}
+	}
+
+	l := &p.Link
+	if l.RemoteRouterID != nil {
+		add(NewLsTLV(LS_TLV_IPV4_REMOTE_ROUTER_ID, []byte(l.RemoteRouterID.To4())))
+	}
+	if l.RemoteRouterIDv6 != nil {
+		add(NewLsTLV(LS_TLV_IPV6_REMOTE_ROUTER_ID, []byte(l.RemoteRouterIDv6.To16())))
+	}
+	if l.AdminGroup != nil {
+		add(lsUint32TLV(LS_TLV_ADMIN_GROUP, *l.AdminGroup))
+	}
+	if l.MaxLinkBandwidth != nil {
+		add(lsFloat32TLV(LS_TLV_MAX_LINK_BANDWIDTH, *l.MaxLinkBandwidth))
+	}
+	if l.MaxReservableBandwidth != nil {
+		add(lsFloat32TLV(LS_TLV_MAX_RESERVABLE_BANDWIDTH, *l.MaxReservableBandwidth))
+	}
+	if l.UnreservedBandwidth != nil {
+		if len(l.UnreservedBandwidth) != 8 {
+			return nil, fmt.Errorf("unreserved bandwidth needs 8 priorities, got %d", len(l.UnreservedBandwidth))
+		}
+		buf := make([]byte, 32)
+		for i, bw := range l.UnreservedBandwidth {
+			binary.BigEndian.PutUint32(buf[4*i:], math.Float32bits(bw))
+		}
+		add(NewLsTLV(LS_TLV_UNRESERVED_BANDWIDTH, buf))
+	}
+	if l.TEDefaultMetric != nil {
+		add(lsUint32TLV(LS_TLV_TE_DEFAULT_METRIC, *l.TEDefaultMetric))
+	}
+	if l.IGPMetric != nil {
+		size := l.igpMetricLen
+		if size == 0 {
+			size = 3
+		}
+		buf := make([]byte, size)
+		for i := range buf {
+			buf[i] = byte(*l.IGPMetric >> uint(8*(size-1-i)))
+		}
+		add(NewLsTLV(LS_TLV_IGP_METRIC, buf))
+	}
+	if l.SRLGs != nil {
+		add(lsUint32sTLV(LS_TLV_SRLG, l.SRLGs))
+	}
+	if l.Name != "" {
+		add(NewLsTLV(LS_TLV_LINK_NAME, []byte(l.Name)))
+	}
+	for _, a := range l.AdjacencySIDs {
+		buf, _ := a.Serialize()
+		add(NewLsTLV(LS_TLV_ADJACENCY_SID, buf))
+	}
+	for _, a := range l.LANAdjacencySIDs {
+		buf, _ := a.Serialize()
+		add(NewLsTLV(LS_TLV_LAN_ADJACENCY_SID, buf))
+	}
+
+	pr := &p.Prefix
+	if pr.IGPFlags != nil {
+		add(lsUint8TLV(LS_TLV_IGP_FLAGS, *pr.IGPFlags))
+	}
+	if pr.RouteTags != nil {
+		add(lsUint32sTLV(LS_TLV_ROUTE_TAG, pr.RouteTags))
+	}
+	if pr.PrefixMetric != nil {
+		add(lsUint32TLV(LS_TLV_PREFIX_METRIC, *pr.PrefixMetric))
+	}
+	for _, s := range pr.PrefixSIDs {
+		buf, _ := s.Serialize()
+		add(NewLsTLV(LS_TLV_PREFIX_SID, buf))
+	}
+	return tlvs, nil
+}
+
+func (p *PathAttributeLs) Serialize() ([]byte, error) {
+	tlvs, err := p.tlvs()
+	if err != nil {
+		return nil, err
+	}
+	buf, err := serializeLsTLVs(tlvs)
+	if err != nil {
+		return nil, err
+	}
+	return p.PathAttribute.serialize(buf)
+}
+
+func (p *PathAttributeLs) String() string {
+	buf := bytes.NewBuffer(nil)
+	buf.WriteString("BGP_LS: ")
+	buf.WriteString(p.Node.String())
+	buf.WriteString(p.Link.String())
+	buf.WriteString(p.Prefix.String())
+	for _, t := range p.Unknown {
+		buf.WriteString(fmt.Sprintf("[tlv-%d:%x]", t.Type, t.Value))
+	}
+	return buf.String()
+}
+
+func (p *PathAttributeLs) MarshalJSON() ([]byte, error) {
+	return p.PathAttribute.marshalJSONRaw(strings.TrimPrefix(p.String(), "BGP_LS: "), p)
+}
+
+// AIGP attribute  RFC 7311
+const AIGP_TLV_AIGP = 1
+
+type AigpTLV struct {
+	Type  uint8
+	Value []byte
+}
+
+// PathAttributeAigp carries the accumulated IGP metric of the route in
+// Metric, other TLVs are kept in Unknown.
+type PathAttributeAigp struct {
+	PathAttribute
+	Metric  uint64
+	Unknown []*AigpTLV
+}
+
+func NewPathAttributeAigp(metric uint64) *PathAttributeAigp {
+	p := &PathAttributeAigp{}
+	p.Flags = BGP_ATTR_FLAG_OPTIONAL
+	p.Type = BGP_ATTR_TYPE_AIGP
+	p.Metric = metric
+	return p
+}
+
+func (p *PathAttributeAigp) DecodeFromBytes(data []byte) error {
+	p.PathAttribute.DecodeFromBytes(data)
+	value := p.PathAttribute.Value
+	found := false
+	for len(value) > 0 {
+		if len(value) < 3 {
+			return fmt.Errorf("Not all AIGP TLV bytes available")
+		}
+		// the TLV length includes the type and length fields
+		l := int(binary.BigEndian.Uint16(value[1:3]))
+		if l < 3 || len(value) < l {
+			return fmt.Errorf("invalid AIGP TLV length %d", l)
+		}
+		if value[0] == AIGP_TLV_AIGP {
+			if l != 11 {
+				return fmt.Errorf("invalid AIGP TLV length %d", l)
+			}
+			if found {
+				return fmt.Errorf("more than one AIGP TLV")
+			}
+			found = true
+			p.Metric = binary.BigEndian.Uint64(value[3:11])
+		} else {
+			p.Unknown = append(p.Unknown, &AigpTLV{value[0], value[3:l]})
+		}
+		value = value[l:]
+	}
+	if !found {
+		return fmt.Errorf("AIGP attribute has no AIGP TLV")
+	}
+	return nil
+}
+
+func (p *PathAttributeAigp) Serialize() ([]byte, error) {
+	buf := make([]byte, 11)
+	buf[0] = AIGP_TLV_AIGP
+	binary.BigEndian.PutUint16(buf[1:3], 11)
+	binary.BigEndian.PutUint64(buf[3:11], p.Metric)
+	for _, t := range p.Unknown {
+		if len(t.Value)+3 > math.MaxUint16 {
+			return nil, fmt.Errorf("AIGP TLV %d is too long", t.Type)
+		}
+		tbuf := make([]byte, 3)
+		tbuf[0] = t.Type
+		binary.BigEndian.PutUint16(tbuf[1:3], uint16(len(t.Value)+3))
+		buf = append(append(buf, tbuf...), t.Value...)
+	}
+	return p.PathAttribute.serialize(buf)
+}
+
+func (p *PathAttributeAigp) String() string {
+	return fmt.Sprintf("AIGP: %d", p.Metric)
+}
+
+func (p *PathAttributeAigp) MarshalJSON() ([]byte, error) {
+	return p.PathAttribute.marshalJSONRaw(struct {
+		Metric uint64 `json:"metric"`
+	}{p.Metric}, p)
+}
+
+// Accumulate adds the IGP distance to the previous next hop when a
+// speaker sets itself as the next hop of the route  RFC 7311 3.4. The
+// metric saturates instead of wrapping around.
+func (p *PathAttributeAigp) Accumulate(distance uint64) {
+	if p.Metric > math.MaxUint64-distance {
+		p.Metric = math.MaxUint64
+		return
+	}
+	p.Metric += distance
+}
+
+// CompareAigp is the AIGP step of the decision process  RFC 7311 4,
+// meant to run before the AS path length is compared. It returns a
+// negative number when a is preferred, a positive one when b is and 0
+// when the step does not decide. A route with the attribute is
+// preferred to one without; distance is the IGP distance to each
+// route's next hop and is added before comparing.
+func CompareAigp(a, b *PathAttributeAigp, distanceA, distanceB uint64) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	}
+	ma := *a
+	ma.Accumulate(distanceA)
+	mb := *b
+	mb.Accumulate(distanceB)
+	switch {
+	case ma.Metric < mb.Metric:
+		return -1
+	case ma.Metric > mb.Metric:
+		return 1
+	}
+	return 0
+}
+
+// BGP Prefix-SID attribute  RFC 8669, RFC 9252
+const (
+	PREFIX_SID_TLV_LABEL_INDEX     = 1
+	PREFIX_SID_TLV_ORIGINATOR_SRGB = 3
+	PREFIX_SID_TLV_SRV6_L3_SERVICE = 5
+	PREFIX_SID_TLV_SRV6_L2_SERVICE = 6
+)
+
+const (
+	SRV6_SERVICE_SUB_TLV_SID_INFORMATION        = 1
+	SRV6_SERVICE_DATA_SUB_SUB_TLV_SID_STRUCTURE = 1
+)
+
+// PrefixSIDTLV is a TLV of the Prefix-SID attribute, or a sub-TLV or
+// sub-sub-TLV of an SRv6 service TLV, which all share a one octet type
+// and a two octet length.
+type PrefixSIDTLV struct {
+	Type  uint8
+	Value []byte
+}
+
+func decodePrefixSIDTLVs(data []byte) ([]*PrefixSIDTLV, error) {
+	var tlvs []*PrefixSIDTLV
+	for len(data) > 0 {
+		if len(data) < 3 {
+			return nil, fmt.Errorf("Not all Prefix-SID TLV bytes available")
+		}
+		l := int(binary.BigEndian.Uint16(data[1:3]))
+		if len(data) < 3+l {
+			return nil, fmt.Errorf("Not all Prefix-SID TLV %d bytes available", data[0])
+		}
+		tlvs = append(tlvs, &PrefixSIDTLV{data[0], data[3 : 3+l]})
+		data = data[3+l:]
+	}
+	return tlvs, nil
+}
+
+func (t *PrefixSIDTLV) Serialize() ([]byte, error) {
+	if len(t.Value) > math.MaxUint16 {
+		return nil, fmt.Errorf("Prefix-SID TLV %d is too long", t.Type)
+	}
+	buf := make([]byte, 3)
+	buf[0] = t.Type
+	binary.BigEndian.PutUint16(buf[1:3], uint16(len(t.Value)))
+	return append(buf, t.Value...), nil
+}
+
+func serializePrefixSIDTLVs(tlvs []*PrefixSIDTLV) ([]byte, error) {
+	var buf []byte
+	for _, t := range tlvs {
+		tbuf, err := t.Serialize()
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, tbuf...)
+	}
+	return buf, nil
+}
+
+type PrefixSIDLabelIndex struct {
+	Flags      uint16
+	LabelIndex uint32
+}
+
+type SRGB struct {
+	Base  uint32
+	Range uint32
+}
+
+type PrefixSIDOriginatorSRGB struct {
+	Flags uint16
+	SRGBs []SRGB
+}
+
+// SRv6SIDStructure describes how an SRv6 SID splits into locator block,
+// locator node, function and argument, and which of its bits are
+// transposed into the MPLS label field of the route  RFC 9252 3.2.1
+type SRv6SIDStructure struct {
+	LocatorBlockLength  uint8
+	LocatorNodeLength   uint8
+	FunctionLength      uint8
+	ArgumentLength      uint8
+	TranspositionLength uint8
+	TranspositionOffset uint8
+}
+
+type SRv6SIDInformation struct {
+	SID              net.IP
+	Flags            uint8
+	EndpointBehavior uint16
+	Structure        *SRv6SIDStructure
+	Unknown          []*PrefixSIDTLV
+}
+
+func (s *SRv6SIDInformation) DecodeFromBytes(data []byte) error {
+	if len(data) < 21 {
+		return fmt.Errorf("Not all SRv6 SID information bytes available")
+	}
+	s.SID = net.IP(data[1:17])
+	s.Flags = data[17]
+	s.EndpointBehavior = binary.BigEndian.Uint16(data[18:20])
+	tlvs, err := decodePrefixSIDTLVs(data[21:])
+	if err != nil {
+		return err
+	}
+	for _, t := range tlvs {
+		if t.Type != SRV6_SERVICE_DATA_SUB_SUB_TLV_SID_STRUCTURE {
+			s.Unknown = append(s.Unknown, t)
+			continue
+		}
+		if len(t.Value) != 6 {
+			return fmt.Errorf("invalid SRv6 SID structure length %d", len(t.Value))
+		}
+		s.Structure = &SRv6SIDStructure{t.Value[0], t.Value[1], t.Value[2], t.Value[3], t.Value[4], t.Value[5]}
+	}
+	return nil
+}
+
+func (s *SRv6SIDInformation) Serialize() ([]byte, error) {
+	sid := s.SID.To16()
+	if sid == nil {
+		return nil, fmt.Errorf("invalid SRv6 SID %s", s.SID)
+	}
+	buf := make([]byte, 21)
+	copy(buf[1:17], sid)
+	buf[17] = s.Flags
+	binary.BigEndian.PutUint16(buf[18:20], s.EndpointBehavior)
+	var tlvs []*PrefixSIDTLV
+	if st := s.Structure; st != nil {
+		tlvs = append(tlvs, &PrefixSIDTLV{SRV6_SERVICE_DATA_SUB_SUB_TLV_SID_STRUCTURE,
+			[]byte{st.LocatorBlockLength, st.LocatorNodeLength, st.FunctionLength, st.ArgumentLength, st.TranspositionLength, st.TranspositionOffset}})
+	}
+	tbuf, err := serializePrefixSIDTLVs(append(tlvs, s.Unknown...))
+	if err != nil {
+		return nil, err
+	}
+	return append(buf, tbuf...), nil
+}
+
+func (s *SRv6SIDInformation) String() string {
+	return fmt.Sprintf("[sid:%s][behavior:%d]", s.SID, s.EndpointBehavior)
+}
+
+// ServiceSID returns the SID of a service route whose label, given as
+// the 20 bit label value of its NLRI, carries the transposed bits of
+// the SID  RFC 9252 4.
+func (s *SRv6SIDInformation) ServiceSID(label uint32) net.IP {
+	sid := make(net.IP, net.IPv6len)
+	copy(sid, s.SID.To16())
+	st := s.Structure
+	if st == nil {
+		return sid
+	}
+	field := []byte{byte(label >> 12), byte(label >> 4), byte(label << 4)}
+	for i := 0; i < int(st.TranspositionLength) && i < 24; i++ {
+		pos := int(st.TranspositionOffset) + i
+		if pos >= 8*net.IPv6len {
+			break
+		}
+		sid[pos/8] &^= 0x80 >> uint(pos%8)
+		if getBit(field, i) {
+			setBit(sid, pos)
+		}
+	}
+	return sid
+}
+
+// SRv6ServiceTLV is the SRv6 L3 Service or L2 Service TLV.
+type SRv6ServiceTLV struct {
+	SIDInformation []*SRv6SIDInformation
+	Unknown        []*PrefixSIDTLV
+}
+
+func (t *SRv6ServiceTLV) DecodeFromBytes(data []byte) error {
+	if len(data) < 1 {
+		return fmt.Errorf("Not all SRv6 service TLV bytes available")
+	}
+	tlvs, err := decodePrefixSIDTLVs(data[1:])
+	if err != nil {
+		return err
+	}
+	for _, sub := range tlvs {
+		if sub.Type != SRV6_SERVICE_SUB_TLV_SID_INFORMATION {
+			t.Unknown = append(t.Unknown, sub)
+			continue
+		}
+		info := &SRv6SIDInformation{}
+		if err := info.DecodeFromBytes(sub.Value); err != nil {
+			return err
+		}
+		t.SIDInformation = append(t.SIDInformation, info)
+	}
+	return nil
+}
+
+func (t *SRv6ServiceTLV) Serialize() ([]byte, error) {
+	var tlvs []*PrefixSIDTLV
+	for _, info := range t.SIDInformation {
+		ibuf, err := info.Serialize()
+		if err != nil {
+			return nil, err
+		}
+		tlvs = append(tlvs, &PrefixSIDTLV{SRV6_SERVICE_SUB_TLV_SID_INFORMATION, ibuf})
+	}
+	buf, err := serializePrefixSIDTLVs(append(tlvs, t.Unknown...))
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{0}, buf...), nil
+}
+
+func (t *SRv6ServiceTLV) String() string {
+	buf := bytes.NewBuffer(nil)
+	for _, s := range t.SIDInformation {
+		buf.WriteString(s.String())
+	}
+	return buf.String()
+}
+
+type PathAttributePrefixSID struct {
+	PathAttribute
+	LabelIndex     *PrefixSIDLabelIndex
+	OriginatorSRGB *PrefixSIDOriginatorSRGB
+	SRv6L3Service  *SRv6ServiceTLV
+	SRv6L2Service  *SRv6ServiceTLV
+	Unknown        []*PrefixSIDTLV
+}
+
+func NewPathAttributePrefixSID() *PathAttributePrefixSID {
+	p := &PathAttributePrefixSID{}
+	p.Flags = BGP_ATTR_FLAG_OPTIONAL | BGP_ATTR_FLAG_TRANSITIVE
+	p.Type = BGP_ATTR_TYPE_PREFIX_SID
+	return p
+}
+
+func (p *PathAttributePrefixSID) DecodeFromBytes(data []byte) error {
+	p.PathAttribute.DecodeFromBytes(data)
+	tlvs, err := decodePrefixSIDTLVs(p.PathAttribute.Value)
+	if err != nil {
+		return err
+	}
+	for _, t := range tlvs {
+		v := t.Value
+		switch t.Type {
+		case PREFIX_SID_TLV_LABEL_INDEX:
+			if len(v) != 7 {
+				return fmt.Errorf("invalid Prefix-SID label index length %d", len(v))
+			}
+			p.LabelIndex = &PrefixSIDLabelIndex{binary.BigEndian.Uint16(v[1:3]), binary.BigEndian.Uint32(v[3:7])}
+		case PREFIX_SID_TLV_ORIGINATOR_SRGB:
+			if len(v) < 2 || (len(v)-2)%6 != 0 {
+				return fmt.Errorf("invalid Prefix-SID originator SRGB length %d", len(v))
+			}
+			s := &PrefixSIDOriginatorSRGB{Flags: binary.BigEndian.Uint16(v[0:2])}
+			for v = v[2:]; len(v) > 0; v = v[6:] {
+				s.SRGBs = append(s.SRGBs, SRGB{
+					Base:  uint32(v[0])<<16 | uint32(v[1])<<8 | uint32(v[2]),
+					Range: uint32(v[3])<<16 | uint32(v[4])<<8 | uint32(v[5]),
+				})
+			}
+			p.OriginatorSRGB = s
+		case PREFIX_SID_TLV_SRV6_L3_SERVICE, PREFIX_SID_TLV_SRV6_L2_SERVICE:
+			s := &SRv6ServiceTLV{}
+			if err := s.DecodeFromBytes(v); err != nil {
+				return err
+			}
+			if t.Type == PREFIX_SID_TLV_SRV6_L3_SERVICE {
+				p.SRv6L3Service = s
+			} else {
+				p.SRv6L2Service = s
+			}
+		default:
+			p.Unknown = append(p.Unknown, t)
+		}
+	}
+	return nil
+}
+
+func (p *PathAttributePrefixSID) Serialize() ([]byte, error) {
+	var tlvs []*PrefixSIDTLV
+	if l := p.LabelIndex; l != nil {
+		v := make([]byte, 7)
+		binary.BigEndian.PutUint16(v[1:3], l.Flags)
+		binary.BigEndian.PutUint32(v[3:7], l.LabelIndex)
+		tlvs = append(tlvs, &PrefixSIDTLV{PREFIX_SID_TLV_LABEL_INDEX, v})
+	}
+	if s := p.OriginatorSRGB; s != nil {
+		v := make([]byte, 2)
+		binary.BigEndian.PutUint16(v, s.Flags)
+		for _, r := range s.SRGBs {
+			v = append(v, byte(r.Base>>16), byte(r.Base>>8), byte(r.Base),
+				byte(r.Range>>16), byte(r.Range>>8), byte(r.Range))
+		}
+		tlvs = append(tlvs, &PrefixSIDTLV{PREFIX_SID_TLV_ORIGINATOR_SRGB, v})
+	}
+	for _, s := range []struct {
+		t   uint8
+		tlv *SRv6ServiceTLV
+	}{
+		{PREFIX_SID_TLV_SRV6_L3_SERVICE, p.SRv6L3Service},
+		{PREFIX_SID_TLV_SRV6_L2_SERVICE, p.SRv6L2Service},
+	} {
+		if s.tlv == nil {
+			continue
+		}
+		v, err := s.tlv.Serialize()
+		if err != nil {
+			return nil, err
+		}
+		tlvs = append(tlvs, &PrefixSIDTLV{s.t, v})
+	}
+	buf, err := serializePrefixSIDTLVs(append(tlvs, p.Unknown...))
+	if err != nil {
+		return nil, err
+	}
+	return p.PathAttribute.serialize(buf)
+}
+
+func (p *PathAttributePrefixSID) String() string {
+	buf := bytes.NewBuffer(nil)
+	buf.WriteString("PREFIX_SID: ")
+	if p.LabelIndex != nil {
+		buf.WriteString(fmt.Sprintf("[label-index:%d]", p.LabelIndex.LabelIndex))
+	}
+	if p.OriginatorSRGB != nil {
+		for _, r := range p.OriginatorSRGB.SRGBs {
+			buf.WriteString(fmt.Sprintf("[srgb:%d/%d]", r.Base, r.Range))
+		}
+	}
+	if p.SRv6L3Service != nil {
+		buf.WriteString(fmt.Sprintf("[srv6-l3-service:%s]", p.SRv6L3Service))
+	}
+	if p.SRv6L2Service != nil {
+		buf.WriteString(fmt.Sprintf("[srv6-l2-service:%s]", p.SRv6L2Service))
+	}
+	for _, t := range p.Unknown {
+		buf.WriteString(fmt.Sprintf("[tlv-%d:%x]", t.Type, t.Value))
+	}
+	return buf.String()
+}
+
+func (p *PathAttributePrefixSID) MarshalJSON() ([]byte, error) {
+	return p.PathAttribute.marshalJSONRaw(strings.TrimPrefix(p.String(), "PREFIX_SID: "), p)
+}
+
+type PathAttributeUnknown struct {
+	PathAttribute
+}
+
+func (p *PathAttributeUnknown) MarshalJSON() ([]byte, error) {
+	return p.PathAttribute.marshalJSONRaw(nil, p)
+}
+
+func getPathAttribute(data []byte) PathAttributeInterface {
+	switch data[1] {
+	case BGP_ATTR_TYPE_ORIGIN:
+		return &PathAttributeOrigin{}
+	case BGP_ATTR_TYPE_AS_PATH:
+		return &PathAttributeAsPath{}
+	case BGP_ATTR_TYPE_NEXT_HOP:
+		return &PathAttributeNextHop{}
+	case BGP_ATTR_TYPE_MULTI_EXIT_DISC:
+		return &PathAttributeMultiExitDisc{}
+	case BGP_ATTR_TYPE_LOCAL_PREF:
+		return &PathAttributeLocalPref{}
+	case BGP_ATTR_TYPE_ATOMIC_AGGREGATE:
+		return &PathAttributeAtomicAggregate{}
+	case BGP_ATTR_TYPE_AGGREGATOR:
+		return &PathAttributeAggregator{}
+	case BGP_ATTR_TYPE_COMMUNITIES:
+		return &PathAttributeCommunities{}
+	case BGP_ATTR_TYPE_ORIGINATOR_ID:
+		return &PathAttributeOriginatorId{}
+	case BGP_ATTR_TYPE_CLUSTER_LIST:
+		return &PathAttributeClusterList{}
+	case BGP_ATTR_TYPE_MP_REACH_NLRI:
+		return &PathAttributeMpReachNLRI{}
+	case BGP_ATTR_TYPE_MP_UNREACH_NLRI:
+		return &PathAttributeMpUnreachNLRI{}
+	case BGP_ATTR_TYPE_EXTENDED_COMMUNITIES:
+		return &PathAttributeExtendedCommunities{}
+	case BGP_ATTR_TYPE_AS4_PATH:
+		return &PathAttributeAs4Path{}
+	case BGP_ATTR_TYPE_AS4_AGGREGATOR:
+		return &PathAttributeAs4Aggregator{}
+	case BGP_ATTR_TYPE_PMSI_TUNNEL:
+		return &PathAttributePmsiTunnel{}
+	case BGP_ATTR_TYPE_TUNNEL_ENCAP:
+		return &PathAttributeTunnelEncap{}
+	case BGP_ATTR_TYPE_IP6_EXTENDED_COMMUNITIES:
+		return &PathAttributeIP6ExtendedCommunities{}
+	case BGP_ATTR_TYPE_AIGP:
+		return &PathAttributeAigp{}
+	case BGP_ATTR_TYPE_LS:
+		return &PathAttributeLs{}
+	case BGP_ATTR_TYPE_LARGE_COMMUNITY:
+		return &PathAttributeLargeCommunities{}
+	case BGP_ATTR_TYPE_PREFIX_SID:
+		return &PathAttributePrefixSID{}
+	}
+	return &PathAttributeUnknown{}
+}
+
+type NLRInfo struct {
+	IPAddrPrefix
+}
+
+type BGPUpdate struct {
+	WithdrawnRoutesLen    uint16
+	WithdrawnRoutes       []WithdrawnRoute
+	TotalPathAttributeLen uint16
+	PathAttributes        []PathAttributeInterface
+	NLRI                  []NLRInfo
+}
+
+func (msg *BGPUpdate) DecodeFromBytes(data []byte) error {
+	return msg.decodeFromBytes(data, nil)
+}
+
+func (msg *BGPUpdate) decodeFromBytes(data []byte, f *SessionFeatures) error {
+	addpath := f.ReceiveAddPath(AFI_IP, SAFI_UNICAST)
+	msg.WithdrawnRoutesLen = binary.BigEndian.Uint16(data[0:2])
+	data = data[2:]
+	for routelen := int(msg.WithdrawnRoutesLen); routelen > 0; {
+		w := WithdrawnRoute{}
+		rest, err := decodePathIdentifier(&w, data, addpath)
+		if err != nil {
+			return err
+		}
+		w.DecodeFromBytes(rest)
+		l := len(data) - len(rest) + w.Len()
+		routelen -= l
+		data = data[l:]
+		msg.WithdrawnRoutes = append(msg.WithdrawnRoutes, w)
+	}
+	msg.TotalPathAttributeLen = binary.BigEndian.Uint16(data[0:2])
+	data = data[2:]
+	for pathlen := msg.TotalPathAttributeLen; pathlen > 0; {
+		// the header is checked first, attributes read their value from it
+		h := PathAttribute{}
+		if err := h.DecodeFromBytes(data); err != nil {
+			return err
+		}
+		p := getPathAttribute(data)
+		if f != nil {
+			switch a := p.(type) {
+			case *PathAttributeAsPath:
+				a.asLen = 2
+				if f.FourOctetAS {
+					a.asLen = 4
+				}
+			case *PathAttributeMpReachNLRI:
+				a.features = f
+			case *PathAttributeMpUnreachNLRI:
+				a.features = f
+			}
+		}
+		// a malformed attribute fails the whole UPDATE rather than leave
+		// a partly decoded route behind
+		if err := p.DecodeFromBytes(data); err != nil {
+			return fmt.Errorf("malformed %s attribute: %s", pathAttrTypeName(h.Type), err)
+		}
+		if int(pathlen) < p.Len() {
+			return fmt.Errorf("Not all PathAttribute bytes available")
+		}
+		pathlen -= uint16(p.Len())
+		data = data[p.Len():]
+		msg.PathAttributes = append(msg.PathAttributes, p)
+	}
+
+	for restlen := len(data); restlen > 0; {
+		n := NLRInfo{}
+		rest, err := decodePathIdentifier(&n, data, addpath)
+		if err != nil {
+			return err
+		}
+		n.DecodeFromBytes(rest)
+		l := len(data) - len(rest) + n.Len()
+		restlen -= l
+		data = data[l:]
+		msg.NLRI = append(msg.NLRI, n)
+	}
+
+	return nil
+}
+
+// String leaves out the empty parts of the message, and shows an UPDATE
+// without any of them as the IPv4 unicast End-of-RIB marker.
+func (msg *BGPUpdate) String() string {
+	var parts []string
+	if len(msg.WithdrawnRoutes) > 0 {
+		s := make([]string, len(msg.WithdrawnRoutes))
+		for i := range msg.WithdrawnRoutes {
+			s[i] = msg.WithdrawnRoutes[i].String()
+		}
+		parts = append(parts, "withdrawn ["+strings.Join(s, " ")+"]")
+	}
+	if len(msg.PathAttributes) > 0 {
+		s := make([]string, len(msg.PathAttributes))
+		for i, p := range msg.PathAttributes {
+			s[i] = p.String()
+		}
+		parts = append(parts, "attributes ["+strings.Join(s, ", ")+"]")
+	}
+	if len(msg.NLRI) > 0 {
+		s := make([]string, len(msg.NLRI))
+		for i := range msg.NLRI {
+			s[i] = msg.NLRI[i].String()
+		}
+		parts = append(parts, "nlri ["+strings.Join(s, " ")+"]")
+	}
+	if len(parts) == 0 {
+		return "UPDATE: end-of-rib"
+	}
+	return "UPDATE: " + strings.Join(parts, ", ")
+}
+
+type bgpUpdateJSON struct {
+	Withdrawn  []prefixJSON      `json:"withdrawn"`
+	Attributes []json.RawMessage `json:"attributes"`
+	NLRI       []prefixJSON      `json:"nlri"`
+}
+
+func (msg *BGPUpdate) MarshalJSON() ([]byte, error) {
+	j := bgpUpdateJSON{
+		Withdrawn:  make([]prefixJSON, len(msg.WithdrawnRoutes)),
+		Attributes: make([]json.RawMessage, len(msg.PathAttributes)),
+		NLRI:       make([]prefixJSON, len(msg.NLRI)),
+	}
+	for i := range msg.WithdrawnRoutes {
+		j.Withdrawn[i] = newPrefixJSON(&msg.WithdrawnRoutes[i])
+	}
+	for i, p := range msg.PathAttributes {
+		b, err := p.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		j.Attributes[i] = b
+	}
+	for i := range msg.NLRI {
+		j.NLRI[i] = newPrefixJSON(&msg.NLRI[i])
+	}
+	return json.Marshal(j)
+}
+
+func (msg *BGPUpdate) UnmarshalJSON(data []byte) error {
+	var j bgpUpdateJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	*msg = BGPUpdate{}
+	for _, w := range j.Withdrawn {
+		p, err := w.ipAddrPrefix()
+		if err != nil {
+			return err
+		}
+		msg.WithdrawnRoutes = append(msg.WithdrawnRoutes, WithdrawnRoute{*p})
+		msg.WithdrawnRoutesLen += uint16(p.Len())
+	}
+	for _, b := range j.Attributes {
+		p, err := decodePathAttributeJSON(b)
+		if err != nil {
+			return err
+		}
+		msg.PathAttributes = append(msg.PathAttributes, p)
+		msg.TotalPathAttributeLen += uint16(p.Len())
+	}
+	for _, n := range j.NLRI {
+		p, err := n.ipAddrPrefix()
+		if err != nil {
+			return err
+		}
+		msg.NLRI = append(msg.NLRI, NLRInfo{*p})
+	}
+	return nil
+}
+
+// BGPNotificationDataInterface is the Data of a NOTIFICATION decoded
+// according to its error code and subcode.
+type BGPNotificationDataInterface interface {
+	DecodeFromBytes([]byte) error
+	String() string
+}
+
+// NotificationDataBadMessageLength is the erroneous length field of a
+// Message Header Error/Bad Message Length.
+type NotificationDataBadMessageLength struct {
+	Length uint16
+}
+
+func (n *NotificationDataBadMessageLength) DecodeFromBytes(data []byte) error {
+	if len(data) < 2 {
+		return fmt.Errorf("Not all Bad Message Length bytes available")
+	}
+	n.Length = binary.BigEndian.Uint16(data[0:2])
+	return nil
+}
+
+func (n *NotificationDataBadMessageLength) String() string {
+	return fmt.Sprintf("length %d", n.Length)
+}
+
+// NotificationDataBadMessageType is the erroneous type field of a
+// Message Header Error/Bad Message Type.
+type NotificationDataBadMessageType struct {
+	Type uint8
+}
+
+func (n *NotificationDataBadMessageType) DecodeFromBytes(data []byte) error {
+	if len(data) < 1 {
+		return fmt.Errorf("Not all Bad Message Type bytes available")
+	}
+	n.Type = data[0]
+	return nil
+}
+
+func (n *NotificationDataBadMessageType) String() string {
+	return fmt.Sprintf("type %d", n.Type)
+}
+
+// NotificationDataUnsupportedVersion is the largest version the sender
+// of an OPEN Message Error/Unsupported Version Number supports.
+type NotificationDataUnsupportedVersion struct {
+	Version uint16
+}
+
+func (n *NotificationDataUnsupportedVersion) DecodeFromBytes(data []byte) error {
+	if len(data) < 2 {
+		return fmt.Errorf("Not all Unsupported Version Number bytes available")
 	}
-	return true
+	n.Version = binary.BigEndian.Uint16(data[0:2])
+	return nil
 }
 
-// TODO: could marge two functions nicely with reflect?
-func (p *DefaultAsPath) decodeAspath(data []byte) []AsPathParam {
-	var param []AsPathParam
+func (n *NotificationDataUnsupportedVersion) String() string {
+	return fmt.Sprintf("version %d", n.Version)
+}
 
+// NotificationDataUnsupportedCapability lists the capabilities an OPEN
+// Message Error/Unsupported Capability refuses  RFC 5492 5
+type NotificationDataUnsupportedCapability struct {
+	Capabilities []ParameterCapabilityInterface
+}
+
+func (n *NotificationDataUnsupportedCapability) DecodeFromBytes(data []byte) error {
 	for len(data) > 0 {
-		a := AsPathParam{}
-		a.Type = data[0]
-		a.Num = data[1]
-		data = data[2:]
-		for i := 0; i < int(a.Num); i++ {
-			a.AS = append(a.AS, uint32(binary.BigEndian.Uint16(data)))
-			data = data[2:]
+		if len(data) < 2 || len(data) < 2+int(data[1]) {
+			return fmt.Errorf("Not all Unsupported Capability bytes available")
 		}
-		param = append(param, a)
+		l := 2 + int(data[1])
+		o := OptionParameterCapability{ParamType: BGP_OPT_CAPABILITY, ParamLen: uint8(l)}
+		if err := o.DecodeFromBytes(data[:l]); err != nil {
+			return err
+		}
+		n.Capabilities = append(n.Capabilities, o.Capability...)
+		data = data[l:]
 	}
-	return param
+	return nil
 }
 
-func (p *DefaultAsPath) decodeAs4path(data []byte) []AsPathParam {
-	var param []AsPathParam
-
-	for len(data) > 0 {
-		a := AsPathParam{}
-		a.Type = data[0]
-		a.Num = data[1]
-		data = data[2:]
-		for i := 0; i < int(a.Num); i++ {
-			a.AS = append(a.AS, binary.BigEndian.Uint32(data))
-			data = data[4:]
-		}
-		param = append(param, a)
+func (n *NotificationDataUnsupportedCapability) String() string {
+	s := make([]string, len(n.Capabilities))
+	for i, c := range n.Capabilities {
+		s[i] = c.String()
 	}
-	return param
+	return fmt.Sprintf("capabilities [%s]", strings.Join(s, " "))
 }
 
-type PathAttributeAsPath struct {
-	DefaultAsPath
-	PathAttribute
-	Value []AsPathParam
+// NotificationDataAttribute is the attribute an UPDATE Message Error
+// complains about. Its length field is kept as received, which for an
+// Attribute Length Error need not match the value.
+type NotificationDataAttribute struct {
+	Attribute PathAttribute
 }
 
-func (p *PathAttributeAsPath) DecodeFromBytes(data []byte) error {
-	p.PathAttribute.DecodeFromBytes(data)
-	if p.DefaultAsPath.isValidAspath(p.PathAttribute.Value) {
-		p.Value = p.DefaultAsPath.decodeAspath(p.PathAttribute.Value)
+func (n *NotificationDataAttribute) DecodeFromBytes(data []byte) error {
+	if len(data) < 3 {
+		return fmt.Errorf("Not all erroneous attribute bytes available")
+	}
+	a := &n.Attribute
+	a.Flags = data[0]
+	a.Type = data[1]
+	if a.Flags&BGP_ATTR_FLAG_EXTENDED_LENGTH != 0 {
+		if len(data) < 4 {
+			return fmt.Errorf("Not all erroneous attribute bytes available")
+		}
+		a.Length = binary.BigEndian.Uint16(data[2:4])
+		data = data[4:]
 	} else {
-		p.Value = p.DefaultAsPath.decodeAs4path(p.PathAttribute.Value)
+		a.Length = uint16(data[2])
+		data = data[3:]
 	}
+	if int(a.Length) < len(data) {
+		data = data[:a.Length]
+	}
+	a.Value = data
 	return nil
 }
 
-type PathAttributeNextHop struct {
-	PathAttribute
-	Value net.IP
+func (n *NotificationDataAttribute) String() string {
+	return "attribute " + n.Attribute.String()
 }
 
-func (p *PathAttributeNextHop) DecodeFromBytes(data []byte) error {
-	p.PathAttribute.DecodeFromBytes(data)
-	p.Value = p.PathAttribute.Value
+// NotificationDataMissingAttribute is the type of the attribute an
+// UPDATE Message Error/Missing Well-known Attribute misses.
+type NotificationDataMissingAttribute struct {
+	Type uint8
+}
+
+func (n *NotificationDataMissingAttribute) DecodeFromBytes(data []byte) error {
+	if len(data) < 1 {
+		return fmt.Errorf("Not all Missing Well-known Attribute bytes available")
+	}
+	n.Type = data[0]
 	return nil
 }
 
-type PathAttributeMultiExitDisc struct {
-	PathAttribute
-	Value uint32
+func (n *NotificationDataMissingAttribute) String() string {
+	return "missing " + pathAttrTypeName(n.Type)
 }
 
-func (p *PathAttributeMultiExitDisc) DecodeFromBytes(data []byte) error {
-	p.PathAttribute.DecodeFromBytes(data)
-	p.Value = binary.BigEndian.Uint32(p.PathAttribute.Value)
+// NotificationDataMaximumPrefixes is the family and limit of a
+// Cease/Maximum Number of Prefixes Reached  RFC 4486 4
+type NotificationDataMaximumPrefixes struct {
+	AFI   uint16
+	SAFI  uint8
+	Limit uint32
+}
+
+func (n *NotificationDataMaximumPrefixes) DecodeFromBytes(data []byte) error {
+	if len(data) < 7 {
+		return fmt.Errorf("Not all Maximum Number of Prefixes Reached bytes available")
+	}
+	n.AFI = binary.BigEndian.Uint16(data[0:2])
+	n.SAFI = data[2]
+	n.Limit = binary.BigEndian.Uint32(data[3:7])
 	return nil
 }
 
-type PathAttributeLocalPref struct {
-	PathAttribute
-	Value uint32
+func (n *NotificationDataMaximumPrefixes) String() string {
+	return fmt.Sprintf("%s limit %d", routeFamilyName(n.AFI, n.SAFI), n.Limit)
 }
 
-func (p *PathAttributeLocalPref) DecodeFromBytes(data []byte) error {
-	p.PathAttribute.DecodeFromBytes(data)
-	p.Value = binary.BigEndian.Uint32(p.PathAttribute.Value)
-	return nil
+// NotificationDataHardReset is the NOTIFICATION a Cease/Hard Reset
+// carries to tell why the session was reset  RFC 8538 3
+type NotificationDataHardReset struct {
+	Notification BGPNotification
 }
 
-type PathAttributeAtomicAggregate struct {
-	PathAttribute
+func (n *NotificationDataHardReset) DecodeFromBytes(data []byte) error {
+	return n.Notification.DecodeFromBytes(data)
 }
 
-type PathAttributeAggregatorParam struct {
-	AS      uint32
-	Address net.IP
+func (n *NotificationDataHardReset) String() string {
+	return "inner " + n.Notification.describe()
 }
 
-type PathAttributeAggregator struct {
-	PathAttribute
-	Value PathAttributeAggregatorParam
+// Shutdown Communication  RFC 9003
+const BGP_ERROR_SHUTDOWN_COMMUNICATION_MAX_LENGTH = 255
+
+type BGPNotification struct {
+	ErrorCode    uint8
+	ErrorSubcode uint8
+	Data         []byte
+	// Communication is the text an operator gave with an Administrative
+	// Shutdown or Administrative Reset Cease.
+	Communication string
+	// Detail is what else Data says for the error code and subcode, it
+	// is nil when there is nothing to decode or Data is malformed.
+	Detail BGPNotificationDataInterface
 }
 
-func (p *PathAttributeAggregator) DecodeFromBytes(data []byte) error {
-	p.PathAttribute.DecodeFromBytes(data)
-	if len(p.PathAttribute.Value) == 6 {
-		p.Value.AS = uint32(binary.BigEndian.Uint16(p.PathAttribute.Value[0:2]))
-		p.Value.Address = p.PathAttribute.Value[2:]
-	} else {
-		p.Value.AS = binary.BigEndian.Uint32(p.PathAttribute.Value[0:4])
-		p.Value.Address = p.PathAttribute.Value[4:]
+func (msg *BGPNotification) Code() BGPErrorCode {
+	return BGPErrorCode(msg.ErrorCode)
+}
+
+// Subcode returns the subcode typed after its error code, one of
+// BGPMessageHeaderErrorSubcode, BGPOpenMessageErrorSubcode and so on.
+func (msg *BGPNotification) Subcode() fmt.Stringer {
+	return bgpErrorSubcode(msg.ErrorCode, msg.ErrorSubcode)
+}
+
+// NewShutdownNotification builds an Administrative Shutdown or
+// Administrative Reset Cease carrying communication, which may be
+// empty.
+func NewShutdownNotification(subcode uint8, communication string) (*BGPNotification, error) {
+	if subcode != BGP_ERROR_SUB_ADMINISTRATIVE_SHUTDOWN && subcode != BGP_ERROR_SUB_ADMINISTRATIVE_RESET {
+		return nil, fmt.Errorf("Cease subcode %d carries no shutdown communication", subcode)
 	}
-	return nil
+	data, err := encodeShutdownCommunication(communication)
+	if err != nil {
+		return nil, err
+	}
+	return &BGPNotification{
+		ErrorCode:     BGP_ERROR_CEASE,
+		ErrorSubcode:  subcode,
+		Data:          data,
+		Communication: communication,
+	}, nil
+}
+
+// NewHardResetNotification wraps inner in a Cease/Hard Reset, which
+// ends the session without graceful restart even when the N bit was
+// negotiated.
+func NewHardResetNotification(inner *BGPNotification) (*BGPNotification, error) {
+	data, err := inner.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	msg := &BGPNotification{
+		ErrorCode:    BGP_ERROR_CEASE,
+		ErrorSubcode: BGP_ERROR_SUB_HARD_RESET,
+		Data:         data,
+	}
+	msg.decodeData()
+	return msg, nil
 }
 
-type PathAttributeCommunities struct {
-	PathAttribute
-	Value []uint32
+func (msg *BGPNotification) IsHardReset() bool {
+	return msg != nil && msg.ErrorCode == BGP_ERROR_CEASE && msg.ErrorSubcode == BGP_ERROR_SUB_HARD_RESET
 }
 
-func (p *PathAttributeCommunities) DecodeFromBytes(data []byte) error {
-	p.PathAttribute.DecodeFromBytes(data)
-	value := p.PathAttribute.Value
-	for len(value) >= 4 {
-		p.Value = append(p.Value, binary.BigEndian.Uint32(value))
-		value = value[4:]
+func encodeShutdownCommunication(communication string) ([]byte, error) {
+	if len(communication) > BGP_ERROR_SHUTDOWN_COMMUNICATION_MAX_LENGTH {
+		return nil, fmt.Errorf("shutdown communication is longer than %d bytes", BGP_ERROR_SHUTDOWN_COMMUNICATION_MAX_LENGTH)
 	}
-	return nil
+	if !utf8.ValidString(communication) {
+		return nil, fmt.Errorf("shutdown communication is not valid UTF-8")
+	}
+	return append([]byte{uint8(len(communication))}, communication...), nil
 }
 
-type PathAttributeOriginatorId struct {
-	PathAttribute
-	Value net.IP
+// hasCommunication reports whether the notification is of a kind that
+// carries a shutdown communication.
+func (msg *BGPNotification) hasCommunication() bool {
+	return msg.ErrorCode == BGP_ERROR_CEASE &&
+		(msg.ErrorSubcode == BGP_ERROR_SUB_ADMINISTRATIVE_SHUTDOWN || msg.ErrorSubcode == BGP_ERROR_SUB_ADMINISTRATIVE_RESET)
 }
 
-func (p *PathAttributeOriginatorId) DecodeFromBytes(data []byte) error {
-	p.PathAttribute.DecodeFromBytes(data)
-	p.Value = p.PathAttribute.Value
+// decodeData fills the fields decoded from Data. Malformed data leaves
+// them empty: a NOTIFICATION closes the session anyway, and RFC 9003
+// asks for a bad communication to be ignored rather than rejected.
+func (msg *BGPNotification) decodeData() {
+	msg.Communication = ""
+	msg.Detail = nil
+	if len(msg.Data) == 0 {
+		return
+	}
+	if msg.hasCommunication() {
+		l := int(msg.Data[0])
+		if l < len(msg.Data) && utf8.Valid(msg.Data[1:1+l]) {
+			msg.Communication = string(msg.Data[1 : 1+l])
+		}
+		return
+	}
+	var d BGPNotificationDataInterface
+	switch msg.ErrorCode {
+	case BGP_ERROR_MESSAGE_HEADER_ERROR:
+		switch msg.ErrorSubcode {
+		case BGP_ERROR_SUB_BAD_MESSAGE_LENGTH:
+			d = &NotificationDataBadMessageLength{}
+		case BGP_ERROR_SUB_BAD_MESSAGE_TYPE:
+			d = &NotificationDataBadMessageType{}
+		}
+	case BGP_ERROR_OPEN_MESSAGE_ERROR:
+		switch msg.ErrorSubcode {
+		case BGP_ERROR_SUB_UNSUPPORTED_VERSION_NUMBER:
+			d = &NotificationDataUnsupportedVersion{}
+		case BGP_ERROR_SUB_UNSUPPORTED_CAPABILITY:
+			d = &NotificationDataUnsupportedCapability{}
+		}
+	case BGP_ERROR_UPDATE_MESSAGE_ERROR:
+		switch msg.ErrorSubcode {
+		case BGP_ERROR_SUB_MISSING_WELL_KNOWN_ATTRIBUTE:
+			d = &NotificationDataMissingAttribute{}
+		case BGP_ERROR_SUB_UNRECOGNIZED_WELL_KNOWN_ATTRIBUTE, BGP_ERROR_SUB_ATTRIBUTE_FLAGS_ERROR,
+			BGP_ERROR_SUB_ATTRIBUTE_LENGTH_ERROR, BGP_ERROR_SUB_INVALID_ORIGIN_ATTRIBUTE,
+			BGP_ERROR_SUB_INVALID_NEXT_HOP_ATTRIBUTE, BGP_ERROR_SUB_OPTIONAL_ATTRIBUTE_ERROR:
+			d = &NotificationDataAttribute{}
+		}
+	case BGP_ERROR_CEASE:
+		switch msg.ErrorSubcode {
+		case BGP_ERROR_SUB_MAXIMUM_NUMBER_OF_PREFIXES_REACHED:
+			d = &NotificationDataMaximumPrefixes{}
+		case BGP_ERROR_SUB_HARD_RESET:
+			d = &NotificationDataHardReset{}
+		}
+	}
+	if d != nil && d.DecodeFromBytes(msg.Data) == nil {
+		msg.Detail = d
+	}
+}
+
+func (msg *BGPNotification) DecodeFromBytes(data []byte) error {
+	if len(data) < 2 {
+		return fmt.Errorf("Not all Notificaiton bytes available")
+	}
+	msg.ErrorCode = data[0]
+	msg.ErrorSubcode = data[1]
+	if len(data) > 2 {
+		msg.Data = data[2:]
+	}
+	msg.decodeData()
 	return nil
 }
 
-type PathAttributeClusterList struct {
-	PathAttribute
-	Value []net.IP
+// Serialize encodes Communication in place of Data when it is set.
+func (msg *BGPNotification) Serialize() ([]byte, error) {
+	data := msg.Data
+	if msg.Communication != "" && msg.hasCommunication() {
+		var err error
+		data, err = encodeShutdownCommunication(msg.Communication)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return append([]byte{msg.ErrorCode, msg.ErrorSubcode}, data...), nil
 }
 
-func (p *PathAttributeClusterList) DecodeFromBytes(data []byte) error {
-	p.PathAttribute.DecodeFromBytes(data)
-	value := p.PathAttribute.Value
-	for len(value) >= 4 {
-		p.Value = append(p.Value, value[:4])
-		value = value[4:]
+func (msg *BGPNotification) String() string {
+	return "NOTIFICATION: " + msg.describe()
+}
+
+func (msg *BGPNotification) describe() string {
+	switch {
+	case msg.Communication != "":
+		return fmt.Sprintf("%s, %q", bgpErrorName(msg.ErrorCode, msg.ErrorSubcode), msg.Communication)
+	case msg.Detail != nil:
+		return fmt.Sprintf("%s, %s", bgpErrorName(msg.ErrorCode, msg.ErrorSubcode), msg.Detail)
+	case len(msg.Data) > 0:
+		return fmt.Sprintf("%s, data %x", bgpErrorName(msg.ErrorCode, msg.ErrorSubcode), msg.Data)
 	}
-	return nil
+	return bgpErrorName(msg.ErrorCode, msg.ErrorSubcode)
 }
 
-type PathAttributeMpReachNLRI struct {
-	PathAttribute
-	Nexthop net.IP
-	Value   []AddrPrefixInterface
+type bgpNotificationJSON struct {
+	Code          uint8  `json:"code"`
+	Subcode       uint8  `json:"subcode"`
+	Name          string `json:"name"`
+	Communication string `json:"communication,omitempty"`
+	Detail        string `json:"detail,omitempty"`
+	Data          string `json:"data,omitempty"`
 }
 
-func (p *PathAttributeMpReachNLRI) DecodeFromBytes(data []byte) error {
-	p.PathAttribute.DecodeFromBytes(data)
+// MarshalJSON writes Detail as text for reading, UnmarshalJSON decodes
+// it from Data again.
+func (msg *BGPNotification) MarshalJSON() ([]byte, error) {
+	detail := ""
+	if msg.Detail != nil {
+		detail = msg.Detail.String()
+	}
+	return json.Marshal(bgpNotificationJSON{
 
-	value := p.PathAttribute.Value
-	afi := binary.BigEndian.Uint16(value[0:2])
-	safi := value[2]
-	nexthopLen := value[3]
-	nexthopbin := value[4 : 4+nexthopLen]
-	value = value[4+nexthopLen:]
-	if nexthopLen > 0 {
-		offset := 0
-		if safi == SAFI_MPLS_VPN {
-			offset = 8
+		Code:          msg.ErrorCode,
+		Subcode:       msg.ErrorSubcode,
+		Name:          bgpErrorName(msg.ErrorCode, msg.ErrorSubcode),
+		Communication: msg.Communication,
+		Detail:        detail,
+		Data:          fmt.Sprintf("%x", msg.Data),
+	})
+}
+
+func (msg *BGPNotification) UnmarshalJSON(data []byte) error {
+	var j bgpNotificationJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	*msg = BGPNotification{ErrorCode: j.Code, ErrorSubcode: j.Subcode}
+	if j.Data != "" {
+		d, err := hex.DecodeString(j.Data)
+		if err != nil {
+			return err
 		}
-		addrlen := 4
-		if afi == AFI_IP6 {
-			addrlen = 16
+		msg.Data = d
+	} else if j.Communication != "" && msg.hasCommunication() {
+		d, err := encodeShutdownCommunication(j.Communication)
+		if err != nil {
+			return err
 		}
-		p.Nexthop = nexthopbin[offset : +offset+addrlen]
+		msg.Data = d
 	}
-	// skip reserved
-	value = value[1:]
-	for len(value) > 0 {
-		prefix := routeFamilyPrefix(afi, safi)
-		prefix.DecodeFromBytes(value)
-		value = value[prefix.Len():]
-		p.Value = append(p.Value, prefix)
+	msg.decodeData()
+	return nil
+}
+
+type BGPKeepAlive struct {
+}
+
+func (msg *BGPKeepAlive) DecodeFromBytes(data []byte) error {
+	return nil
+}
+
+func (msg *BGPKeepAlive) String() string {
+	return "KEEPALIVE"
+}
+
+// ORF entries carried in ROUTE-REFRESH  RFC 5291, RFC 5292
+const (
+	ORF_WHEN_TO_REFRESH_IMMEDIATE = 1
+	ORF_WHEN_TO_REFRESH_DEFER     = 2
+)
+
+const (
+	ORF_ACTION_ADD = iota
+	ORF_ACTION_REMOVE
+	ORF_ACTION_REMOVE_ALL
+)
+
+const (
+	ORF_MATCH_PERMIT = iota
+	ORF_MATCH_DENY
+)
+
+type ORFEntryInterface interface {
+	DecodeFromBytes([]byte) error
+	Len() int
+	Serialize() ([]byte, error)
+	String() string
+}
+
+type DefaultORFEntry struct {
+	Action uint8
+	Match  uint8
+}
+
+func (e *DefaultORFEntry) DecodeFromBytes(data []byte) error {
+	if len(data) < 1 {
+		return fmt.Errorf("Not all ORF entry bytes available")
 	}
+	e.Action = data[0] >> 6
+	e.Match = (data[0] >> 5) & 1
 	return nil
 }
 
-type PathAttributeMpUnreachNLRI struct {
-	PathAttribute
-	Value []AddrPrefixInterface
+func (e *DefaultORFEntry) Len() int { return 1 }
+
+func (e *DefaultORFEntry) Serialize() ([]byte, error) {
+	return []byte{e.Action<<6 | (e.Match&1)<<5}, nil
 }
 
-func (p *PathAttributeMpUnreachNLRI) DecodeFromBytes(data []byte) error {
-	p.PathAttribute.DecodeFromBytes(data)
+var orfActionNames = map[uint8]string{
+	ORF_ACTION_ADD:        "add",
+	ORF_ACTION_REMOVE:     "remove",
+	ORF_ACTION_REMOVE_ALL: "remove-all",
+}
 
-	value := p.PathAttribute.Value
-	afi := binary.BigEndian.Uint16(value[0:2])
-	safi := value[2]
-	addrprefix := routeFamilyPrefix(afi, safi)
-	value = value[3:]
-	for len(value) > 0 {
-		prefix := addrprefix
-		prefix.DecodeFromBytes(value)
-		value = value[prefix.Len():]
-		p.Value = append(p.Value, prefix)
+func (e *DefaultORFEntry) String() string {
+	action, ok := orfActionNames[e.Action]
+	if !ok {
+		action = strconv.Itoa(int(e.Action))
+	}
+	if e.Match == ORF_MATCH_DENY {
+		return action + " deny"
+	}
+	return action + " permit"
+}
+
+type AddressPrefixORFEntry struct {
+	DefaultORFEntry
+	Sequence uint32
+	MinLen   uint8
+	MaxLen   uint8
+	Length   uint8
+	Prefix   net.IP
+	addrlen  uint8
+}
+
+func NewAddressPrefixORFEntry(afi uint16) *AddressPrefixORFEntry {
+	e := &AddressPrefixORFEntry{}
+	e.addrlen = 4
+	if afi == AFI_IP6 {
+		e.addrlen = 16
+	}
+	return e
+}
+
+func (e *AddressPrefixORFEntry) DecodeFromBytes(data []byte) error {
+	err := e.DefaultORFEntry.DecodeFromBytes(data)
+	if err != nil {
+		return err
+	}
+	if e.Action == ORF_ACTION_REMOVE_ALL {
+		return nil
+	}
+	data = data[1:]
+	if len(data) < 7 {
+		return fmt.Errorf("Not all AddressPrefixORF bytes available")
+	}
+	e.Sequence = binary.BigEndian.Uint32(data[0:4])
+	e.MinLen = data[4]
+	e.MaxLen = data[5]
+	e.Length = data[6]
+	data = data[7:]
+	if e.addrlen == 0 {
+		e.addrlen = 4
 	}
+	bytelen := (int(e.Length) + 7) / 8
+	if e.Length > 8*e.addrlen || len(data) < bytelen {
+		return fmt.Errorf("Not all AddressPrefixORF bytes available")
+	}
+	b := make([]byte, e.addrlen)
+	copy(b, data[:bytelen])
+	e.Prefix = b
 	return nil
 }
 
-type ExtendedCommunityInterface interface {
+func (e *AddressPrefixORFEntry) Len() int {
+	if e.Action == ORF_ACTION_REMOVE_ALL {
+		return 1
+	}
+	return 8 + (int(e.Length)+7)/8
 }
 
-type TwoOctetAsSpecificExtended struct {
-	AS         uint16
-	LocalAdmin uint32
+func (e *AddressPrefixORFEntry) Serialize() ([]byte, error) {
+	buf, _ := e.DefaultORFEntry.Serialize()
+	if e.Action == ORF_ACTION_REMOVE_ALL {
+		return buf, nil
+	}
+	b := make([]byte, 7)
+	binary.BigEndian.PutUint32(b[0:4], e.Sequence)
+	b[4] = e.MinLen
+	b[5] = e.MaxLen
+	b[6] = e.Length
+	bytelen := (int(e.Length) + 7) / 8
+	if len(e.Prefix) < bytelen {
+		return nil, fmt.Errorf("AddressPrefixORF prefix is shorter than its length")
+	}
+	b = append(b, e.Prefix[:bytelen]...)
+	return append(buf, b...), nil
 }
 
-type IPv4AddressSpecificExtended struct {
-	SubType    uint8
-	IPv4       net.IP
-	LocalAdmin uint16
+func (e *AddressPrefixORFEntry) String() string {
+	if e.Action == ORF_ACTION_REMOVE_ALL {
+		return orfActionNames[e.Action]
+	}
+	return fmt.Sprintf("%s seq %d %s/%d ge %d le %d", &e.DefaultORFEntry, e.Sequence, e.Prefix, e.Length, e.MinLen, e.MaxLen)
 }
 
-type FourOctetAsSpecificExtended struct {
-	AS         net.IP
-	LocalAdmin uint16
+func (e *AddressPrefixORFEntry) match(prefix net.IP, length uint8) bool {
+	bitlen := uint8(8 * len(e.Prefix))
+	minlen := e.MinLen
+	maxlen := e.MaxLen
+	if minlen == 0 {
+		minlen = e.Length
+	}
+	if maxlen == 0 {
+		if e.MinLen == 0 {
+			maxlen = e.Length
+		} else {
+			maxlen = bitlen
+		}
+	}
+	if length < e.Length || length < minlen || length > maxlen {
+		return false
+	}
+	mask := net.CIDRMask(int(e.Length), int(bitlen))
+	p := prefix.Mask(mask)
+	return p != nil && p.Equal(e.Prefix.Mask(mask))
 }
 
-type OpaqueExtended struct {
-	Value []byte
+func (e *AddressPrefixORFEntry) sameAs(o *AddressPrefixORFEntry) bool {
+	return e.Sequence == o.Sequence && e.Match == o.Match &&
+		e.MinLen == o.MinLen && e.MaxLen == o.MaxLen &&
+		e.Length == o.Length && e.Prefix.Equal(o.Prefix)
 }
 
-type UnknownExtended struct {
+type UnknownORFEntry struct {
 	Value []byte
 }
 
-type PathAttributeExtendedCommunities struct {
-	PathAttribute
-	Value []ExtendedCommunityInterface
+func (e *UnknownORFEntry) DecodeFromBytes(data []byte) error {
+	e.Value = data
+	return nil
 }
 
-func parseExtended(data []byte) ExtendedCommunityInterface {
-	typehigh := data[0] & ^uint8(0x40)
-	switch typehigh {
-	case 0:
-		e := &TwoOctetAsSpecificExtended{}
-		e.AS = binary.BigEndian.Uint16(data[2:4])
-		e.LocalAdmin = binary.BigEndian.Uint32(data[4:8])
-		return e
-	case 1:
-		e := &IPv4AddressSpecificExtended{}
-		e.SubType = data[1]
-		e.IPv4 = data[2:6]
-		e.LocalAdmin = binary.BigEndian.Uint16(data[6:8])
-		return e
-	case 2:
-		e := &FourOctetAsSpecificExtended{}
-		e.AS = data[2:6]
-		e.LocalAdmin = binary.BigEndian.Uint16(data[6:8])
-		return e
-	case 3:
-		e := &OpaqueExtended{}
-		e.Value = data[2:8]
-		return e
-	}
-	e := &UnknownExtended{}
-	e.Value = data[2:8]
-	return e
+func (e *UnknownORFEntry) Len() int { return len(e.Value) }
+
+func (e *UnknownORFEntry) Serialize() ([]byte, error) {
+	return e.Value, nil
 }
 
-func (p *PathAttributeExtendedCommunities) DecodeFromBytes(data []byte) error {
-	p.PathAttribute.DecodeFromBytes(data)
+func (e *UnknownORFEntry) String() string {
+	return fmt.Sprintf("%x", e.Value)
+}
 
-	value := p.PathAttribute.Value
-	for len(value) >= 8 {
-		e := parseExtended(value)
-		p.Value = append(p.Value, e)
-		value = value[8:]
+type RouteRefreshORF struct {
+	Type    uint8
+	Length  uint16
+	Entries []ORFEntryInterface
+}
+
+func (o *RouteRefreshORF) decodeFromBytes(afi uint16, data []byte) error {
+	if len(data) < 3 {
+		return fmt.Errorf("Not all RouteRefresh ORF bytes available")
+	}
+	o.Type = data[0]
+	o.Length = binary.BigEndian.Uint16(data[1:3])
+	data = data[3:]
+	if len(data) < int(o.Length) {
+		return fmt.Errorf("Not all RouteRefresh ORF bytes available")
+	}
+	data = data[:o.Length]
+	for len(data) > 0 {
+		var e ORFEntryInterface
+		switch o.Type {
+		case ORF_TYPE_ADDRESS_PREFIX, ORF_TYPE_ADDRESS_PREFIX_CISCO:
+			e = NewAddressPrefixORFEntry(afi)
+		default:
+			e = &UnknownORFEntry{}
+		}
+		err := e.DecodeFromBytes(data)
+		if err != nil {
+			return err
+		}
+		o.Entries = append(o.Entries, e)
+		data = data[e.Len():]
 	}
 	return nil
 }
 
-type PathAttributeAs4Path struct {
-	PathAttribute
-	Value []AsPathParam
-	DefaultAsPath
-}
+func (o *RouteRefreshORF) Len() int { return 3 + int(o.Length) }
 
-func (p *PathAttributeAs4Path) DecodeFromBytes(data []byte) error {
-	p.PathAttribute.DecodeFromBytes(data)
-	p.Value = p.DefaultAsPath.decodeAs4path(p.PathAttribute.Value)
-	return nil
+func (o *RouteRefreshORF) Serialize() ([]byte, error) {
+	buf := make([]byte, 3)
+	buf[0] = o.Type
+	for _, e := range o.Entries {
+		b, err := e.Serialize()
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, b...)
+	}
+	if len(buf)-3 > math.MaxUint16 {
+		return nil, fmt.Errorf("too many ORF entries")
+	}
+	o.Length = uint16(len(buf) - 3)
+	binary.BigEndian.PutUint16(buf[1:3], o.Length)
+	return buf, nil
 }
 
-type PathAttributeAs4Aggregator struct {
-	PathAttribute
-	Value PathAttributeAggregatorParam
+func (o *RouteRefreshORF) String() string {
+	s := make([]string, len(o.Entries))
+	for i, e := range o.Entries {
+		s[i] = e.String()
+	}
+	return fmt.Sprintf("type %d [%s]", o.Type, strings.Join(s, ", "))
 }
 
-func (p *PathAttributeAs4Aggregator) DecodeFromBytes(data []byte) error {
-	p.PathAttribute.DecodeFromBytes(data)
+type BGPRouteRefresh struct {
+	AFI           uint16
+	Demarcation   uint8
+	SAFI          uint8
+	WhenToRefresh uint8
+	ORFs          []RouteRefreshORF
+}
 
-	p.Value.AS = binary.BigEndian.Uint32(p.PathAttribute.Value[0:4])
-	p.Value.Address = p.PathAttribute.Value[4:]
+func (msg *BGPRouteRefresh) DecodeFromBytes(data []byte) error {
+	if len(data) < 4 {
+		return fmt.Errorf("Not all RouteRefresh bytes available")
+	}
+	msg.AFI = binary.BigEndian.Uint16(data[0:2])
+	msg.Demarcation = data[2]
+	msg.SAFI = data[3]
+	data = data[4:]
+	if len(data) == 0 {
+		return nil
+	}
+	msg.WhenToRefresh = data[0]
+	data = data[1:]
+	for len(data) > 0 {
+		o := RouteRefreshORF{}
+		err := o.decodeFromBytes(msg.AFI, data)
+		if err != nil {
+			return err
+		}
+		msg.ORFs = append(msg.ORFs, o)
+		data = data[o.Len():]
+	}
 	return nil
 }
 
-type PathAttributeUnknown struct {
-	PathAttribute
+func (msg *BGPRouteRefresh) Serialize() ([]byte, error) {
+	buf := make([]byte, 4)
+	binary.BigEndian.PutUint16(buf[0:2], msg.AFI)
+	buf[2] = msg.Demarcation
+	buf[3] = msg.SAFI
+	if len(msg.ORFs) == 0 {
+		return buf, nil
+	}
+	when := msg.WhenToRefresh
+	if when == 0 {
+		when = ORF_WHEN_TO_REFRESH_IMMEDIATE
+	}
+	buf = append(buf, when)
+	for i := range msg.ORFs {
+		b, err := msg.ORFs[i].Serialize()
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, b...)
+	}
+	return buf, nil
 }
 
-func getPathAttribute(data []byte) PathAttributeInterface {
-	switch data[1] {
-	case BGP_ATTR_TYPE_ORIGIN:
-		return &PathAttributeOrigin{}
-	case BGP_ATTR_TYPE_AS_PATH:
-		return &PathAttributeAsPath{}
-	case BGP_ATTR_TYPE_NEXT_HOP:
-		return &PathAttributeNextHop{}
-	case BGP_ATTR_TYPE_MULTI_EXIT_DISC:
-		return &PathAttributeMultiExitDisc{}
-	case BGP_ATTR_TYPE_LOCAL_PREF:
-		return &PathAttributeLocalPref{}
-	case BGP_ATTR_TYPE_ATOMIC_AGGREGATE:
-		return &PathAttributeAtomicAggregate{}
-	case BGP_ATTR_TYPE_AGGREGATOR:
-		return &PathAttributeAggregator{}
-	case BGP_ATTR_TYPE_COMMUNITIES:
-		return &PathAttributeCommunities{}
-	case BGP_ATTR_TYPE_ORIGINATOR_ID:
-		return &PathAttributeOriginatorId{}
-	case BGP_ATTR_TYPE_CLUSTER_LIST:
-		return &PathAttributeClusterList{}
-	case BGP_ATTR_TYPE_MP_REACH_NLRI:
-		return &PathAttributeMpReachNLRI{}
-	case BGP_ATTR_TYPE_MP_UNREACH_NLRI:
-		return &PathAttributeMpUnreachNLRI{}
-	case BGP_ATTR_TYPE_EXTENDED_COMMUNITIES:
-		return &PathAttributeExtendedCommunities{}
-	case BGP_ATTR_TYPE_AS4_PATH:
-		return &PathAttributeAs4Path{}
-	case BGP_ATTR_TYPE_AS4_AGGREGATOR:
-		return &PathAttributeAs4Aggregator{}
+func (msg *BGPRouteRefresh) String() string {
+	buf := bytes.NewBuffer(nil)
+	buf.WriteString("ROUTE_REFRESH: " + routeFamilyName(msg.AFI, msg.SAFI))
+	if msg.Demarcation != 0 {
+		buf.WriteString(fmt.Sprintf(", subtype %d", msg.Demarcation))
 	}
-	return &PathAttributeUnknown{}
+	for i := range msg.ORFs {
+		buf.WriteString(", orf " + msg.ORFs[i].String())
+	}
+	return buf.String()
 }
 
-type NLRInfo struct {
-	IPAddrPrefix
+// routeRefreshORFJSON lists the entries of an ORF for reading, Raw
+// carries them in hex for decoding.
+type routeRefreshORFJSON struct {
+	Type    uint8    `json:"type"`
+	Entries []string `json:"entries"`
+	Raw     string   `json:"raw"`
 }
 
-type BGPUpdate struct {
-	WithdrawnRoutesLen    uint16
-	WithdrawnRoutes       []WithdrawnRoute
-	TotalPathAttributeLen uint16
-	PathAttributes        []PathAttributeInterface
-	NLRI                  []NLRInfo
+type bgpRouteRefreshJSON struct {
+	familyJSON
+	Subtype       uint8                 `json:"subtype,omitempty"`
+	WhenToRefresh uint8                 `json:"when_to_refresh,omitempty"`
+	ORFs          []routeRefreshORFJSON `json:"orfs,omitempty"`
 }
 
-func (msg *BGPUpdate) DecodeFromBytes(data []byte) error {
-	msg.WithdrawnRoutesLen = binary.BigEndian.Uint16(data[0:2])
-	data = data[2:]
-	for routelen := msg.WithdrawnRoutesLen; routelen > 0; {
-		w := WithdrawnRoute{}
-		w.DecodeFromBytes(data)
-		routelen -= uint16(w.Len())
-		data = data[w.Len():]
-		msg.WithdrawnRoutes = append(msg.WithdrawnRoutes, w)
+func (msg *BGPRouteRefresh) MarshalJSON() ([]byte, error) {
+	j := bgpRouteRefreshJSON{
+		familyJSON:    newFamilyJSON(msg.AFI, msg.SAFI),
+		Subtype:       msg.Demarcation,
+		WhenToRefresh: msg.WhenToRefresh,
 	}
-	msg.TotalPathAttributeLen = binary.BigEndian.Uint16(data[0:2])
-	data = data[2:]
-	for pathlen := msg.TotalPathAttributeLen; pathlen > 0; {
-		p := getPathAttribute(data)
-		p.DecodeFromBytes(data)
-		pathlen -= uint16(p.Len())
-		data = data[p.Len():]
-		msg.PathAttributes = append(msg.PathAttributes, p)
+	for i := range msg.ORFs {
+		o := &msg.ORFs[i]
+		b, err := o.Serialize()
+		if err != nil {
+			return nil, err
+		}
+		entries := make([]string, len(o.Entries))
+		for k, e := range o.Entries {
+			entries[k] = e.String()
+		}
+		j.ORFs = append(j.ORFs, routeRefreshORFJSON{o.Type, entries, fmt.Sprintf("%x", b[3:])})
 	}
+	return json.Marshal(j)
+}
 
-	for restlen := len(data); restlen > 0; {
-		n := NLRInfo{}
-		n.DecodeFromBytes(data)
-		restlen -= n.Len()
-		data = data[n.Len():]
-		msg.NLRI = append(msg.NLRI, n)
+func (msg *BGPRouteRefresh) UnmarshalJSON(data []byte) error {
+	var j bgpRouteRefreshJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	*msg = BGPRouteRefresh{
+		AFI:           j.AFI,
+		Demarcation:   j.Subtype,
+		SAFI:          j.SAFI,
+		WhenToRefresh: j.WhenToRefresh,
+	}
+	for _, oj := range j.ORFs {
+		entries, err := hex.DecodeString(oj.Raw)
+		if err != nil {
+			return err
+		}
+		if len(entries) > math.MaxUint16 {
+			return fmt.Errorf("too many ORF entries")
+		}
+		buf := []byte{oj.Type, 0, 0}
+		binary.BigEndian.PutUint16(buf[1:3], uint16(len(entries)))
+		o := RouteRefreshORF{}
+		if err := o.decodeFromBytes(msg.AFI, append(buf, entries...)); err != nil {
+			return err
+		}
+		msg.ORFs = append(msg.ORFs, o)
 	}
-
 	return nil
 }
 
-type BGPNotification struct {
-	ErrorCode    uint8
-	ErrorSubcode uint8
-	Data         []byte
+// PrefixORFList holds the Address Prefix ORF entries a peer pushed to
+// us for one address family. Routes advertised to that peer should be
+// checked with Permit before they are sent.
+type PrefixORFList struct {
+	AFI     uint16
+	SAFI    uint8
+	Entries []*AddressPrefixORFEntry
 }
 
-func (msg *BGPNotification) DecodeFromBytes(data []byte) error {
-	if len(data) < 2 {
-		return fmt.Errorf("Not all Notificaiton bytes available")
-	}
-	msg.ErrorCode = data[0]
-	msg.ErrorSubcode = data[1]
-	if len(data) > 2 {
-		msg.Data = data[2:]
-	}
-	return nil
+func NewPrefixORFList(afi uint16, safi uint8) *PrefixORFList {
+	return &PrefixORFList{AFI: afi, SAFI: safi}
 }
 
-type BGPKeepAlive struct {
+// Update applies the add, remove and remove-all actions carried in a
+// ROUTE-REFRESH message. Messages for other families are ignored.
+func (l *PrefixORFList) Update(msg *BGPRouteRefresh) {
+	if msg.AFI != l.AFI || msg.SAFI != l.SAFI {
+		return
+	}
+	for _, o := range msg.ORFs {
+		if o.Type != ORF_TYPE_ADDRESS_PREFIX && o.Type != ORF_TYPE_ADDRESS_PREFIX_CISCO {
+			continue
+		}
+		for _, i := range o.Entries {
+			e, ok := i.(*AddressPrefixORFEntry)
+			if !ok {
+				continue
+			}
+			switch e.Action {
+			case ORF_ACTION_ADD:
+				l.remove(e)
+				l.add(e)
+			case ORF_ACTION_REMOVE:
+				l.remove(e)
+			case ORF_ACTION_REMOVE_ALL:
+				l.Entries = nil
+			}
+		}
+	}
 }
 
-func (msg *BGPKeepAlive) DecodeFromBytes(data []byte) error {
-	return nil
+func (l *PrefixORFList) add(e *AddressPrefixORFEntry) {
+	i := 0
+	for i < len(l.Entries) && l.Entries[i].Sequence <= e.Sequence {
+		i++
+	}
+	l.Entries = append(l.Entries, nil)
+	copy(l.Entries[i+1:], l.Entries[i:])
+	l.Entries[i] = e
 }
 
-type BGPRouteRefresh struct {
-	AFI         uint16
-	Demarcation uint8
-	SAFI        uint8
+func (l *PrefixORFList) remove(e *AddressPrefixORFEntry) {
+	for i, o := range l.Entries {
+		if o.sameAs(e) {
+			l.Entries = append(l.Entries[:i], l.Entries[i+1:]...)
+			return
+		}
+	}
 }
 
-func (msg *BGPRouteRefresh) DecodeFromBytes(data []byte) error {
-	if len(data) < 4 {
-		return fmt.Errorf("Not all RouteRefresh bytes available")
+// Permit reports whether a prefix may be advertised to the peer. The
+// first entry in sequence order that matches decides; once a peer has
+// installed entries, prefixes matching none of them are denied.
+func (l *PrefixORFList) Permit(prefix net.IP, length uint8) bool {
+	if len(l.Entries) == 0 {
+		return true
 	}
-	msg.AFI = binary.BigEndian.Uint16(data[0:2])
-	msg.Demarcation = data[2]
-	msg.SAFI = data[3]
-	return nil
+	for _, e := range l.Entries {
+		if e.match(prefix, length) {
+			return e.Match == ORF_MATCH_PERMIT
+		}
+	}
+	return false
 }
 
 type BGPBody interface {
 	DecodeFromBytes([]byte) error
+	String() string
 }
 
 type BGPHeader struct {
@@ -1212,17 +10548,111 @@ func (msg *BGPHeader) DecodeFromBytes(data []byte) error {
 	return nil
 }
 
-type BGPMessage struct {
-	Header BGPHeader
-	Body   BGPBody
+type BGPMessage struct {
+	Header BGPHeader
+	Body   BGPBody
+}
+
+func (msg *BGPMessage) String() string {
+	if msg.Body == nil {
+		return fmt.Sprintf("BGP message type %d", msg.Header.Type)
+	}
+	return msg.Body.String()
+}
+
+// JSON_SCHEMA_VERSION is carried in the JSON form of BGP and BMP
+// messages. It changes whenever a field is removed or changes meaning.
+const JSON_SCHEMA_VERSION = 1
+
+var bgpMessageTypeNames = map[uint8]string{
+	BGP_MSG_OPEN:          "OPEN",
+	BGP_MSG_UPDATE:        "UPDATE",
+	BGP_MSG_NOTIFICATION:  "NOTIFICATION",
+	BGP_MSG_KEEPALIVE:     "KEEPALIVE",
+	BGP_MSG_ROUTE_REFRESH: "ROUTE_REFRESH",
+}
+
+type bgpMessageJSON struct {
+	Version int             `json:"version"`
+	Type    string          `json:"type"`
+	Body    json.RawMessage `json:"body"`
+}
+
+// MarshalJSON writes the message in the JSON schema whose version is
+// JSON_SCHEMA_VERSION. Addresses and prefixes are written as strings,
+// and path attributes and capabilities are objects tagged with their
+// name and code.
+func (msg *BGPMessage) MarshalJSON() ([]byte, error) {
+	name, ok := bgpMessageTypeNames[msg.Header.Type]
+	if !ok || msg.Body == nil {
+		return nil, fmt.Errorf("unknown BGP message type %d", msg.Header.Type)
+	}
+	body, err := json.Marshal(msg.Body)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(bgpMessageJSON{JSON_SCHEMA_VERSION, name, body})
+}
+
+// UnmarshalJSON reads a message written by MarshalJSON. The header
+// only gets its type, since the length depends on how the message is
+// encoded.
+func (msg *BGPMessage) UnmarshalJSON(data []byte) error {
+	var j bgpMessageJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if j.Version != JSON_SCHEMA_VERSION {
+		return fmt.Errorf("unsupported JSON schema version %d", j.Version)
+	}
+	t, err := nameToCode(bgpMessageTypeNames, j.Type)
+	if err != nil {
+		return err
+	}
+	var body BGPBody
+	switch t {
+	case BGP_MSG_OPEN:
+		body = &BGPOpen{}
+	case BGP_MSG_UPDATE:
+		body = &BGPUpdate{}
+	case BGP_MSG_NOTIFICATION:
+		body = &BGPNotification{}
+	case BGP_MSG_KEEPALIVE:
+		body = &BGPKeepAlive{}
+	case BGP_MSG_ROUTE_REFRESH:
+		body = &BGPRouteRefresh{}
+	default:
+		return fmt.Errorf("unknown BGP message type %d", t)
+	}
+	if len(j.Body) > 0 {
+		if err := json.Unmarshal(j.Body, body); err != nil {
+			return err
+		}
+	}
+	msg.Header = BGPHeader{Type: t}
+	msg.Body = body
+	return nil
+}
+
+func ParseBGPMessage(data []byte) (*BGPMessage, error) {
+	return parseBGPMessage(data, nil)
 }
 
-func ParseBGPMessage(data []byte) (*BGPMessage, error) {
+// ParseBGPMessage decodes a message received from the peer the features
+// were negotiated with.
+func (f *SessionFeatures) ParseBGPMessage(data []byte) (*BGPMessage, error) {
+	return parseBGPMessage(data, f)
+}
+
+func parseBGPMessage(data []byte, f *SessionFeatures) (*BGPMessage, error) {
 	msg := &BGPMessage{}
 	err := msg.Header.DecodeFromBytes(data)
 	if err != nil {
 		return nil, err
 	}
+	if int(msg.Header.Len) > f.maxMessageLength() {
+		return nil, fmt.Errorf("BGP message length %d exceeds %d", msg.Header.Len, f.maxMessageLength())
+	}
 	data = data[19:msg.Header.Len]
 	switch msg.Header.Type {
 	case BGP_MSG_OPEN:
@@ -1235,8 +10665,14 @@ func ParseBGPMessage(data []byte) (*BGPMessage, error) {
 		msg.Body = &BGPKeepAlive{}
 	case BGP_MSG_ROUTE_REFRESH:
 		msg.Body = &BGPRouteRefresh{}
+	default:
+		return nil, fmt.Errorf("unknown BGP message type %d", msg.Header.Type)
+	}
+	if u, ok := msg.Body.(*BGPUpdate); ok {
+		err = u.decodeFromBytes(data, f)
+	} else {
+		err = msg.Body.DecodeFromBytes(data)
 	}
-	err = msg.Body.DecodeFromBytes(data)
 	if err != nil {
 		return nil, err
 	}
@@ -1252,7 +10688,8 @@ type BMPHeader struct {
 }
 
 const (
-	BMP_HEADER_SIZE = 6
+	BMP_HEADER_SIZE      = 6
+	BMP_PEER_HEADER_SIZE = 42
 )
 
 func (msg *BMPHeader) DecodeFromBytes(data []byte) error {
@@ -1270,8 +10707,11 @@ func (msg *BMPHeader) Len() int {
 }
 
 type BMPPeerHeader struct {
-	PeerType          uint8
-	IsPostPolicy      bool
+	PeerType     uint8
+	IsPostPolicy bool
+	// IsLegacyASPath is the A flag: the AS_PATH of Route Monitoring
+	// messages has 2-octet AS numbers.
+	IsLegacyASPath    bool
 	PeerDistinguisher uint64
 	PeerAddress       net.IP
 	PeerAS            uint32
@@ -1281,21 +10721,24 @@ type BMPPeerHeader struct {
 }
 
 func (msg *BMPPeerHeader) DecodeFromBytes(data []byte) error {
-	data = data[6:]
+	if len(data) < BMP_PEER_HEADER_SIZE {
+		return fmt.Errorf("Not all BMP per-peer header bytes available")
+	}
 
 	msg.PeerType = data[0]
 	flags := data[1]
 	msg.flags = flags
-	if flags&1<<6 == 1 {
+	if flags&(1<<6) != 0 {
 		msg.IsPostPolicy = true
 	} else {
 		msg.IsPostPolicy = false
 	}
+	msg.IsLegacyASPath = flags&(1<<5) != 0
 	msg.PeerDistinguisher = binary.BigEndian.Uint64(data[2:10])
-	if flags&1<<7 == 1 {
+	if flags&(1<<7) != 0 {
 		msg.PeerAddress = data[10:26]
 	} else {
-		msg.PeerAddress = data[10:14]
+		msg.PeerAddress = data[22:26]
 	}
 	msg.PeerAS = binary.BigEndian.Uint32(data[26:30])
 	msg.PeerBGPID = data[30:34]
@@ -1307,12 +10750,95 @@ func (msg *BMPPeerHeader) DecodeFromBytes(data []byte) error {
 	return nil
 }
 
+func (msg *BMPPeerHeader) String() string {
+	buf := bytes.NewBuffer(nil)
+	buf.WriteString(fmt.Sprintf("peer %s AS %d ID %s", msg.PeerAddress, msg.PeerAS, msg.PeerBGPID))
+	if msg.PeerDistinguisher != 0 {
+		buf.WriteString(fmt.Sprintf(" distinguisher %d", msg.PeerDistinguisher))
+	}
+	if msg.IsPostPolicy {
+		buf.WriteString(" post-policy")
+	}
+	if msg.IsLegacyASPath {
+		buf.WriteString(" legacy-as-path")
+	}
+	return buf.String()
+}
+
+type bmpPeerHeaderJSON struct {
+	Type          uint8   `json:"type"`
+	Distinguisher uint64  `json:"distinguisher,string"`
+	Address       string  `json:"address"`
+	AS            uint32  `json:"as"`
+	BGPID         string  `json:"bgp_id"`
+	PostPolicy    bool    `json:"post_policy"`
+	LegacyASPath  bool    `json:"legacy_as_path"`
+	Timestamp     float64 `json:"timestamp"`
+}
+
+func (msg *BMPPeerHeader) MarshalJSON() ([]byte, error) {
+	return json.Marshal(bmpPeerHeaderJSON{
+		Type:          msg.PeerType,
+		Distinguisher: msg.PeerDistinguisher,
+		Address:       msg.PeerAddress.String(),
+		AS:            msg.PeerAS,
+		BGPID:         msg.PeerBGPID.String(),
+		PostPolicy:    msg.IsPostPolicy,
+		LegacyASPath:  msg.IsLegacyASPath,
+		Timestamp:     msg.Timestamp,
+	})
+}
+
+func (msg *BMPPeerHeader) UnmarshalJSON(data []byte) error {
+	var j bmpPeerHeaderJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	addr, err := parseJSONAddress(j.Address)
+	if err != nil {
+		return err
+	}
+	id := net.ParseIP(j.BGPID).To4()
+	if id == nil {
+		return fmt.Errorf("invalid BGP identifier %q", j.BGPID)
+	}
+	*msg = BMPPeerHeader{
+		PeerType:          j.Type,
+		IsPostPolicy:      j.PostPolicy,
+		IsLegacyASPath:    j.LegacyASPath,
+		PeerDistinguisher: j.Distinguisher,
+		PeerAddress:       addr,
+		PeerAS:            j.AS,
+		PeerBGPID:         id,
+		Timestamp:         j.Timestamp,
+	}
+	if len(addr) == net.IPv6len {
+		msg.flags |= 1 << 7
+	}
+	if j.PostPolicy {
+		msg.flags |= 1 << 6
+	}
+	if j.LegacyASPath {
+		msg.flags |= 1 << 5
+	}
+	return nil
+}
+
 type BMPRouteMonitoring struct {
 	BGPUpdate *BGPMessage
 }
 
 func (body *BMPRouteMonitoring) ParseBody(msg *BMPMessage, data []byte) error {
-	update, err := ParseBGPMessage(data)
+	// RFC 7854 4.2: the A flag, not the OPEN messages of the Peer Up
+	// notification, tells the AS number size of the AS_PATH
+	f := &SessionFeatures{ExtendedMessage: true}
+	if msg.features != nil {
+		c := *msg.features
+		f = &c
+	}
+	f.FourOctetAS = !msg.PeerHeader.IsLegacyASPath
+	update, err := f.ParseBGPMessage(data)
+
 	if err != nil {
 		return err
 	}
@@ -1320,6 +10846,27 @@ func (body *BMPRouteMonitoring) ParseBody(msg *BMPMessage, data []byte) error {
 	return nil
 }
 
+func (body *BMPRouteMonitoring) String() string {
+	return fmt.Sprintf("ROUTE_MONITORING: %s", body.BGPUpdate)
+}
+
+type bmpRouteMonitoringJSON struct {
+	Update *BGPMessage `json:"update"`
+}
+
+func (body *BMPRouteMonitoring) MarshalJSON() ([]byte, error) {
+	return json.Marshal(bmpRouteMonitoringJSON{body.BGPUpdate})
+}
+
+func (body *BMPRouteMonitoring) UnmarshalJSON(data []byte) error {
+	var j bmpRouteMonitoringJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	body.BGPUpdate = j.Update
+	return nil
+}
+
 const (
 	BMP_STAT_TYPE_REJECTED = iota
 	BMP_STAT_TYPE_DUPLICATE_PREFIX
@@ -1342,6 +10889,64 @@ type BMPStatisticsReport struct {
 	Stats []BMPStatsTLV
 }
 
+var bmpStatTypeNames = map[uint16]string{
+	BMP_STAT_TYPE_REJECTED:                            "rejected",
+	BMP_STAT_TYPE_DUPLICATE_PREFIX:                    "duplicate-prefix",
+	BMP_STAT_TYPE_DUPLICATE_WITHDRAW:                  "duplicate-withdraw",
+	BMP_STAT_TYPE_INV_UPDATE_DUE_TO_CLUSTER_LIST_LOOP: "cluster-list-loop",
+	BMP_STAT_TYPE_INV_UPDATE_DUE_TO_AS_PATH_LOOP:      "as-path-loop",
+	BMP_STAT_TYPE_INV_UPDATE_DUE_TO_ORIGINATOR_ID:     "originator-id-loop",
+	BMP_STAT_TYPE_INV_UPDATE_DUE_TO_AS_CONFED_LOOP:    "as-confed-loop",
+	BMP_STAT_TYPE_ADJ_RIB_IN:                          "adj-rib-in",
+	BMP_STAT_TYPE_LOC_RIB:                             "loc-rib",
+}
+
+func (body *BMPStatisticsReport) String() string {
+	s := make([]string, len(body.Stats))
+	for i, t := range body.Stats {
+		name, ok := bmpStatTypeNames[t.Type]
+		if !ok {
+			name = fmt.Sprintf("type-%d", t.Type)
+		}
+		s[i] = fmt.Sprintf("%s %d", name, t.Value)
+	}
+	return "STATISTICS_REPORT: " + strings.Join(s, ", ")
+}
+
+type bmpStatJSON struct {
+	Type  uint16 `json:"type"`
+	Name  string `json:"name"`
+	Value uint64 `json:"value"`
+}
+
+type bmpStatisticsReportJSON struct {
+	Stats []bmpStatJSON `json:"stats"`
+}
+
+func (body *BMPStatisticsReport) MarshalJSON() ([]byte, error) {
+	j := bmpStatisticsReportJSON{make([]bmpStatJSON, len(body.Stats))}
+	for i, t := range body.Stats {
+		j.Stats[i] = bmpStatJSON{t.Type, bmpTLVName(bmpStatTypeNames, t.Type), t.Value}
+	}
+	return json.Marshal(j)
+}
+
+func (body *BMPStatisticsReport) UnmarshalJSON(data []byte) error {
+	var j bmpStatisticsReportJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	body.Stats = nil
+	for _, t := range j.Stats {
+		s := BMPStatsTLV{Type: t.Type, Length: 4, Value: t.Value}
+		if t.Type == BMP_STAT_TYPE_ADJ_RIB_IN || t.Type == BMP_STAT_TYPE_LOC_RIB {
+			s.Length = 8
+		}
+		body.Stats = append(body.Stats, s)
+	}
+	return nil
+}
+
 const (
 	BMP_PEER_DOWN_REASON_UNKNOWN = iota
 	BMP_PEER_DOWN_REASON_LOCAL_BGP_NOTIFICATION
@@ -1371,6 +10976,60 @@ func (body *BMPPeerDownNotification) ParseBody(msg *BMPMessage, data []byte) err
 	return nil
 }
 
+var bmpPeerDownReasonNames = map[uint8]string{
+	BMP_PEER_DOWN_REASON_UNKNOWN:                 "unknown",
+	BMP_PEER_DOWN_REASON_LOCAL_BGP_NOTIFICATION:  "local notification",
+	BMP_PEER_DOWN_REASON_LOCAL_NO_NOTIFICATION:   "local without notification",
+	BMP_PEER_DOWN_REASON_REMOTE_BGP_NOTIFICATION: "remote notification",
+	BMP_PEER_DOWN_REASON_REMOTE_NO_NOTIFICATION:  "remote without notification",
+}
+
+func (body *BMPPeerDownNotification) String() string {
+	reason, ok := bmpPeerDownReasonNames[body.Reason]
+	if !ok {
+		reason = strconv.Itoa(int(body.Reason))
+	}
+	switch {
+	case body.BGPNotification != nil:
+		return fmt.Sprintf("PEER_DOWN: %s, %s", reason, body.BGPNotification)
+	case len(body.Data) > 0:
+		return fmt.Sprintf("PEER_DOWN: %s, data %x", reason, body.Data)
+	}
+	return "PEER_DOWN: " + reason
+}
+
+type bmpPeerDownJSON struct {
+	Reason       uint8       `json:"reason"`
+	ReasonName   string      `json:"reason_name"`
+	Notification *BGPMessage `json:"notification,omitempty"`
+	Data         string      `json:"data,omitempty"`
+}
+
+func (body *BMPPeerDownNotification) MarshalJSON() ([]byte, error) {
+	return json.Marshal(bmpPeerDownJSON{
+		Reason:       body.Reason,
+		ReasonName:   codeToName(bmpPeerDownReasonNames, body.Reason),
+		Notification: body.BGPNotification,
+		Data:         fmt.Sprintf("%x", body.Data),
+	})
+}
+
+func (body *BMPPeerDownNotification) UnmarshalJSON(data []byte) error {
+	var j bmpPeerDownJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	*body = BMPPeerDownNotification{Reason: j.Reason, BGPNotification: j.Notification}
+	if j.Data != "" {
+		d, err := hex.DecodeString(j.Data)
+		if err != nil {
+			return err
+		}
+		body.Data = d
+	}
+	return nil
+}
+
 type BMPPeerUpNotification struct {
 	LocalAddress    net.IP
 	LocalPort       uint16
@@ -1380,10 +11039,10 @@ type BMPPeerUpNotification struct {
 }
 
 func (body *BMPPeerUpNotification) ParseBody(msg *BMPMessage, data []byte) error {
-	if msg.PeerHeader.flags&1<<7 == 1 {
+	if msg.PeerHeader.flags&(1<<7) != 0 {
 		body.LocalAddress = data[:16]
 	} else {
-		body.LocalAddress = data[:4]
+		body.LocalAddress = data[12:16]
 	}
 
 	body.LocalPort = binary.BigEndian.Uint16(data[16:18])
@@ -1403,6 +11062,65 @@ func (body *BMPPeerUpNotification) ParseBody(msg *BMPMessage, data []byte) error
 	return nil
 }
 
+func (body *BMPPeerUpNotification) String() string {
+	return fmt.Sprintf("PEER_UP: local %s port %d, remote port %d, sent %s, received %s",
+		body.LocalAddress, body.LocalPort, body.RemotePort, body.SentOpenMsg, body.ReceivedOpenMsg)
+}
+
+type bmpPeerUpJSON struct {
+	LocalAddress string      `json:"local_address"`
+	LocalPort    uint16      `json:"local_port"`
+	RemotePort   uint16      `json:"remote_port"`
+	SentOpen     *BGPMessage `json:"sent_open"`
+	ReceivedOpen *BGPMessage `json:"received_open"`
+}
+
+func (body *BMPPeerUpNotification) MarshalJSON() ([]byte, error) {
+	return json.Marshal(bmpPeerUpJSON{
+		LocalAddress: body.LocalAddress.String(),
+		LocalPort:    body.LocalPort,
+		RemotePort:   body.RemotePort,
+		SentOpen:     body.SentOpenMsg,
+		ReceivedOpen: body.ReceivedOpenMsg,
+	})
+}
+
+func (body *BMPPeerUpNotification) UnmarshalJSON(data []byte) error {
+	var j bmpPeerUpJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	addr, err := parseJSONAddress(j.LocalAddress)
+	if err != nil {
+		return err
+	}
+	*body = BMPPeerUpNotification{
+		LocalAddress:    addr,
+		LocalPort:       j.LocalPort,
+		RemotePort:      j.RemotePort,
+		SentOpenMsg:     j.SentOpen,
+		ReceivedOpenMsg: j.ReceivedOpen,
+	}
+	return nil
+}
+
+// Features returns what the monitored router and its peer negotiated,
+// from the point of view of the monitored router.
+func (body *BMPPeerUpNotification) Features() *SessionFeatures {
+	if body.SentOpenMsg == nil || body.ReceivedOpenMsg == nil {
+		return nil
+	}
+	local, ok := body.SentOpenMsg.Body.(*BGPOpen)
+	if !ok {
+		return nil
+	}
+	remote, ok := body.ReceivedOpenMsg.Body.(*BGPOpen)
+	if !ok {
+		return nil
+	}
+	return NegotiateSessionFeatures(local, remote)
+}
+
 func (body *BMPStatisticsReport) ParseBody(msg *BMPMessage, data []byte) error {
 	_ = binary.BigEndian.Uint32(data[0:4])
 	data = data[4:]
@@ -1428,6 +11146,12 @@ type BMPTLV struct {
 	Value  []byte
 }
 
+const (
+	BMP_INIT_TLV_TYPE_STRING = iota
+	BMP_INIT_TLV_TYPE_SYS_DESCR
+	BMP_INIT_TLV_TYPE_SYS_NAME
+)
+
 type BMPInitiation struct {
 	Info []BMPTLV
 }
@@ -1445,6 +11169,92 @@ func (body *BMPInitiation) ParseBody(msg *BMPMessage, data []byte) error {
 	return nil
 }
 
+func bmpTLVName(names map[uint16]string, t uint16) string {
+	if name, ok := names[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("type-%d", t)
+}
+
+func bmpTLVsString(info []BMPTLV, names map[uint16]string) string {
+	s := make([]string, len(info))
+	for i, tlv := range info {
+		s[i] = fmt.Sprintf("[%s:%s]", bmpTLVName(names, tlv.Type), tlv.Value)
+	}
+	return strings.Join(s, "")
+}
+
+// bmpTLVJSON is the JSON form of the Initiation and Termination TLVs,
+// whose values are text.
+type bmpTLVJSON struct {
+	Type  uint16 `json:"type"`
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+func bmpTLVsJSON(info []BMPTLV, names map[uint16]string) []bmpTLVJSON {
+	j := make([]bmpTLVJSON, len(info))
+	for i, tlv := range info {
+		j[i] = bmpTLVJSON{tlv.Type, bmpTLVName(names, tlv.Type), string(tlv.Value)}
+	}
+	return j
+}
+
+func bmpTLVsFromJSON(j []bmpTLVJSON) ([]BMPTLV, error) {
+	var info []BMPTLV
+	for _, t := range j {
+		if len(t.Value) > math.MaxUint16 {
+			return nil, fmt.Errorf("BMP TLV %d is too long", t.Type)
+		}
+		info = append(info, BMPTLV{t.Type, uint16(len(t.Value)), []byte(t.Value)})
+	}
+	return info, nil
+}
+
+var bmpInitiationTLVNames = map[uint16]string{
+	BMP_INIT_TLV_TYPE_STRING:    "string",
+	BMP_INIT_TLV_TYPE_SYS_DESCR: "sysDescr",
+	BMP_INIT_TLV_TYPE_SYS_NAME:  "sysName",
+}
+
+func (body *BMPInitiation) String() string {
+	return "INITIATION: " + bmpTLVsString(body.Info, bmpInitiationTLVNames)
+}
+
+type bmpInitiationJSON struct {
+	Info []bmpTLVJSON `json:"info"`
+}
+
+func (body *BMPInitiation) MarshalJSON() ([]byte, error) {
+	return json.Marshal(bmpInitiationJSON{bmpTLVsJSON(body.Info, bmpInitiationTLVNames)})
+}
+
+func (body *BMPInitiation) UnmarshalJSON(data []byte) error {
+	var j bmpInitiationJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	info, err := bmpTLVsFromJSON(j.Info)
+	if err != nil {
+		return err
+	}
+	body.Info = info
+	return nil
+}
+
+const (
+	BMP_TERM_TLV_TYPE_STRING = iota
+	BMP_TERM_TLV_TYPE_REASON
+)
+
+const (
+	BMP_TERM_REASON_ADMIN = iota
+	BMP_TERM_REASON_UNSPEC
+	BMP_TERM_REASON_OUT_OF_RESOURCES
+	BMP_TERM_REASON_REDUNDANT
+	BMP_TERM_REASON_PERM_ADMIN
+)
+
 type BMPTermination struct {
 	Info []BMPTLV
 }
@@ -1462,20 +11272,183 @@ func (body *BMPTermination) ParseBody(msg *BMPMessage, data []byte) error {
 	return nil
 }
 
+var bmpTerminationReasonNames = map[uint16]string{
+	BMP_TERM_REASON_ADMIN:            "administratively closed",
+	BMP_TERM_REASON_UNSPEC:           "unspecified",
+	BMP_TERM_REASON_OUT_OF_RESOURCES: "out of resources",
+	BMP_TERM_REASON_REDUNDANT:        "redundant connection",
+	BMP_TERM_REASON_PERM_ADMIN:       "permanently administratively closed",
+}
+
+var bmpTerminationTLVNames = map[uint16]string{
+	BMP_TERM_TLV_TYPE_STRING: "string",
+}
+
+// reason splits the reason TLV off the other ones.
+func (body *BMPTermination) reason() (*uint16, []BMPTLV) {
+	var code *uint16
+	var info []BMPTLV
+	for _, tlv := range body.Info {
+		if tlv.Type == BMP_TERM_TLV_TYPE_REASON && len(tlv.Value) == 2 {
+			c := binary.BigEndian.Uint16(tlv.Value)
+			code = &c
+			continue
+		}
+		info = append(info, tlv)
+	}
+	return code, info
+}
+
+func bmpTerminationReasonName(code uint16) string {
+	if name, ok := bmpTerminationReasonNames[code]; ok {
+		return name
+	}
+	return strconv.Itoa(int(code))
+}
+
+func (body *BMPTermination) String() string {
+	code, info := body.reason()
+	reason := ""
+	if code != nil {
+		reason = fmt.Sprintf("[reason:%s]", bmpTerminationReasonName(*code))
+	}
+	return "TERMINATION: " + reason + bmpTLVsString(info, bmpTerminationTLVNames)
+}
+
+type bmpTerminationJSON struct {
+	Reason     *uint16      `json:"reason,omitempty"`
+	ReasonName string       `json:"reason_name,omitempty"`
+	Info       []bmpTLVJSON `json:"info"`
+}
+
+func (body *BMPTermination) MarshalJSON() ([]byte, error) {
+	code, info := body.reason()
+	j := bmpTerminationJSON{Reason: code, Info: bmpTLVsJSON(info, bmpTerminationTLVNames)}
+	if code != nil {
+		j.ReasonName = bmpTerminationReasonName(*code)
+	}
+	return json.Marshal(j)
+}
+
+func (body *BMPTermination) UnmarshalJSON(data []byte) error {
+	var j bmpTerminationJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	info, err := bmpTLVsFromJSON(j.Info)
+	if err != nil {
+		return err
+	}
+	body.Info = nil
+	if j.Reason != nil {
+		v := binary.BigEndian.AppendUint16(nil, *j.Reason)
+		body.Info = append(body.Info, BMPTLV{BMP_TERM_TLV_TYPE_REASON, 2, v})
+	}
+	body.Info = append(body.Info, info...)
+	return nil
+}
+
 type BMPBody interface {
 	ParseBody(*BMPMessage, []byte) error
+	String() string
 }
 
 type BMPMessage struct {
 	Header     BMPHeader
 	PeerHeader BMPPeerHeader
 	Body       BMPBody
+	features   *SessionFeatures
 }
 
 func (msg *BMPMessage) Len() int {
 	return int(msg.Header.Length)
 }
 
+func (msg *BMPMessage) String() string {
+	if msg.Body == nil {
+		return fmt.Sprintf("BMP message type %d", msg.Header.Type)
+	}
+	if msg.Header.Type == BMP_MSG_INITIATION || msg.Header.Type == BMP_MSG_TERMINATION {
+		return msg.Body.String()
+	}
+	return fmt.Sprintf("[%s] %s", &msg.PeerHeader, msg.Body)
+}
+
+var bmpMessageTypeNames = map[uint8]string{
+	BMP_MSG_ROUTE_MONITORING:       "ROUTE_MONITORING",
+	BMP_MSG_STATISTICS_REPORT:      "STATISTICS_REPORT",
+	BMP_MSG_PEER_DOWN_NOTIFICATION: "PEER_DOWN",
+	BMP_MSG_PEER_UP_NOTIFICATION:   "PEER_UP",
+	BMP_MSG_INITIATION:             "INITIATION",
+	BMP_MSG_TERMINATION:            "TERMINATION",
+}
+
+// bmpMessageJSON has no peer for the Initiation and Termination
+// messages, which carry no per-peer header.
+type bmpMessageJSON struct {
+	Version int             `json:"version"`
+	Type    string          `json:"type"`
+	Peer    *BMPPeerHeader  `json:"peer,omitempty"`
+	Body    json.RawMessage `json:"body"`
+}
+
+// MarshalJSON writes the message in the schema described at
+// BGPMessage.MarshalJSON, which the BGP messages inside use too.
+func (msg *BMPMessage) MarshalJSON() ([]byte, error) {
+	name, ok := bmpMessageTypeNames[msg.Header.Type]
+	if !ok || msg.Body == nil {
+		return nil, fmt.Errorf("unknown BMP message type %d", msg.Header.Type)
+	}
+	body, err := json.Marshal(msg.Body)
+	if err != nil {
+		return nil, err
+	}
+	j := bmpMessageJSON{Version: JSON_SCHEMA_VERSION, Type: name, Body: body}
+	if msg.Header.Type != BMP_MSG_INITIATION && msg.Header.Type != BMP_MSG_TERMINATION {
+		j.Peer = &msg.PeerHeader
+	}
+	return json.Marshal(j)
+}
+
+func (msg *BMPMessage) UnmarshalJSON(data []byte) error {
+	var j bmpMessageJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if j.Version != JSON_SCHEMA_VERSION {
+		return fmt.Errorf("unsupported JSON schema version %d", j.Version)
+	}
+	t, err := nameToCode(bmpMessageTypeNames, j.Type)
+	if err != nil {
+		return err
+	}
+	var body BMPBody
+	switch t {
+	case BMP_MSG_ROUTE_MONITORING:
+		body = &BMPRouteMonitoring{}
+	case BMP_MSG_STATISTICS_REPORT:
+		body = &BMPStatisticsReport{}
+	case BMP_MSG_PEER_DOWN_NOTIFICATION:
+		body = &BMPPeerDownNotification{}
+	case BMP_MSG_PEER_UP_NOTIFICATION:
+		body = &BMPPeerUpNotification{}
+	case BMP_MSG_INITIATION:
+		body = &BMPInitiation{}
+	case BMP_MSG_TERMINATION:
+		body = &BMPTermination{}
+	default:
+		return fmt.Errorf("unknown BMP message type %d", t)
+	}
+	if err := json.Unmarshal(j.Body, body); err != nil {
+		return err
+	}
+	*msg = BMPMessage{Header: BMPHeader{Version: 3, Type: t}, Body: body}
+	if j.Peer != nil {
+		msg.PeerHeader = *j.Peer
+	}
+	return nil
+}
+
 const (
 	BMP_MSG_ROUTE_MONITORING = iota
 	BMP_MSG_STATISTICS_REPORT
@@ -1485,8 +11458,36 @@ const (
 	BMP_MSG_TERMINATION
 )
 
+// BMPSession decodes the messages sent by one monitored router. It
+// remembers the features negotiated by each peer reported in a Peer Up
+// notification and decodes later Route Monitoring messages for that
+// peer accordingly.
+type BMPSession struct {
+	peers map[string]*SessionFeatures
+}
+
+func NewBMPSession() *BMPSession {
+	return &BMPSession{peers: make(map[string]*SessionFeatures)}
+}
+
+func (h *BMPPeerHeader) key() string {
+	return fmt.Sprintf("%d:%s", h.PeerDistinguisher, h.PeerAddress)
+}
+
+func (s *BMPSession) ReadBMPMessage(conn net.Conn) (*BMPMessage, error) {
+	data, err := readBMPMessage(conn)
+	if err != nil {
+		return nil, err
+	}
+	return parseBMPMessage(data, s)
+}
+
+func (s *BMPSession) ParseBMPMessage(data []byte) (*BMPMessage, error) {
+	return parseBMPMessage(data, s)
+}
+
 // move somewhere else
-func ReadBMPMessage(conn net.Conn) (*BMPMessage, error) {
+func readBMPMessage(conn net.Conn) ([]byte, error) {
 	buf := make([]byte, BMP_HEADER_SIZE)
 	for offset := 0; offset < BMP_HEADER_SIZE; {
 		rlen, err := conn.Read(buf[offset:])
@@ -1501,50 +11502,47 @@ func ReadBMPMessage(conn net.Conn) (*BMPMessage, error) {
 	if err != nil {
 		return nil, err
 	}
+	if h.Len() < BMP_HEADER_SIZE {
+		return nil, fmt.Errorf("BMP message length %d is too short", h.Len())
+	}
 
 	data := make([]byte, h.Len())
 	copy(data, buf)
-	data = data[BMP_HEADER_SIZE:]
-	for offset := 0; offset < h.Len()-BMP_HEADER_SIZE; {
+	for offset := BMP_HEADER_SIZE; offset < h.Len(); {
 		rlen, err := conn.Read(data[offset:])
 		if err != nil {
 			return nil, err
 		}
 		offset += rlen
 	}
-	msg := &BMPMessage{Header: h}
-
-	switch msg.Header.Type {
-	case BMP_MSG_ROUTE_MONITORING:
-		msg.Body = &BMPRouteMonitoring{}
-	case BMP_MSG_STATISTICS_REPORT:
-		msg.Body = &BMPStatisticsReport{}
-	case BMP_MSG_PEER_DOWN_NOTIFICATION:
-		msg.Body = &BMPPeerDownNotification{}
-	case BMP_MSG_PEER_UP_NOTIFICATION:
-		msg.Body = &BMPPeerUpNotification{}
-	case BMP_MSG_INITIATION:
-		msg.Body = &BMPInitiation{}
-	case BMP_MSG_TERMINATION:
-		msg.Body = &BMPTermination{}
-	}
-
-	if msg.Header.Type != BMP_MSG_INITIATION && msg.Header.Type != BMP_MSG_INITIATION {
-		msg.PeerHeader.DecodeFromBytes(data)
-		data = data[42:]
-	}
+	return data, nil
+}
 
-	err = msg.Body.ParseBody(msg, data)
+func ReadBMPMessage(conn net.Conn) (*BMPMessage, error) {
+	data, err := readBMPMessage(conn)
 	if err != nil {
 		return nil, err
 	}
-	return msg, nil
+	return parseBMPMessage(data, nil)
 }
 
 func ParseBMPMessage(data []byte) (*BMPMessage, error) {
+	return parseBMPMessage(data, nil)
+}
+
+func parseBMPMessage(data []byte, s *BMPSession) (*BMPMessage, error) {
 	msg := &BMPMessage{}
-	msg.Header.DecodeFromBytes(data)
-	data = data[6:msg.Header.Length]
+	err := msg.Header.DecodeFromBytes(data)
+	if err != nil {
+		return nil, err
+	}
+	if msg.Header.Length < BMP_HEADER_SIZE {
+		return nil, fmt.Errorf("invalid BMP message length %d", msg.Header.Length)
+	}
+	if len(data) < int(msg.Header.Length) {
+		return nil, fmt.Errorf("Not all BMP message bytes available")
+	}
+	data = data[BMP_HEADER_SIZE:msg.Header.Length]
 
 	switch msg.Header.Type {
 	case BMP_MSG_ROUTE_MONITORING:
@@ -1559,16 +11557,33 @@ func ParseBMPMessage(data []byte) (*BMPMessage, error) {
 		msg.Body = &BMPInitiation{}
 	case BMP_MSG_TERMINATION:
 		msg.Body = &BMPTermination{}
+	default:
+		return nil, fmt.Errorf("unknown BMP message type %d", msg.Header.Type)
 	}
 
-	if msg.Header.Type != BMP_MSG_INITIATION && msg.Header.Type != BMP_MSG_INITIATION {
-		msg.PeerHeader.DecodeFromBytes(data)
-		data = data[42:]
+	if msg.Header.Type != BMP_MSG_INITIATION && msg.Header.Type != BMP_MSG_TERMINATION {
+		err = msg.PeerHeader.DecodeFromBytes(data)
+		if err != nil {
+			return nil, err
+		}
+		data = data[BMP_PEER_HEADER_SIZE:]
+		if s != nil {
+			msg.features = s.peers[msg.PeerHeader.key()]
+		}
 	}
 
-	err := msg.Body.ParseBody(msg, data)
+	err = msg.Body.ParseBody(msg, data)
 	if err != nil {
 		return nil, err
 	}
+
+	if s != nil {
+		switch body := msg.Body.(type) {
+		case *BMPPeerUpNotification:
+			s.peers[msg.PeerHeader.key()] = body.Features()
+		case *BMPPeerDownNotification:
+			delete(s.peers, msg.PeerHeader.key())
+		}
+	}
 	return msg, nil
 }